@@ -0,0 +1,190 @@
+package diffdb
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// AddChanOpts controls how AddChanWith commits objects received from a stream.
+// The zero value adds the whole stream in a single transaction.
+type AddChanOpts struct {
+	// ChunkSize commits the current transaction and starts a new one after this many objects have been received.
+	// If ChunkSize is <= 0 then the transaction is not committed based on the number of objects.
+	ChunkSize int
+
+	// ChunkInterval commits the current transaction and starts a new one when this much time has passed
+	// since the transaction was started. If ChunkInterval is <= 0 then the transaction is not committed based on time.
+	ChunkInterval time.Duration
+
+	// Progress is called every ProgressEvery objects and once when the stream ends.
+	Progress      ProgressFunc
+	ProgressEvery int
+}
+
+// AddChanWith adds objects sent from a channel like AddChan, optionally committing in chunks
+// so that very large streams do not build up a single huge write transaction.
+// If an error occurs then chunks committed before the error remain committed.
+func (diff *Differential) AddChanWith(ctx context.Context, stream <-chan Object, opts AddChanOpts) (err error) {
+	var (
+		n        int
+		objects  int
+		updates  int
+		size     int
+		started  = time.Now()
+		progress = newProgressReporter(opts.Progress, opts.ProgressEvery)
+	)
+
+	ctx, span := diff.startSpan(ctx, "diffdb.AddChan")
+	defer func() {
+		span.SetAttributes(attrObjects.Int(objects), attrUpdated.Int(updates), attrBytes.Int(size))
+		endSpan(span, err)
+	}()
+
+	tx, err := diff.db.beginContext(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+	defer progress.done()
+
+	for {
+		var obj Object
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case obj = <-stream:
+			if obj == nil {
+				return tx.Commit()
+			}
+		}
+
+		updated, sz, err := diff.addTx(tx, obj)
+		if err != nil {
+			return err
+		}
+		progress.processed(updated)
+
+		objects++
+		size += sz
+		if updated {
+			updates++
+		}
+
+		n++
+		if (opts.ChunkSize > 0 && n >= opts.ChunkSize) || (opts.ChunkInterval > 0 && time.Since(started) >= opts.ChunkInterval) {
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			diff.log(slog.LevelDebug, "chunk committed", slog.Int("objects", n))
+
+			next, err := diff.db.beginContext(ctx, true)
+			if err != nil {
+				return err
+			}
+			tx, n, started = next, 0, time.Now()
+		}
+	}
+}
+
+// addResultsChunk is the maximum number of objects AddChanResults adds before committing.
+const addResultsChunk = 1000
+
+// AddResult is the outcome of adding a single object using AddChanResults.
+type AddResult struct {
+	ID      []byte
+	Updated bool
+	Err     error
+}
+
+// AddChanResults adds objects sent from a channel until the channel is closed, the object is nil,
+// or the context is cancelled, and emits a result for every object received.
+// Unlike AddChan an error adding one object does not stop the stream.
+//
+// Objects are committed in groups whenever the stream is idle or after every 1000 objects,
+// and results are only emitted once the group containing the object has been committed.
+// If the commit fails, or the context is cancelled, every object in the uncommitted group is reported with that error.
+// The returned channel is closed when the stream ends and must be drained by the caller.
+func (diff *Differential) AddChanResults(ctx context.Context, stream <-chan Object) <-chan AddResult {
+	results := make(chan AddResult)
+
+	go func() {
+		defer close(results)
+
+		var (
+			tx      Tx
+			pending []AddResult
+		)
+
+		flush := func(err error) {
+			if tx != nil {
+				if err == nil {
+					err = tx.Commit()
+				}
+				if err == nil {
+					diff.log(slog.LevelDebug, "chunk committed", slog.Int("objects", len(pending)))
+				}
+				if err != nil {
+					tx.Rollback()
+				}
+				tx = nil
+			}
+			for _, r := range pending {
+				if err != nil && r.Err == nil {
+					r.Updated, r.Err = false, err
+				}
+				results <- r
+			}
+			pending = pending[:0]
+		}
+
+		for {
+			var (
+				obj Object
+				ok  bool
+			)
+
+			select {
+			case obj, ok = <-stream:
+			default:
+				// The stream is idle, commit everything received so far before blocking
+				flush(nil)
+				select {
+				case <-ctx.Done():
+					return
+				case obj, ok = <-stream:
+				}
+			}
+
+			if err := ctx.Err(); err != nil {
+				if ok && obj != nil {
+					pending = append(pending, AddResult{ID: obj.ID(), Err: err})
+				}
+				flush(err)
+				return
+			}
+			if !ok || obj == nil {
+				flush(nil)
+				return
+			}
+
+			if tx == nil {
+				var err error
+				if tx, err = diff.db.begin(true); err != nil {
+					results <- AddResult{ID: obj.ID(), Err: err}
+					continue
+				}
+			}
+
+			updated, err := diff.AddTx(tx, obj)
+			pending = append(pending, AddResult{ID: obj.ID(), Updated: updated, Err: err})
+			if len(pending) >= addResultsChunk {
+				flush(nil)
+			}
+		}
+	}()
+
+	return results
+}
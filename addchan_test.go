@@ -0,0 +1,83 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// Test that chunks committed before an error remain committed.
+func TestDifferential_AddChanWith_Chunks(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := make(chan Object)
+	go func() {
+		for i := 0; i < 25; i++ {
+			stream <- NewIDObject([]byte(strconv.Itoa(i)), i)
+		}
+		cancel()
+	}()
+
+	err = diff.AddChanWith(ctx, stream, AddChanOpts{ChunkSize: 10})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context cancelled; got %v", err)
+	}
+
+	if pending := diff.CountChanges(); pending != 20 {
+		t.Fatalf("Expected 2 committed chunks of 10 changes; got %d", pending)
+	}
+}
+
+func TestDifferential_AddChanResults(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.MustNotConflict(); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := make(chan Object, 4)
+	stream <- NewIDObject([]byte("1"), 1)
+	stream <- NewIDObject([]byte("2"), 2)
+	stream <- NewIDObject([]byte("1"), 3)
+	stream <- NewIDObject([]byte("3"), 3)
+	close(stream)
+
+	var results []AddResult
+	for r := range diff.AddChanResults(context.Background(), stream) {
+		results = append(results, r)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results; got %d", len(results))
+	}
+	for i, r := range results {
+		if i == 2 {
+			if !errors.Is(r.Err, ErrConflictingKey) || string(r.ID) != "1" {
+				t.Fatalf("Expected a conflict for result %d; got %+v", i, r)
+			}
+			continue
+		}
+		if r.Err != nil || !r.Updated {
+			t.Fatalf("Expected result %d to be updated; got %+v", i, r)
+		}
+	}
+
+	if pending := diff.CountChanges(); pending != 3 {
+		t.Fatalf("Expected 3 pending changes; got %d", pending)
+	}
+}
@@ -0,0 +1,121 @@
+package diffdb
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// idTag is the struct tag value that marks a field as part of an object's ID.
+const idTag = "id"
+
+// idFields caches the indices of the ID fields of each struct type.
+var idFields sync.Map
+
+// IDOf returns the ID of the struct x, or a pointer to it, derived from its fields tagged `diffdb:"id"`.
+//
+//	type Row struct {
+//		Tenant string `diffdb:"id"`
+//		Seq    uint64 `diffdb:"id"`
+//		Value  string
+//	}
+//
+// A single tagged field is encoded using StringKey, Uint64Key or Int64Key depending on its kind,
+// and byte slices are used as is. Multiple tagged fields are combined in the order they are declared using CompositeKey,
+// so IDs are ordered by each field in turn.
+func IDOf(x interface{}) ([]byte, error) {
+	v := reflect.ValueOf(x)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("diffdb: cannot derive an ID from nil %T", x)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("diffdb: cannot derive an ID from non-struct %T", x)
+	}
+
+	fields, err := idFieldsOf(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]Key, len(fields))
+	for i, index := range fields {
+		parts[i] = fieldKey(v.Field(index))
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return CompositeKey(parts...), nil
+}
+
+// idFieldsOf returns the indices of the ID fields of the struct type t.
+func idFieldsOf(t reflect.Type) ([]int, error) {
+	if fields, ok := idFields.Load(t); ok {
+		return fields.([]int), nil
+	}
+
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("diffdb") != idTag {
+			continue
+		}
+		if !keyable(f.Type) {
+			return nil, fmt.Errorf("diffdb: ID field %s.%s has unsupported type %s", t, f.Name, f.Type)
+		}
+		fields = append(fields, i)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("diffdb: %s has no fields tagged `diffdb:\"id\"`", t)
+	}
+
+	idFields.Store(t, fields)
+	return fields, nil
+}
+
+func keyable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+func fieldKey(v reflect.Value) Key {
+	switch v.Kind() {
+	case reflect.String:
+		return StringKey(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int64Key(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Uint64Key(v.Uint())
+	default:
+		return Key(v.Bytes())
+	}
+}
+
+// autoIDObject adapts a struct with tagged ID fields to an Object.
+type autoIDObject struct {
+	obj interface{}
+	id  []byte
+}
+
+func (o autoIDObject) ID() []byte {
+	return o.id
+}
+
+// AutoID returns an Object for the struct x whose ID is derived using IDOf.
+// Like WithStringID, x itself is hashed and stored rather than the wrapper.
+func AutoID(x interface{}) (Object, error) {
+	id, err := IDOf(x)
+	if err != nil {
+		return nil, err
+	}
+	return autoIDObject{obj: x, id: id}, nil
+}
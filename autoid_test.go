@@ -0,0 +1,77 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+type autoIDRow struct {
+	Tenant string `diffdb:"id"`
+	Seq    uint64 `diffdb:"id"`
+	Value  string
+}
+
+func TestIDOf(t *testing.T) {
+	id, err := IDOf(&autoIDRow{Tenant: "a", Seq: 5, Value: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := CompositeKey(StringKey("a"), Uint64Key(5)); !bytes.Equal(id, expect) {
+		t.Fatalf("Expected ID %x; got %x", expect, id)
+	}
+
+	id, err = IDOf(struct {
+		Name string `diffdb:"id"`
+	}{"b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(id) != "b" {
+		t.Fatalf("Expected a single field to be used as is; got %q", id)
+	}
+
+	if _, err := IDOf(struct{ Name string }{"c"}); err == nil {
+		t.Fatal("Expected an error for a struct without ID fields")
+	}
+	if _, err := IDOf(struct {
+		F float64 `diffdb:"id"`
+	}{1}); err == nil {
+		t.Fatal("Expected an error for an unsupported ID field")
+	}
+}
+
+func TestAutoID(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []autoIDRow{{"a", 10, "x"}, {"a", 2, "y"}} {
+		obj, err := AutoID(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := diff.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var seen []uint64
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var r autoIDRow
+		if err := data.Decode(&r); err != nil {
+			return err
+		}
+		seen = append(seen, r.Seq)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != 2 || seen[1] != 10 {
+		t.Fatalf("Expected rows in sequence order; got %v", seen)
+	}
+}
@@ -0,0 +1,86 @@
+package diffdb
+
+// A Backend is a transactional store of nested, ordered key/value buckets used to persist differential state.
+// BoltDB is used by default, but any store that can provide ordered keys and serialisable transactions
+// (such as Badger or Pebble) may be plugged in using NewWithBackend.
+//
+// The interfaces mirror the BoltDB API so that existing BoltDB code can be ported with few changes.
+type Backend interface {
+	// Begin starts a new transaction.
+	// Only one writable transaction may be in progress at any time.
+	Begin(writable bool) (Tx, error)
+
+	// Close releases all resources held by the backend.
+	Close() error
+}
+
+// A Tx is a read-only or read-write transaction on a Backend.
+type Tx interface {
+	// Bucket returns the top-level bucket with the given name, or nil if it does not exist.
+	Bucket(name []byte) Bucket
+	CreateBucket(name []byte) (Bucket, error)
+	CreateBucketIfNotExists(name []byte) (Bucket, error)
+	DeleteBucket(name []byte) error
+
+	// ForEach calls f for each top-level bucket in key order.
+	ForEach(f func(name []byte, b Bucket) error) error
+
+	// OnCommit adds a handler function to be executed after the transaction successfully commits.
+	OnCommit(f func())
+
+	Writable() bool
+	Commit() error
+	Rollback() error
+}
+
+// A Bucket is an ordered collection of key/value pairs and nested buckets inside a transaction.
+// Values returned by a Bucket are only valid for the life of the transaction.
+type Bucket interface {
+	// Get returns the value of key, or nil if the key does not exist or is a nested bucket.
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+
+	// Bucket returns the nested bucket with the given name, or nil if it does not exist.
+	Bucket(name []byte) Bucket
+	CreateBucket(name []byte) (Bucket, error)
+	CreateBucketIfNotExists(name []byte) (Bucket, error)
+	DeleteBucket(name []byte) error
+
+	// Cursor creates a cursor over the keys of this bucket.
+	// Nested buckets are returned with a nil value.
+	Cursor() Cursor
+
+	// ForEach calls f for each key/value pair in key order.
+	// Nested buckets are returned with a nil value.
+	ForEach(f func(k, v []byte) error) error
+
+	// KeyN returns the number of keys in the bucket.
+	KeyN() int
+
+	Sequence() uint64
+	SetSequence(v uint64) error
+	NextSequence() (uint64, error)
+}
+
+// A Cursor iterates over the keys of a Bucket in order.
+// Each method returns a nil key when the cursor has been exhausted.
+type Cursor interface {
+	First() (key []byte, value []byte)
+	Last() (key []byte, value []byte)
+	Next() (key []byte, value []byte)
+	Prev() (key []byte, value []byte)
+	Seek(seek []byte) (key []byte, value []byte)
+	Delete() error
+}
+
+// view executes f inside a read-only transaction.
+func view(backend Backend, f func(tx Tx) error) error {
+	tx, err := backend.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return f(tx)
+}
@@ -0,0 +1,185 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A testBackend opens an empty database for tests that must pass against every backend.
+// The memory backend tracks cursor positions by key and counts keys exactly,
+// which hides behaviour that differs on BoltDB such as cursors skipping keys deleted during iteration.
+type testBackend struct {
+	name string
+	open func(t *testing.T) *DB
+}
+
+var testBackends = []testBackend{
+	{"Memory", func(t *testing.T) *DB {
+		db := NewMemory()
+		t.Cleanup(func() {
+			db.Close()
+		})
+		return db
+	}},
+	{"Bolt", func(t *testing.T) *DB {
+		dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := New(filepath.Join(dir, "state.db"))
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			db.Close()
+			os.RemoveAll(dir)
+		})
+		return db
+	}},
+}
+
+// TestBackends runs apply runs in each order, eviction and history retention against every backend.
+func TestBackends(t *testing.T) {
+	type testCase struct {
+		name string
+		run  func(t *testing.T, diff *Differential)
+	}
+
+	// apply applies every pending change of diff, failing changes for which fail returns true,
+	// and returns the IDs of the changes that were given to the apply function.
+	apply := func(t *testing.T, diff *Differential, opts EachOpts, fail func(id string) bool) string {
+		var applied []string
+		err := diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+			applied = append(applied, string(id))
+			if fail != nil && fail(string(id)) {
+				return errors.New("fail")
+			}
+			return nil
+		}, opts)
+		if err != nil && fail == nil {
+			t.Fatal(err)
+		}
+		return strings.Join(applied, " ")
+	}
+	add := func(t *testing.T, diff *Differential, obj Object) {
+		if _, err := diff.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectPending := func(t *testing.T, diff *Differential, n int) {
+		if pending := diff.CountChanges(); pending != n {
+			t.Fatalf("Expected %d pending changes; got %d", n, pending)
+		}
+	}
+
+	var cases []testCase
+	for _, commitEvery := range []int{0, 3} {
+		opts := EachOpts{CommitEvery: commitEvery}
+		cases = append(cases,
+			testCase{"Each/" + strconv.Itoa(commitEvery), func(t *testing.T, diff *Differential) {
+				for i := 0; i < 20; i++ {
+					add(t, diff, NewIDObject([]byte(fmt.Sprintf("%02d", i)), i))
+				}
+				// Failed changes are updated while the run is iterating the pending changes
+				got := apply(t, diff, opts, func(id string) bool {
+					return id[1] == '5'
+				})
+				if expect := "00 01 02 03 04 05 06 07 08 09 10 11 12 13 14 15 16 17 18 19"; got != expect {
+					t.Fatalf("Expected %s; got %s", expect, got)
+				}
+				expectPending(t, diff, 2)
+			}},
+			testCase{"Priority/" + strconv.Itoa(commitEvery), func(t *testing.T, diff *Differential) {
+				for i := 0; i < 10; i++ {
+					add(t, diff, WithPriority(NewIDObject([]byte(strconv.Itoa(i)), i), uint64(i%2)))
+				}
+				if got, expect := apply(t, diff, opts, nil), "1 3 5 7 9 0 2 4 6 8"; got != expect {
+					t.Fatalf("Expected %s; got %s", expect, got)
+				}
+				expectPending(t, diff, 0)
+			}},
+			testCase{"StagingOrder/" + strconv.Itoa(commitEvery), func(t *testing.T, diff *Differential) {
+				if err := diff.ApplyInStagingOrder(); err != nil {
+					t.Fatal(err)
+				}
+				// Stage in reverse ID order, prioritising every third change
+				for i := 9; i >= 0; i-- {
+					obj := Object(NewIDObject([]byte(strconv.Itoa(i)), i))
+					if i%3 == 0 {
+						obj = WithPriority(obj, 1)
+					}
+					add(t, diff, obj)
+				}
+				if got, expect := apply(t, diff, opts, nil), "9 6 3 0 8 7 5 4 2 1"; got != expect {
+					t.Fatalf("Expected %s; got %s", expect, got)
+				}
+				expectPending(t, diff, 0)
+			}},
+		)
+	}
+	cases = append(cases,
+		testCase{"Eviction", func(t *testing.T, diff *Differential) {
+			// Interleave stale and recent changes so that eviction deletes keys between those left to apply
+			for i := 0; i < 20; i += 2 {
+				add(t, diff, NewIDObject([]byte(fmt.Sprintf("%02d", i)), i))
+			}
+			time.Sleep(50 * time.Millisecond)
+			for i := 1; i < 20; i += 2 {
+				add(t, diff, NewIDObject([]byte(fmt.Sprintf("%02d", i)), i))
+			}
+			diff.SetPendingMaxAge(25*time.Millisecond, EvictDiscard)
+
+			if got, expect := apply(t, diff, EachOpts{}, nil), "01 03 05 07 09 11 13 15 17 19"; got != expect {
+				t.Fatalf("Expected only the recent changes to be applied; got %s", got)
+			}
+			expectPending(t, diff, 0)
+		}},
+		testCase{"History", func(t *testing.T, diff *Differential) {
+			if err := diff.RetainHistory(2); err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= 4; i++ {
+				add(t, diff, NewIDObject([]byte("1"), i))
+				apply(t, diff, EachOpts{}, nil)
+			}
+
+			versions, err := diff.History([]byte("1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(versions) != 2 {
+				t.Fatalf("Expected 2 retained versions; got %d", len(versions))
+			}
+			for i, v := range versions {
+				var x struct{ Object int }
+				if err := v.Decode(&x); err != nil {
+					t.Fatal(err)
+				}
+				if x.Object != i+3 {
+					t.Fatalf("Expected version %d to contain %d; got %d", i, i+3, x.Object)
+				}
+			}
+		}},
+	)
+
+	for _, backend := range testBackends {
+		for _, tc := range cases {
+			t.Run(backend.name+"/"+tc.name, func(t *testing.T) {
+				diff, err := backend.open(t).Open("test")
+				if err != nil {
+					t.Fatal(err)
+				}
+				tc.run(t, diff)
+			})
+		}
+	}
+}
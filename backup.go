@@ -0,0 +1,120 @@
+package diffdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrDatabaseInUse is returned by Restore when the target database file is held open by another process.
+var ErrDatabaseInUse = errors.New("diffdb: refusing to overwrite a database that is in use")
+
+// A Snapshotter is a Backend that can write a consistent snapshot of its entire contents.
+type Snapshotter interface {
+	Snapshot(w io.Writer) (int64, error)
+}
+
+// Backup streams a consistent snapshot of the database to w, returning the number of bytes written.
+// Backup runs in a read-only transaction so changes may continue to be added and applied while it is in progress.
+//
+// The backend must implement Snapshotter, otherwise an error wrapping errors.ErrUnsupported is returned.
+func (db *DB) Backup(w io.Writer) (int64, error) {
+	s, ok := db.backend.(Snapshotter)
+	if !ok {
+		return 0, fmt.Errorf("diffdb: backend does not support backups: %w", errors.ErrUnsupported)
+	}
+	return s.Snapshot(w)
+}
+
+// Restore writes a snapshot created by Backup to path and opens it.
+// The snapshot is written to a temporary file next to path and checked for consistency
+// before atomically replacing any existing file.
+//
+// Restore returns ErrDatabaseInUse if a database already exists at path and is open elsewhere,
+// or the error opening any other existing file at path, such as a file that is not a database, which is left in place.
+func Restore(r io.Reader, path string) (*DB, error) {
+	if err := checkNotInUse(path); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".restore-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSnapshot(tmp); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp, os.FileMode(0600)); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+
+	return New(path)
+}
+
+// checkNotInUse returns ErrDatabaseInUse if the BoltDB file at path is locked by another open database,
+// or the error opening it if it exists but cannot be opened.
+func checkNotInUse(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(path, os.FileMode(0600), &bolt.Options{
+		Timeout: 100 * time.Millisecond,
+	})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return ErrDatabaseInUse
+	}
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// validateSnapshot opens the BoltDB file at path and runs a consistency check over all pages.
+func validateSnapshot(path string) error {
+	db, err := bolt.Open(path, os.FileMode(0600), &bolt.Options{
+		ReadOnly: true,
+	})
+	if err != nil {
+		return fmt.Errorf("diffdb: invalid snapshot: %w", err)
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		// The check must run to completion, otherwise it blocks sending the next error and holds the transaction open
+		var first error
+		for err := range tx.Check() {
+			if first == nil {
+				first = err
+			}
+		}
+		if first != nil {
+			return fmt.Errorf("diffdb: invalid snapshot: %w", first)
+		}
+		return nil
+	})
+}
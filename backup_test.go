@@ -0,0 +1,84 @@
+package diffdb
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_Backup(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := New(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(IDMapper{id: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := db.Backup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("Expected %d bytes written; got %d", buf.Len(), n)
+	}
+
+	if _, err := Restore(bytes.NewReader(buf.Bytes()), filepath.Join(dir, "state.db")); err != ErrDatabaseInUse {
+		t.Fatalf("Expected %q restoring over a live database; got %v", ErrDatabaseInUse, err)
+	}
+	if _, err := Restore(bytes.NewReader([]byte("not a database")), filepath.Join(dir, "invalid.db")); err == nil {
+		t.Fatal("Expected an error restoring an invalid snapshot")
+	}
+
+	// An existing file that cannot be opened is not replaced
+	other := filepath.Join(dir, "other.db")
+	if err := ioutil.WriteFile(other, []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(bytes.NewReader(buf.Bytes()), other); err == nil || err == ErrDatabaseInUse {
+		t.Fatalf("Expected the error opening the existing file; got %v", err)
+	}
+	if data, err := ioutil.ReadFile(other); err != nil || string(data) != "not a database" {
+		t.Fatalf("Expected the existing file to be left in place; got %q (%v)", data, err)
+	}
+
+	restored, err := Restore(&buf, filepath.Join(dir, "backup.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	rdiff, err := restored.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := rdiff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected 1 pending change in backup; got %d", pending)
+	}
+}
+
+func TestDB_Backup_Unsupported(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	_, err := db.Backup(ioutil.Discard)
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Expected unsupported error; got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package diffdb
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// A Change is a single pending change delivered to a batch apply function.
+type Change struct {
+	ID   []byte
+	Hash []byte
+	Data Decoder
+}
+
+// BatchApplyFunc is a function to be called to apply a batch of pending changes.
+// If it returns an error then every change in the batch remains pending.
+type BatchApplyFunc func(batch []Change) error
+
+// EachBatch scans through pending changes and applies them using f in batches of up to batchSize changes,
+// which allows the apply function to issue multi-row statements to the target system.
+// If batchSize is < 1 then a batch size of 1 is used.
+//
+// Changes are batched in ID order. Unlike EachWith, EachBatch does not apply changes in priority
+// or staging order and does not evict changes older than the maximum age set using SetPendingMaxAge.
+// In-flight changes that were prepared by a TwoPhase run or leased by Consume are skipped.
+func (diff *Differential) EachBatch(ctx context.Context, f BatchApplyFunc, batchSize int) error {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	tx, err := diff.db.begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	b := tx.Bucket(diff.q)
+	var (
+		bph = b.Bucket(bucketPendingHashes)
+		bpd = b.Bucket(bucketPendingData)
+		cur = bph.Cursor()
+		run = nextRun(b)
+
+		updateErr *multierror.Error
+		batch     = make([]Change, 0, batchSize)
+		payloads  = make([][]byte, 0, batchSize)
+		last      []byte
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			updateErr = multierror.Append(updateErr, err)
+			break
+		}
+
+		batch, payloads = batch[:0], payloads[:0]
+		for id, hash := seekAfter(cur, last); id != nil && len(batch) < batchSize; id, hash = cur.Next() {
+			last = append(last[:0], id...)
+			if inFlight(b, id) {
+				continue
+			}
+
+			data := bpd.Get(id)
+			if data == nil {
+				return missingPayload(diff.Name(), id)
+			}
+
+			data = append([]byte(nil), data...)
+			batch = append(batch, Change{
+				ID:   append([]byte(nil), id...),
+				Hash: append([]byte(nil), hash...),
+				Data: &msgpackDecoder{data: data, run: run},
+			})
+			payloads = append(payloads, data)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if applyErr := f(batch); applyErr != nil {
+			updateErr = multierror.Append(updateErr, applyErr)
+			for i, c := range batch {
+				if err := diff.failChange(tx, b, c.ID, c.Hash, payloads[i], applyErr); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		for i, c := range batch {
+			if err := diff.commitChange(tx, b, c.ID, c.Hash, payloads[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := recordRun(b, run); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return updateErr.ErrorOrNil()
+}
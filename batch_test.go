@@ -0,0 +1,89 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestDifferential_EachBatch(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sizes []int
+	err = diff.EachBatch(context.Background(), func(batch []Change) error {
+		sizes = append(sizes, len(batch))
+		for _, c := range batch {
+			var x struct{ Object int }
+			if err := c.Data.Decode(&x); err != nil {
+				return err
+			}
+			if strconv.Itoa(x.Object) != string(c.ID) {
+				return errors.New("payload does not match id")
+			}
+		}
+		if len(sizes) == 2 {
+			return errors.New("batch failed")
+		}
+		return nil
+	}, 10)
+	if err == nil {
+		t.Fatal("Expected a batch error")
+	}
+
+	if len(sizes) != 3 || sizes[0] != 10 || sizes[1] != 10 || sizes[2] != 5 {
+		t.Fatalf("Unexpected batch sizes %v", sizes)
+	}
+	if tracking := diff.CountTracking(); tracking != 15 {
+		t.Fatalf("Expected 15 applied changes; got %d", tracking)
+	}
+	if pending := diff.CountChanges(); pending != 10 {
+		t.Fatalf("Expected the failed batch to remain pending; got %d", pending)
+	}
+}
+
+func TestDifferential_EachBatch_InFlight(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, EachOpts{TwoPhase: true, N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []string
+	err = diff.EachBatch(context.Background(), func(batch []Change) error {
+		for _, c := range batch {
+			applied = append(applied, string(c.ID))
+		}
+		return nil
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0] != "2" || applied[1] != "3" || diff.CountChanges() != 1 {
+		t.Fatalf("Expected 2 and 3 to be applied and the in-flight change to remain pending; got %v", applied)
+	}
+}
@@ -0,0 +1,34 @@
+package diffdb
+
+import (
+	"context"
+)
+
+// A Batcher is a Backend that can coalesce concurrent writes into a single transaction.
+type Batcher interface {
+	// Batch calls f as part of a batch with other concurrent calls to Batch.
+	// f may be called more than once if another function in the same batch fails, so it must be idempotent.
+	Batch(f func(tx Tx) error) error
+}
+
+// SetBatchAdd makes Add and AddContext write through the backend's Batcher instead of starting a transaction
+// for every call, so that concurrent calls from many goroutines are coalesced into fewer commits and fsyncs.
+// Each call still waits until its batch has been committed before returning, which may add latency to a single caller.
+//
+// Batching only takes effect if the backend implements Batcher, such as the BoltDB backend.
+func (db *DB) SetBatchAdd(enabled bool) {
+	db.batchAdd.Store(enabled)
+}
+
+// addContext executes f inside a read-write transaction like updateContext,
+// or as part of a batch if batched adds are enabled and supported by the backend.
+func (db *DB) addContext(ctx context.Context, f func(tx Tx) error) error {
+	b, ok := db.backend.(Batcher)
+	if !ok || !db.batchAdd.Load() || db.readOnly {
+		return db.updateContext(ctx, f)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.Batch(f)
+}
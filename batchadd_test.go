@@ -0,0 +1,109 @@
+package diffdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDB_SetBatchAdd(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewWithOptions(filepath.Join(dir, "test.db"), &NewOptions{
+		BatchAdd:      true,
+		MaxBatchDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var staged atomic.Int32
+	diff.OnAdd(func(id, hash []byte) {
+		staged.Add(1)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if pending := diff.CountChanges(); pending != 50 {
+		t.Fatalf("Expected 50 pending changes; got %d", pending)
+	}
+	if n := staged.Load(); n != 50 {
+		t.Fatalf("Expected 50 staged events; got %d", n)
+	}
+}
+
+// Test that an add that fails in a batch, which is then retried on its own, is only counted once.
+func TestDB_SetBatchAdd_Retry(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewWithOptions(filepath.Join(dir, "test.db"), &NewOptions{
+		BatchAdd:      true,
+		MaxBatchDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.MustNotConflict(); err != nil {
+		t.Fatal(err)
+	}
+	diff.SetQuota(Quota{MaxPending: 2})
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 2)); !errors.Is(err, ErrConflictingKey) {
+		t.Fatalf("Expected %q; got %v", ErrConflictingKey, err)
+	}
+	if reports, _ := diff.Conflicts(); len(reports) != 1 || reports[0].Count != 2 {
+		t.Fatalf("Expected a single conflict to be counted; got %+v", reports)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("2"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	err = db.view(func(tx Tx) error {
+		if u, _ := loadPendingUsage(tx.Bucket([]byte("test"))); u.count != 2 {
+			t.Fatalf("Expected a usage of 2 pending changes; got %d", u.count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,368 @@
+package diffdb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	_ Backend     = (*boltBackend)(nil)
+	_ Snapshotter = (*boltBackend)(nil)
+	_ Compactor   = (*boltBackend)(nil)
+	_ Batcher     = (*boltBackend)(nil)
+	_ dbStatser   = (*boltBackend)(nil)
+)
+
+// NewBoltBackend uses an already open BoltDB database as a Backend.
+func NewBoltBackend(db *bolt.DB) Backend {
+	return &boltBackend{db: db}
+}
+
+// boltBackend is the default Backend implementation backed by a BoltDB file.
+// Every open transaction is counted in open until it is committed or rolled back,
+// so that Compact can wait until no transaction is open before it replaces db.
+// New transactions are only blocked while compacting, by which time none are open,
+// so a goroutine that begins a transaction while holding another cannot deadlock with Compact.
+type boltBackend struct {
+	mu         sync.Mutex
+	cond       sync.Cond // signalled when open reaches zero or compaction finishes
+	open       int
+	compacting bool
+
+	db *bolt.DB
+
+	// opts are the options db was opened with, which are used again when it is reopened after compaction.
+	opts *bolt.Options
+
+	// err is set if db could not be reopened after compaction, and is returned by every later transaction.
+	err error
+}
+
+// acquire counts a new use of db, waiting for any compaction to finish first.
+// It returns the error of a failed compaction, in which case release must not be called.
+func (b *boltBackend) acquire() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.compacting {
+		b.wait()
+	}
+	if b.err != nil {
+		return b.err
+	}
+	b.open++
+	return nil
+}
+
+// release ends a use of db started by acquire.
+func (b *boltBackend) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open--; b.open == 0 {
+		b.cond.Broadcast()
+	}
+}
+
+// wait waits for cond to be signalled. mu must be held.
+func (b *boltBackend) wait() {
+	if b.cond.L == nil {
+		b.cond.L = &b.mu
+	}
+	b.cond.Wait()
+}
+
+func (b *boltBackend) Begin(writable bool) (Tx, error) {
+	if err := b.acquire(); err != nil {
+		return nil, err
+	}
+
+	tx, err := b.db.Begin(writable)
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+	return &boltTx{tx: tx, release: b.release}, nil
+}
+
+// Batch calls f as part of a BoltDB batch transaction.
+func (b *boltBackend) Batch(f func(tx Tx) error) error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		return f(&boltTx{tx: tx})
+	})
+}
+
+func (b *boltBackend) Close() error {
+	if err := b.acquire(); err != nil {
+		return nil
+	}
+	defer b.release()
+	return b.db.Close()
+}
+
+// Snapshot writes the database file to w using a read-only transaction.
+func (b *boltBackend) Snapshot(w io.Writer) (n int64, err error) {
+	if err := b.acquire(); err != nil {
+		return 0, err
+	}
+	defer b.release()
+
+	err = b.db.View(func(tx *bolt.Tx) error {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return
+}
+
+// stats returns the size of the database file and its BoltDB statistics.
+func (b *boltBackend) stats() (size int64, stats bolt.Stats, err error) {
+	if err := b.acquire(); err != nil {
+		return 0, stats, err
+	}
+	defer b.release()
+
+	err = b.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	return size, b.db.Stats(), err
+}
+
+// Compact copies the live database into a new file next to it, then replaces the original file and reopens it
+// using the options it was opened with. Compaction starts once no transaction is open, and new transactions are blocked
+// until it has finished. Transactions can still be started while it waits, so under constant load it may wait indefinitely.
+//
+// If the file cannot be reopened then the backend is unusable and every later transaction returns the error.
+func (b *boltBackend) Compact() error {
+	b.mu.Lock()
+	for b.compacting || b.open > 0 {
+		b.wait()
+	}
+	if b.err != nil {
+		b.mu.Unlock()
+		return b.err
+	}
+	b.compacting = true
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.compacting = false
+		b.cond.Broadcast()
+		b.mu.Unlock()
+	}()
+
+	var (
+		src  = b.db
+		path = src.Path()
+		tmp  = path + ".compact"
+		opts = b.options()
+	)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	dst, err := bolt.Open(tmp, fi.Mode(), opts)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	err = bolt.Compact(dst, src, compactTxMaxSize)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := src.Close(); err != nil {
+		return err
+	}
+
+	// The original file is reopened if it cannot be replaced
+	err = os.Rename(tmp, path)
+
+	db, oerr := bolt.Open(path, fi.Mode(), opts)
+	if oerr != nil {
+		b.err = fmt.Errorf("diffdb: database is closed because it could not be reopened after compaction: %w", oerr)
+		return b.err
+	}
+	db.MaxBatchSize = src.MaxBatchSize
+	db.MaxBatchDelay = src.MaxBatchDelay
+	db.AllocSize = src.AllocSize
+	b.db = db
+	return err
+}
+
+// options returns the options to reopen the database with.
+// OpenFile is not kept, as the compacted file must be created even if the database was opened using MustExist.
+func (b *boltBackend) options() *bolt.Options {
+	if b.opts != nil {
+		opts := *b.opts
+		opts.OpenFile = nil
+		return &opts
+	}
+	return &bolt.Options{
+		NoSync:       b.db.NoSync,
+		FreelistType: b.db.FreelistType,
+	}
+}
+
+// compactTxMaxSize is the number of bytes copied in a single transaction while compacting.
+const compactTxMaxSize = 64 << 20
+
+type boltTx struct {
+	tx *bolt.Tx
+
+	// release is called once the transaction is committed or rolled back,
+	// or is nil if the transaction is managed by BoltDB such as in a batch.
+	release func()
+	// onCommit are the handlers to call after release once the transaction is committed,
+	// so that handlers which start a new transaction do not wait on a pending compaction.
+	onCommit []func()
+}
+
+// done releases the transaction if it has not been released yet.
+func (tx *boltTx) done() {
+	if tx.release != nil {
+		tx.release()
+		tx.release = nil
+	}
+}
+
+func (tx *boltTx) Bucket(name []byte) Bucket {
+	return wrapBoltBucket(tx.tx.Bucket(name))
+}
+
+func (tx *boltTx) CreateBucket(name []byte) (Bucket, error) {
+	b, err := tx.tx.CreateBucket(name)
+	return wrapBoltBucket(b), err
+}
+
+func (tx *boltTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	b, err := tx.tx.CreateBucketIfNotExists(name)
+	return wrapBoltBucket(b), err
+}
+
+func (tx *boltTx) DeleteBucket(name []byte) error {
+	return tx.tx.DeleteBucket(name)
+}
+
+func (tx *boltTx) ForEach(f func(name []byte, b Bucket) error) error {
+	return tx.tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		return f(name, wrapBoltBucket(b))
+	})
+}
+
+func (tx *boltTx) OnCommit(f func()) {
+	if tx.release == nil {
+		tx.tx.OnCommit(f)
+		return
+	}
+	tx.onCommit = append(tx.onCommit, f)
+}
+
+func (tx *boltTx) Writable() bool {
+	return tx.tx.Writable()
+}
+
+func (tx *boltTx) Commit() error {
+	if tx.release == nil {
+		return tx.tx.Commit()
+	}
+	err := tx.tx.Commit()
+	tx.done()
+	if err != nil {
+		return err
+	}
+	for _, f := range tx.onCommit {
+		f()
+	}
+	return nil
+}
+
+func (tx *boltTx) Rollback() error {
+	err := tx.tx.Rollback()
+	tx.done()
+	return err
+}
+
+// wrapBoltBucket wraps b as a Bucket, preserving nil.
+func wrapBoltBucket(b *bolt.Bucket) Bucket {
+	if b == nil {
+		return nil
+	}
+	return &boltBucket{b: b}
+}
+
+type boltBucket struct {
+	b *bolt.Bucket
+}
+
+func (b *boltBucket) Get(key []byte) []byte {
+	return b.b.Get(key)
+}
+
+func (b *boltBucket) Put(key, value []byte) error {
+	return b.b.Put(key, value)
+}
+
+func (b *boltBucket) Delete(key []byte) error {
+	return b.b.Delete(key)
+}
+
+func (b *boltBucket) Bucket(name []byte) Bucket {
+	return wrapBoltBucket(b.b.Bucket(name))
+}
+
+func (b *boltBucket) CreateBucket(name []byte) (Bucket, error) {
+	nb, err := b.b.CreateBucket(name)
+	return wrapBoltBucket(nb), err
+}
+
+func (b *boltBucket) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	nb, err := b.b.CreateBucketIfNotExists(name)
+	return wrapBoltBucket(nb), err
+}
+
+func (b *boltBucket) DeleteBucket(name []byte) error {
+	return b.b.DeleteBucket(name)
+}
+
+func (b *boltBucket) Cursor() Cursor {
+	return b.b.Cursor()
+}
+
+func (b *boltBucket) ForEach(f func(k, v []byte) error) error {
+	return b.b.ForEach(f)
+}
+
+func (b *boltBucket) KeyN() int {
+	return b.b.Stats().KeyN
+}
+
+func (b *boltBucket) Stats() bolt.BucketStats {
+	return b.b.Stats()
+}
+
+func (b *boltBucket) Sequence() uint64 {
+	return b.b.Sequence()
+}
+
+func (b *boltBucket) SetSequence(v uint64) error {
+	return b.b.SetSequence(v)
+}
+
+func (b *boltBucket) NextSequence() (uint64, error) {
+	return b.b.NextSequence()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/relvacode/diffdb"
+)
+
+func openDB(path string, readOnly bool) (*diffdb.DB, error) {
+	return diffdb.NewWithOptions(path, &diffdb.NewOptions{
+		Timeout:   timeout,
+		ReadOnly:  readOnly,
+		MustExist: true,
+	})
+}
+
+// openExisting opens the named differential of db, returning an error if it does not exist.
+// Opening a differential of a writable database would otherwise create it.
+func openExisting(db *diffdb.DB, name string) (*diffdb.Differential, error) {
+	names, err := db.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range names {
+		if n == name {
+			return db.Open(name)
+		}
+	}
+	return nil, fmt.Errorf("differential %q does not exist", name)
+}
+
+func list(path string, _ []string) error {
+	db, err := openDB(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	names, err := db.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintln(stdout, name)
+	}
+	return nil
+}
+
+func count(path string, args []string) error {
+	db, err := openDB(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	diff, err := db.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "tracking\t%d\npending\t%d\n", diff.CountTracking(), diff.CountChanges())
+	return nil
+}
+
+func dump(path string, args []string) error {
+	db, err := openDB(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	diff, err := db.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	return diff.Export(stdout, diffdb.NDJSON)
+}
+
+func plan(path string, args []string) error {
+	db, err := openDB(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	diff, err := db.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	report, err := diff.Plan(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, c := range report.Changes {
+		fmt.Fprintf(stdout, "%s\t%s\t%x\t%d\n", c.Kind, c.ID, c.Hash, c.Size)
+	}
+	fmt.Fprintf(stdout, "\n%d to create, %d to update, %d bytes\n", report.Counts[diffdb.ChangeCreate], report.Counts[diffdb.ChangeUpdate], report.Bytes)
+	return nil
+}
+
+func hash(path string, args []string) error {
+	db, err := openDB(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	diff, err := db.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	committed, pending, err := diff.HashFor([]byte(args[1]))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "committed\t%x\npending\t%x\n", committed, pending)
+	return nil
+}
+
+func discard(path string, args []string) error {
+	db, err := openDB(path, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The differential is checked while holding the file lock, so it cannot be removed in the meantime
+	diff, err := openExisting(db, args[0])
+	if err != nil {
+		return err
+	}
+
+	return diff.DiscardPending()
+}
+
+func remove(path string, args []string) error {
+	db, err := openDB(path, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Delete(args[0])
+}
@@ -0,0 +1,117 @@
+// Command diffdbctl inspects and maintains diffdb database files.
+//
+// Usage:
+//
+//	diffdbctl -db state.db list
+//	diffdbctl -db state.db count <differential>
+//	diffdbctl -db state.db dump <differential>
+//	diffdbctl -db state.db plan <differential>
+//	diffdbctl -db state.db hash <differential> <id>
+//	diffdbctl -db state.db discard <differential>
+//	diffdbctl -db state.db delete <differential>
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type command struct {
+	usage string
+	args  int
+	run   func(path string, args []string) error
+}
+
+var commands = map[string]command{
+	"list": {
+		usage: "list differentials stored in the database",
+		run:   list,
+	},
+	"count": {
+		usage: "show tracking and pending counts of a differential",
+		args:  1,
+		run:   count,
+	},
+	"dump": {
+		usage: "dump pending changes of a differential as newline-delimited JSON",
+		args:  1,
+		run:   dump,
+	},
+	"plan": {
+		usage: "summarise the changes the next apply run would make",
+		args:  1,
+		run:   plan,
+	},
+	"hash": {
+		usage: "show the committed and pending hashes of an ID",
+		args:  2,
+		run:   hash,
+	},
+	"discard": {
+		usage: "discard all pending changes of a differential",
+		args:  1,
+		run:   discard,
+	},
+	"delete": {
+		usage: "delete a differential and all of its state",
+		args:  1,
+		run:   remove,
+	},
+}
+
+var order = []string{"list", "count", "dump", "plan", "hash", "discard", "delete"}
+
+// timeout is the amount of time to wait for a file lock held by another process.
+var timeout time.Duration
+
+// stdout is where commands write their output.
+var stdout io.Writer = os.Stdout
+
+// errUsage is returned by parse if the command line is not a valid command.
+var errUsage = errors.New("invalid usage")
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -db <path> <command> [differential] [id]\n\nCommands:\n", os.Args[0])
+	for _, name := range order {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-8s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// parse parses the command line arguments args using fs,
+// returning the path of the database and the command to run with its arguments.
+func parse(fs *flag.FlagSet, args []string) (string, command, []string, error) {
+	path := fs.String("db", "", "path to the diffdb database file")
+	fs.DurationVar(&timeout, "timeout", 5*time.Second, "time to wait for the database file lock")
+	if err := fs.Parse(args); err != nil {
+		return "", command{}, nil, err
+	}
+
+	if *path == "" || fs.NArg() == 0 {
+		return "", command{}, nil, errUsage
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok || fs.NArg()-1 != cmd.args {
+		return "", command{}, nil, errUsage
+	}
+	return *path, cmd, fs.Args()[1:], nil
+}
+
+func main() {
+	flag.Usage = usage
+	path, cmd, args, err := parse(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(path, args); err != nil {
+		fmt.Fprintf(os.Stderr, "diffdbctl: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relvacode/diffdb"
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		args []string
+		path string
+		rest []string
+		err  bool
+	}{
+		{"List", []string{"-db", "state.db", "list"}, "state.db", []string{}, false},
+		{"Count", []string{"-db", "state.db", "count", "test"}, "state.db", []string{"test"}, false},
+		{"Hash", []string{"-db", "state.db", "-timeout", "1s", "hash", "test", "1"}, "state.db", []string{"test", "1"}, false},
+		{"NoPath", []string{"list"}, "", nil, true},
+		{"NoCommand", []string{"-db", "state.db"}, "", nil, true},
+		{"UnknownCommand", []string{"-db", "state.db", "inspect", "test"}, "", nil, true},
+		{"MissingArgument", []string{"-db", "state.db", "discard"}, "", nil, true},
+		{"ExtraArgument", []string{"-db", "state.db", "list", "test"}, "", nil, true},
+		{"UnknownFlag", []string{"-db", "state.db", "-force", "list"}, "", nil, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("diffdbctl", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+
+			path, _, rest, err := parse(fs, c.args)
+			if (err != nil) != c.err {
+				t.Fatalf("Expected an error %v; got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if path != c.path || strings.Join(rest, " ") != strings.Join(c.rest, " ") {
+				t.Fatalf("Expected %q %q; got %q %q", c.path, c.rest, path, rest)
+			}
+		})
+	}
+}
+
+type row struct {
+	Key string
+}
+
+func (r row) ID() []byte {
+	return []byte(r.Key)
+}
+
+// testDB creates a database file with a differential named test that has two pending changes.
+func testDB(t *testing.T) string {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	path := filepath.Join(dir, "state.db")
+	db, err := diffdb.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if _, err := diff.Add(row{Key: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// runCommand runs the named command against the database at path and returns its output.
+func runCommand(t *testing.T, path, name string, args ...string) (string, error) {
+	var out bytes.Buffer
+	stdout = &out
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	err := commands[name].run(path, args)
+	return out.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	path := testDB(t)
+
+	for _, c := range []struct {
+		name   string
+		args   []string
+		expect string
+		err    bool
+	}{
+		{"list", nil, "test\n", false},
+		{"count", []string{"test"}, "tracking\t0\npending\t2\n", false},
+		{"count", []string{"missing"}, "", true},
+		{"dump", []string{"test"}, "", false},
+		{"discard", []string{"missing"}, "", true},
+		{"discard", []string{"test"}, "", false},
+		{"count", []string{"test"}, "tracking\t0\npending\t0\n", false},
+		{"list", nil, "test\n", false},
+	} {
+		out, err := runCommand(t, path, c.name, c.args...)
+		if (err != nil) != c.err {
+			t.Fatalf("%s %q: expected an error %v; got %v", c.name, c.args, c.err, err)
+		}
+		if c.name == "dump" {
+			if lines := strings.Count(out, "\n"); lines != 2 {
+				t.Fatalf("Expected 2 dumped changes; got %q", out)
+			}
+			continue
+		}
+		if out != c.expect {
+			t.Fatalf("%s %q: expected %q; got %q", c.name, c.args, c.expect, out)
+		}
+	}
+}
+
+// Test that discard never creates a database file that does not exist.
+func TestCommands_DiscardMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.db")
+	if _, err := runCommand(t, path, "discard", "test"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected %q; got %v", os.ErrNotExist, err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected the database file not to be created; got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package diffdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// A Codec encodes the payloads of objects in place of msgpack, such as protobuf for generated message types.
+// Encoded payloads are hashed directly, so Marshal must be deterministic.
+type Codec interface {
+	// Name identifies the codec in stored payloads so that they can be decoded by any process that registered it.
+	// It must be unique and at most 255 bytes long.
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+// RegisterCodec makes c available to decode payloads that were encoded using a codec of the same name.
+// Packages providing a Codec typically register it in an init function.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+// SetCodec encodes the payloads of objects added from now on using c instead of msgpack, and registers c.
+// Objects added using AddRaw are not affected.
+// Changing the codec of a differential changes the hash of every object, so they will all be treated as changed.
+func (diff *Differential) SetCodec(c Codec) error {
+	if len(c.Name()) > 255 {
+		return errors.New("diffdb: codec name is too long")
+	}
+	RegisterCodec(c)
+	diff.codec = c
+	return nil
+}
+
+// tagCodecPayload prefixes data with the tag and name of the codec used to encode it.
+func tagCodecPayload(name string, data []byte) []byte {
+	out := make([]byte, 3+len(name)+len(data))
+	out[0], out[1], out[2] = compressedMarker, encodingCodec, byte(len(name))
+	copy(out[3:], name)
+	copy(out[3+len(name):], data)
+	return out
+}
+
+// decodeCodec decodes an untagged codec payload into x using the registered codec named in it.
+func decodeCodec(content []byte, x interface{}) error {
+	if len(content) < 1 || len(content) < 1+int(content[0]) {
+		return errors.New("diffdb: truncated codec payload")
+	}
+	name := string(content[1 : 1+content[0]])
+
+	codecsMu.RLock()
+	c, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("diffdb: payload encoded with unregistered codec %q", name)
+	}
+	return c.Unmarshal(content[1+content[0]:], x)
+}
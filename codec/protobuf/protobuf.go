@@ -0,0 +1,45 @@
+// Package protobuf provides a diffdb Codec that stores objects generated by protoc as protobuf.
+//
+// Messages are marshaled deterministically so that the same message always produces the same payload and hash.
+// Importing the package registers the codec, so payloads can be decoded by any process that imports it:
+//
+//	diff.SetCodec(protobuf.Codec)
+package protobuf
+
+import (
+	"fmt"
+
+	"github.com/relvacode/diffdb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes objects that implement proto.Message.
+var Codec diffdb.Codec = codec{}
+
+func init() {
+	diffdb.RegisterCodec(Codec)
+}
+
+var marshalOptions = proto.MarshalOptions{Deterministic: true}
+
+type codec struct{}
+
+func (codec) Name() string {
+	return "protobuf"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T does not implement proto.Message", v)
+	}
+	return marshalOptions.Marshal(m)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T does not implement proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
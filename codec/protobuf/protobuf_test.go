@@ -0,0 +1,64 @@
+package protobuf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/relvacode/diffdb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// row is a generated message with an ID, as it would be declared next to the generated code.
+type row struct {
+	*structpb.Struct
+}
+
+func (r row) ID() []byte {
+	return []byte(r.Fields["id"].GetStringValue())
+}
+
+func newRow(t *testing.T, fields map[string]interface{}) row {
+	s, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row{s}
+}
+
+func TestCodec(t *testing.T) {
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.SetCodec(Codec); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string]interface{}{"id": "1", "a": 1, "b": "two", "c": true}
+	if _, err := diff.Add(newRow(t, fields)); err != nil {
+		t.Fatal(err)
+	}
+	// Map fields are marshaled deterministically, so the same message is always unchanged
+	for i := 0; i < 10; i++ {
+		if updated, err := diff.Add(newRow(t, fields)); err != nil || updated {
+			t.Fatalf("Expected an identical message to be unchanged; got %v, %v", updated, err)
+		}
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data diffdb.Decoder) error {
+		var s structpb.Struct
+		if err := data.Decode(&s); err != nil {
+			return err
+		}
+		if s.Fields["b"].GetStringValue() != "two" {
+			t.Fatalf("Expected the message to round trip; got %v", s.AsMap())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
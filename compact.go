@@ -0,0 +1,28 @@
+package diffdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Compactor is a Backend that can rewrite its storage to reclaim unused space.
+type Compactor interface {
+	Compact() error
+}
+
+// Compact rewrites the database into a new file to reclaim the space left behind by large deletions,
+// such as DiscardPending or Delete, then atomically replaces the original file.
+// Writes and new transactions wait until compaction has finished.
+// Compact must not be called while the calling goroutine holds an open transaction, otherwise it will deadlock.
+//
+// The backend must implement Compactor, otherwise an error wrapping errors.ErrUnsupported is returned.
+func (db *DB) Compact() error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	c, ok := db.backend.(Compactor)
+	if !ok {
+		return fmt.Errorf("diffdb: backend does not support compaction: %w", errors.ErrUnsupported)
+	}
+	return c.Compact()
+}
@@ -0,0 +1,177 @@
+package diffdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDB_Compact(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.db")
+	db, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objs := make([]Object, 0, 10000)
+	for i := 0; i < cap(objs); i++ {
+		objs = append(objs, NewIDObject([]byte(strconv.Itoa(i)), strconv.Itoa(i)))
+	}
+	if _, err := diff.AddBatch(objs); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.DiscardPending(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("keep"), "keep")); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Fatalf("Expected the file to shrink from %d bytes; got %d", before.Size(), after.Size())
+	}
+
+	// The database remains usable after it has been swapped
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected 1 pending change; got %d", pending)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("new"), "new")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test that a write transaction that is open when compaction starts is not lost.
+func TestDB_Compact_OpenTx(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewWithOptions(filepath.Join(dir, "state.db"), &NewOptions{MaxBatchSize: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.AddTx(tx, NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	compacted := make(chan error, 1)
+	go func() {
+		compacted <- db.Compact()
+	}()
+	select {
+	case err := <-compacted:
+		t.Fatalf("Expected compaction to wait for the open transaction; got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-compacted; err != nil {
+		t.Fatal(err)
+	}
+
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected the change committed during compaction to be kept; got %d pending changes", pending)
+	}
+	if size := db.backend.(*boltBackend).db.MaxBatchSize; size != 7 {
+		t.Fatalf("Expected the options to be kept after compaction; got a max batch size of %d", size)
+	}
+}
+
+func TestDB_Compact_Unsupported(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	if err := db.Compact(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Expected %q; got %v", errors.ErrUnsupported, err)
+	}
+}
+
+// Test that a goroutine holding a transaction can begin another while compaction is waiting for it.
+func TestDB_Compact_NestedTx(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := New(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tx, err := db.begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compacted := make(chan error, 1)
+	go func() {
+		compacted <- db.Compact()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	nested := make(chan error, 1)
+	go func() {
+		nested <- db.view(func(tx Tx) error {
+			return nil
+		})
+	}()
+	select {
+	case err := <-nested:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a nested transaction not to wait for compaction")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-compacted; err != nil {
+		t.Fatal(err)
+	}
+}
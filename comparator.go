@@ -0,0 +1,29 @@
+package diffdb
+
+// A Comparator reports whether the encoded payloads of two versions of the same ID are equivalent.
+// stored is the most recently applied or pending payload and incoming is the payload of the object being added.
+type Comparator func(stored, incoming []byte) bool
+
+// SetComparator sets a comparator that is consulted whenever the hash of an added object differs from its
+// committed or pending hash, allowing domain-specific equivalence such as ignoring float jitter or whitespace.
+// If f reports the payloads as equivalent then the object is treated as unchanged and the stored version is kept,
+// so small differences cannot accumulate unnoticed across successive adds.
+//
+// Committed payloads are only available to f if RetainPayloads is enabled.
+// Setting f to nil restores strict hash equality.
+func (diff *Differential) SetComparator(f Comparator) {
+	diff.comparator = f
+}
+
+// equivalent returns true if the comparator considers the stored payload equivalent to raw.
+// A missing payload is never equivalent.
+func (diff *Differential) equivalent(stored, raw []byte) (bool, error) {
+	if stored == nil {
+		return false, nil
+	}
+	stored, err := decompress(stored)
+	if err != nil {
+		return false, err
+	}
+	return diff.comparator(stored, raw), nil
+}
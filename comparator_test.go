@@ -0,0 +1,50 @@
+package diffdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+func TestDifferential_SetComparator(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compare values ignoring surrounding whitespace
+	diff.SetComparator(func(stored, incoming []byte) bool {
+		var a, b struct{ Object string }
+		if msgpack.Unmarshal(stored, &a) != nil || msgpack.Unmarshal(incoming, &b) != nil {
+			return false
+		}
+		return strings.TrimSpace(a.Object) == strings.TrimSpace(b.Object)
+	})
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil {
+		t.Fatal(err)
+	}
+	if updated, err := diff.Add(NewIDObject([]byte("1"), "value ")); err != nil || updated {
+		t.Fatalf("Expected an equivalent pending object to be unchanged; got %v, %v", updated, err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if updated, err := diff.Add(NewIDObject([]byte("1"), " value")); err != nil || updated {
+		t.Fatalf("Expected an equivalent committed object to be unchanged; got %v, %v", updated, err)
+	}
+	if updated, err := diff.Add(NewIDObject([]byte("1"), "other")); err != nil || !updated {
+		t.Fatalf("Expected a different object to be changed; got %v, %v", updated, err)
+	}
+}
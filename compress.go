@@ -0,0 +1,90 @@
+package diffdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// A Compression is an algorithm used to compress stored payloads.
+type Compression uint8
+
+const (
+	// CompressionNone stores payloads uncompressed.
+	CompressionNone Compression = iota
+	// CompressionSnappy favours speed over compression ratio.
+	CompressionSnappy
+	// CompressionZstd favours compression ratio over speed.
+	CompressionZstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionSnappy:
+		return "snappy"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Compression(%d)", uint8(c))
+	}
+}
+
+// compressedMarker prefixes compressed payloads.
+// It is a byte that never appears in msgpack, so compressed and uncompressed payloads can be stored side by side.
+const compressedMarker = 0xc1
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// CompressPayloads compresses payloads of objects added from now on using c.
+// Payloads are decompressed transparently when decoded, including those stored before compression was enabled,
+// so the compression can be changed at any time. CompressionNone disables compression of new payloads.
+func (diff *Differential) CompressPayloads(c Compression) error {
+	if c > CompressionZstd {
+		return fmt.Errorf("diffdb: unknown compression %s", c)
+	}
+	diff.compression = c
+	return nil
+}
+
+// compress compresses data using c.
+// If compression does not reduce the size of data then data is returned unchanged.
+func compress(c Compression, data []byte) []byte {
+	var out []byte
+	switch c {
+	case CompressionSnappy:
+		out = make([]byte, 2+snappy.MaxEncodedLen(len(data)))
+		out = out[:2+len(snappy.Encode(out[2:], data))]
+	case CompressionZstd:
+		out = zstdEncoder.EncodeAll(data, make([]byte, 2, 2+len(data)))
+	default:
+		return data
+	}
+	if len(out) >= len(data) {
+		return data
+	}
+	out[0], out[1] = compressedMarker, byte(c)
+	return out
+}
+
+// decompress returns the uncompressed contents of a payload stored by compress.
+// Uncompressed payloads with an encoding tag are returned unchanged.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != compressedMarker || data[1] >= encodingRaw {
+		return data, nil
+	}
+	switch Compression(data[1]) {
+	case CompressionSnappy:
+		return snappy.Decode(nil, data[2:])
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(data[2:], nil)
+	default:
+		return nil, errors.New("diffdb: payload compressed with an unknown algorithm")
+	}
+}
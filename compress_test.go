@@ -0,0 +1,73 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDifferential_CompressPayloads(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large := strings.Repeat("payload ", 100)
+	if _, err := diff.Add(NewIDObject([]byte("plain"), large)); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []Compression{CompressionSnappy, CompressionZstd} {
+		if err := diff.CompressPayloads(c); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := diff.Add(NewIDObject([]byte(c.String()), large+strings.Repeat("!", i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := diff.CompressPayloads(Compression(10)); err == nil {
+		t.Fatal("Expected an error for an unknown compression")
+	}
+
+	// Payloads are stored compressed
+	err = db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		data := b.Bucket(bucketPendingData).Get([]byte("zstd"))
+		if data[0] != compressedMarker || len(data) >= len(large) {
+			t.Fatalf("Expected a compressed payload; got %d bytes", len(data))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen int
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var x struct{ Object string }
+		if err := data.Decode(&x); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(x.Object, large) {
+			t.Fatalf("Unexpected payload of %s", id)
+		}
+		seen++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen != 3 {
+		t.Fatalf("Expected 3 changes; got %d", seen)
+	}
+}
+
+func TestCompress_Small(t *testing.T) {
+	data := []byte{0x01}
+	if out := compress(CompressionZstd, data); !bytes.Equal(out, data) {
+		t.Fatalf("Expected incompressible data to be stored as is; got %x", out)
+	}
+}
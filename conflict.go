@@ -0,0 +1,113 @@
+package diffdb
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// A ConflictPolicy decides what happens when an ID is added more than once while MustNotConflict is enabled.
+type ConflictPolicy int
+
+const (
+	// ConflictFail fails the conflicting add with a *ConflictError, which matches ErrConflictingKey.
+	ConflictFail ConflictPolicy = iota
+	// ConflictKeepFirst ignores the conflicting add and keeps the version that was added first.
+	ConflictKeepFirst
+	// ConflictKeepLast adds the conflicting version in place of the version that was added first.
+	ConflictKeepLast
+	// ConflictMerge adds the object returned by a ConflictMergeFunc.
+	ConflictMerge
+)
+
+// A ConflictMergeFunc combines the pending version of a conflicting ID with the object being added.
+// The returned object is added in place of both and must have the same ID.
+type ConflictMergeFunc func(id []byte, pending Decoder, incoming Object) (Object, error)
+
+// SetConflictPolicy sets how IDs that are added more than once are handled once MustNotConflict is enabled.
+// merge is only used by ConflictMerge, which requires it.
+// Conflicts are counted and reported by Conflicts regardless of the policy.
+func (diff *Differential) SetConflictPolicy(policy ConflictPolicy, merge ConflictMergeFunc) error {
+	if policy == ConflictMerge && merge == nil {
+		return errors.New("diffdb: ConflictMerge requires a merge function")
+	}
+	diff.conflictPolicy = policy
+	diff.conflictMerge = merge
+	return nil
+}
+
+// resolveConflict returns the object that should be added in place of obj after a conflict on its ID,
+// or nil if the add should be ignored.
+func (diff *Differential) resolveConflict(b Bucket, id []byte, obj Object) (Object, error) {
+	switch diff.conflictPolicy {
+	case ConflictKeepFirst:
+		return nil, nil
+	case ConflictKeepLast:
+		return obj, nil
+	case ConflictMerge:
+		data := b.Bucket(bucketPendingData).Get(id)
+		if data == nil {
+			// The first version is no longer pending, so there is nothing to merge with
+			return obj, nil
+		}
+		merged, err := diff.conflictMerge(id, &msgpackDecoder{data: data}, obj)
+		if err != nil {
+			return nil, err
+		}
+		if string(merged.ID()) != string(id) {
+			return nil, fmt.Errorf("diffdb: merge of conflicting ID %q returned an object with ID %q", id, merged.ID())
+		}
+		return merged, nil
+	default:
+		return nil, &ConflictError{Differential: diff.Name(), ID: copyBytes(id)}
+	}
+}
+
+// A ConflictReport describes an ID that was added more than once while MustNotConflict was enabled.
+type ConflictReport struct {
+	ID []byte
+	// Count is the number of times the ID was added, including the first time.
+	Count int
+}
+
+// Conflicts returns a report of every conflicting ID seen since MustNotConflict was last called, ordered by ID.
+// Conflicts are counted by the Differential that saw them even though the conflicting add itself fails,
+// so they are not shared between processes or kept when the database is reopened.
+func (diff *Differential) Conflicts() ([]ConflictReport, error) {
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+
+	reports := make([]ConflictReport, 0, len(diff.conflicts))
+	for id, n := range diff.conflicts {
+		reports = append(reports, ConflictReport{
+			ID:    []byte(id),
+			Count: n + 1,
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return string(reports[i].ID) < string(reports[j].ID)
+	})
+	return reports, nil
+}
+
+// recordConflict logs and counts a conflicting add of id.
+func (diff *Differential) recordConflict(id []byte) {
+	diff.log(slog.LevelWarn, "conflicting id", logID(id))
+
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+
+	if diff.conflicts == nil {
+		diff.conflicts = make(map[string]int)
+	}
+	diff.conflicts[string(id)]++
+}
+
+// resetConflicts removes all counted conflicts.
+func (diff *Differential) resetConflicts() {
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+
+	diff.conflicts = nil
+}
@@ -0,0 +1,159 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDifferential_Conflicts(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := diff.MustNotConflict(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"2", "1", "2", "2", "1", "3"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil && !errors.Is(err, ErrConflictingKey) {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := diff.Conflicts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 conflicting IDs; got %+v", reports)
+	}
+	for i, expect := range []ConflictReport{{[]byte("1"), 2}, {[]byte("2"), 3}} {
+		if string(reports[i].ID) != string(expect.ID) || reports[i].Count != expect.Count {
+			t.Fatalf("Expected %+v; got %+v", expect, reports[i])
+		}
+	}
+
+	if err := diff.MustNotConflict(); err != nil {
+		t.Fatal(err)
+	}
+	if reports, _ := diff.Conflicts(); len(reports) != 0 {
+		t.Fatalf("Expected conflicts to be reset; got %+v", reports)
+	}
+}
+
+type conflictRow struct {
+	Key   string
+	Total int
+}
+
+func (r conflictRow) ID() []byte {
+	return []byte(r.Key)
+}
+
+func TestDifferential_SetConflictPolicy(t *testing.T) {
+	sum := func(id []byte, pending Decoder, incoming Object) (Object, error) {
+		var r conflictRow
+		if err := pending.Decode(&r); err != nil {
+			return nil, err
+		}
+		r.Total += incoming.(conflictRow).Total
+		return r, nil
+	}
+
+	for _, c := range []struct {
+		name   string
+		policy ConflictPolicy
+		merge  ConflictMergeFunc
+		expect int
+	}{
+		{"KeepFirst", ConflictKeepFirst, nil, 1},
+		{"KeepLast", ConflictKeepLast, nil, 3},
+		{"Merge", ConflictMerge, sum, 6},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			db := NewMemory()
+			defer db.Close()
+
+			diff, err := db.Open("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := diff.MustNotConflict(); err != nil {
+				t.Fatal(err)
+			}
+			if err := diff.SetConflictPolicy(c.policy, c.merge); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, total := range []int{1, 2, 3} {
+				if _, err := diff.Add(conflictRow{"a", total}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var r conflictRow
+			if pending, err := diff.GetPending([]byte("a"), &r); err != nil || !pending {
+				t.Fatalf("Expected a pending change; got %v, %v", pending, err)
+			}
+			if r.Total != c.expect {
+				t.Fatalf("Expected total %d; got %d", c.expect, r.Total)
+			}
+			if reports, _ := diff.Conflicts(); len(reports) != 1 || reports[0].Count != 3 {
+				t.Fatalf("Expected 1 ID added 3 times; got %+v", reports)
+			}
+		})
+	}
+}
+
+func TestDifferential_SetConflictPolicy_KeepLastCommitted(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(conflictRow{"a", 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := diff.MustNotConflict(); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.SetConflictPolicy(ConflictKeepLast, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, total := range []int{2, 1} {
+		if _, err := diff.Add(conflictRow{"a", total}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The last version is the committed version, so nothing is left to apply
+	if pending := diff.CountChanges(); pending != 0 {
+		t.Fatalf("Expected no pending changes; got %d", pending)
+	}
+}
+
+func TestDifferential_SetConflictPolicy_MergeRequiresFunc(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.SetConflictPolicy(ConflictMerge, nil); err == nil {
+		t.Fatal("Expected an error without a merge function")
+	}
+}
@@ -0,0 +1,232 @@
+package diffdb
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// consumeBatch is the maximum number of changes Consume claims in a single transaction.
+	consumeBatch = 100
+	// consumePoll is how often an idle Consume checks for delayed changes or expired leases.
+	// A lease shorter than consumePoll is checked as often as the lease.
+	consumePoll = time.Second
+)
+
+// AckFunc acknowledges a change delivered by Consume.
+//
+// If err is nil the change is acknowledged and promoted to the committed state as if by Confirm.
+// Otherwise the change is negatively acknowledged: it stays pending, err is recorded as a failed attempt,
+// and it is delivered again once delay has elapsed.
+type AckFunc func(id []byte, err error, delay time.Duration) error
+
+// ConsumeOpts controls how ConsumeWith delivers pending changes.
+type ConsumeOpts struct {
+	// Lease is how long a delivered change stays in-flight without being acknowledged.
+	// Once the lease expires the change can be delivered again, so that changes delivered to a consumer
+	// that crashed are not stranded. The change can still be acknowledged by the original consumer until then.
+	// If Lease is <= 0 then delivered changes stay in-flight until they are acknowledged.
+	Lease time.Duration
+}
+
+// Consume delivers pending changes on the returned channel with queue-style semantics for asynchronous
+// downstream writers. Each delivered change is marked as in-flight and is not delivered again until it has been
+// acknowledged using the returned AckFunc. New changes are delivered as they are added.
+//
+// The channel is closed when ctx is cancelled, at which point every delivered change that has not been
+// acknowledged is returned to the pending queue, so delivery is at-least-once.
+// Changes left in-flight by a consumer that crashed can be found using InFlight and recovered using Abort,
+// or recovered automatically by using ConsumeWith with a lease.
+func (diff *Differential) Consume(ctx context.Context) (<-chan Change, AckFunc) {
+	return diff.ConsumeWith(ctx, ConsumeOpts{})
+}
+
+// ConsumeWith delivers pending changes like Consume using the given options.
+func (diff *Differential) ConsumeWith(ctx context.Context, opts ConsumeOpts) (<-chan Change, AckFunc) {
+	c := &consumer{
+		diff:        diff,
+		lease:       opts.Lease,
+		outstanding: make(map[string]struct{}),
+	}
+	ch := make(chan Change)
+	go c.run(ctx, ch)
+	return ch, c.ack
+}
+
+// consumer tracks the changes delivered by Consume that have not been acknowledged.
+type consumer struct {
+	diff  *Differential
+	lease time.Duration
+
+	mu          sync.Mutex
+	outstanding map[string]struct{}
+}
+
+func (c *consumer) run(ctx context.Context, ch chan<- Change) {
+	defer close(ch)
+	defer c.abort()
+
+	interval := consumePoll
+	if c.lease > 0 && c.lease < interval {
+		interval = c.lease
+	}
+
+	events := c.diff.Watch(ctx)
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+
+	for ctx.Err() == nil {
+		changes, err := c.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			c.diff.log(slog.LevelError, "consume failed", slog.String("error", err.Error()))
+		}
+
+		for _, change := range changes {
+			select {
+			case ch <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if len(changes) == consumeBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-events:
+		case <-poll.C:
+		}
+	}
+}
+
+// claim marks up to consumeBatch available pending changes as in-flight and returns them.
+func (c *consumer) claim(ctx context.Context) ([]Change, error) {
+	var changes []Change
+	err := c.diff.db.updateContext(ctx, func(tx Tx) error {
+		b := tx.Bucket(c.diff.q)
+		var (
+			bph = b.Bucket(bucketPendingHashes)
+			bpd = b.Bucket(bucketPendingData)
+			now = time.Now()
+		)
+
+		cur := bph.Cursor()
+		for id, hash := cur.First(); id != nil && len(changes) < consumeBatch; id, hash = cur.Next() {
+			if inFlight(b, id) || delayed(b, id, now) {
+				continue
+			}
+
+			data := bpd.Get(id)
+			if data == nil {
+				return missingPayload(c.diff.Name(), id)
+			}
+			if err := markInFlight(b, id, hash, c.lease); err != nil {
+				return err
+			}
+			changes = append(changes, Change{
+				ID:   append([]byte(nil), id...),
+				Hash: append([]byte(nil), hash...),
+				Data: &msgpackDecoder{
+					data:   append([]byte(nil), data...),
+					meta:   copyBytes(changeMetadata(b, id)),
+					staged: stagedAt(b, id),
+				},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	for _, change := range changes {
+		c.outstanding[string(change.ID)] = struct{}{}
+	}
+	c.mu.Unlock()
+	return changes, nil
+}
+
+func (c *consumer) ack(id []byte, cause error, delay time.Duration) error {
+	var err error
+	if cause == nil {
+		err = c.diff.Confirm([][]byte{id})
+	} else {
+		err = c.diff.nack(id, cause, delay)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.outstanding, string(id))
+	c.mu.Unlock()
+	return nil
+}
+
+// abort returns all changes that were delivered but not acknowledged to the pending queue.
+func (c *consumer) abort() {
+	c.mu.Lock()
+	ids := make([][]byte, 0, len(c.outstanding))
+	for id := range c.outstanding {
+		ids = append(ids, []byte(id))
+	}
+	c.outstanding = make(map[string]struct{})
+	c.mu.Unlock()
+
+	if err := c.diff.Abort(ids); err != nil {
+		c.diff.log(slog.LevelError, "consume failed", slog.String("error", err.Error()))
+	}
+}
+
+// nack records a failed attempt to apply the in-flight change of id and returns it to the pending queue,
+// delaying its next delivery by Consume until delay has elapsed.
+func (diff *Differential) nack(id []byte, cause error, delay time.Duration) error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		if !inFlight(b, id) {
+			return nil
+		}
+		if err := clearInFlight(b, id); err != nil {
+			return err
+		}
+
+		hash := b.Bucket(bucketPendingHashes).Get(id)
+		data := b.Bucket(bucketPendingData).Get(id)
+		if hash == nil || data == nil {
+			return nil
+		}
+		if delay > 0 {
+			bdy, err := b.CreateBucketIfNotExists(bucketDelayed)
+			if err != nil {
+				return err
+			}
+			if err := bdy.Put(id, encodeTime(time.Now().Add(delay))); err != nil {
+				return err
+			}
+		}
+		return diff.failChange(tx, b, id, hash, data, cause)
+	})
+}
+
+// delayed returns true if the next delivery of id by Consume has been delayed beyond now.
+func delayed(b Bucket, id []byte, now time.Time) bool {
+	bdy := b.Bucket(bucketDelayed)
+	if bdy == nil {
+		return false
+	}
+	v := bdy.Get(id)
+	return v != nil && now.Before(decodeTime(v))
+}
+
+// clearDelay removes any delay of the next delivery of id.
+func clearDelay(b Bucket, id []byte) error {
+	bdy := b.Bucket(bucketDelayed)
+	if bdy == nil {
+		return nil
+	}
+	return bdy.Delete(id)
+}
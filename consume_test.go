@@ -0,0 +1,123 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDifferential_Consume(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, ack := diff.Consume(ctx)
+
+	receive := func() string {
+		select {
+		case c := <-changes:
+			var x struct{ Object string }
+			if err := c.Data.Decode(&x); err != nil {
+				t.Fatal(err)
+			}
+			return string(c.ID)
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for a change")
+			return ""
+		}
+	}
+
+	for _, want := range []string{"1", "2", "3"} {
+		if id := receive(); id != want {
+			t.Fatalf("Expected %s; got %s", want, id)
+		}
+	}
+
+	errApply := errors.New("apply failed")
+	if err := ack([]byte("1"), nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ack([]byte("2"), errApply, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := ack([]byte("3"), errApply, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 is redelivered straight away, 2 is delayed
+	if id := receive(); id != "3" {
+		t.Fatalf("Expected 3 to be redelivered; got %s", id)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("4"), "4")); err != nil {
+		t.Fatal(err)
+	}
+	if id := receive(); id != "4" {
+		t.Fatalf("Expected 4 to be delivered once added; got %s", id)
+	}
+
+	cancel()
+	for range changes {
+	}
+
+	if ids, err := diff.InFlight(); err != nil || len(ids) != 0 {
+		t.Fatalf("Expected unacknowledged changes to be returned to the pending queue; got %q, %v", ids, err)
+	}
+	if tracked, pending := diff.CountTracking(), diff.CountChanges(); tracked != 1 || pending != 3 {
+		t.Fatalf("Expected 1 committed and 3 pending changes; got %d and %d", tracked, pending)
+	}
+	failed, err := diff.FailedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("Expected 2 failed changes; got %d", len(failed))
+	}
+}
+
+func TestDifferential_ConsumeWith_Lease(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, ack := diff.ConsumeWith(ctx, ConsumeOpts{Lease: 20 * time.Millisecond})
+
+	// The change is delivered again once the lease expires without an acknowledgement
+	for i := 0; i < 2; i++ {
+		select {
+		case c := <-changes:
+			if string(c.ID) != "1" {
+				t.Fatalf("Unexpected change %s", c.ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for delivery %d", i+1)
+		}
+	}
+
+	if err := ack([]byte("1"), nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if tracked := diff.CountTracking(); tracked != 1 {
+		t.Fatalf("Expected the change to be committed; got %d", tracked)
+	}
+}
@@ -0,0 +1,80 @@
+package diffdb
+
+import (
+	"fmt"
+)
+
+// Rename moves the differential named old to new in a single transaction.
+// It returns an error if old does not exist or new already exists.
+// Differentials opened using the old name must be opened again using the new name.
+func (db *DB) Rename(old, new string) error {
+	return db.update(func(tx Tx) error {
+		if err := copyDifferential(tx, old, new); err != nil {
+			return err
+		}
+		tx.OnCommit(func() {
+			db.handles.Delete(old)
+		})
+		return tx.DeleteBucket([]byte(old))
+	})
+}
+
+// Clone deep-copies every bucket of the differential named src into a new differential named dst,
+// so that the copy can be modified without affecting the original.
+// It returns an error if src does not exist or dst already exists.
+func (db *DB) Clone(src, dst string) error {
+	return db.update(func(tx Tx) error {
+		return copyDifferential(tx, src, dst)
+	})
+}
+
+// copyDifferential deep-copies the differential src into a new top-level bucket named dst.
+func copyDifferential(tx Tx, src, dst string) error {
+	sb := tx.Bucket([]byte(src))
+	if sb == nil {
+		return fmt.Errorf("diffdb: differential %q does not exist", src)
+	}
+	if tx.Bucket([]byte(dst)) != nil {
+		return fmt.Errorf("diffdb: differential %q already exists", dst)
+	}
+
+	db, err := tx.CreateBucket([]byte(dst))
+	if err != nil {
+		return err
+	}
+	return copyBucket(db, sb)
+}
+
+// copyBucket recursively copies all keys, nested buckets, and sequences of src into dst.
+func copyBucket(dst, src Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+
+	c := src.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			if err := dst.Put(k, v); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sb := src.Bucket(k)
+		if sb == nil {
+			// An empty value rather than a nested bucket
+			if err := dst.Put(k, v); err != nil {
+				return err
+			}
+			continue
+		}
+		nb, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		if err := copyBucket(nb, sb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
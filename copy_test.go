@@ -0,0 +1,105 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDB_Rename(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainHistory(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("2"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Open("taken"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Rename("missing", "new"); err == nil {
+		t.Fatal("Expected an error renaming a missing differential")
+	}
+	if err := db.Rename("old", "taken"); err == nil {
+		t.Fatal("Expected an error renaming to an existing differential")
+	}
+	if err := db.Rename("old", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := db.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "new" || names[1] != "taken" {
+		t.Fatalf("Expected [new taken]; got %v", names)
+	}
+
+	diff, err = db.Open("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracking, pending := diff.CountTracking(), diff.CountChanges(); tracking != 1 || pending != 1 {
+		t.Fatalf("Expected 1 tracked and 1 pending; got %d and %d", tracking, pending)
+	}
+	versions, err := diff.History([]byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("Expected history to be copied; got %d versions", len(versions))
+	}
+}
+
+func TestDB_Clone(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Clone("prod", "prod"); err == nil {
+		t.Fatal("Expected an error cloning to an existing differential")
+	}
+	if err := db.Clone("prod", "staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := db.Open("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = staging.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pending := staging.CountChanges(); pending != 0 {
+		t.Fatalf("Expected the clone to have no pending changes; got %d", pending)
+	}
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected the original to be unchanged; got %d pending", pending)
+	}
+}
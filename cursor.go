@@ -0,0 +1,115 @@
+package diffdb
+
+import (
+	"bytes"
+)
+
+var infoCursor = []byte("cursor")
+
+// ResumeEach makes apply runs started by EachWith, and so Each, EachN and EachFor, resume after the last
+// pending change visited by the previous run if that run stopped early, instead of starting again from the first
+// pending change. This avoids repeating the side effects of changes that failed before an interrupted run stopped.
+//
+// A resumed run continues to the end of the pending changes and then wraps around to the start,
+// so every pending change is still visited once per complete run.
+// The position is stored in the differential and is saved whenever the run commits.
+func (diff *Differential) ResumeEach() {
+	diff.resume = true
+}
+
+// resumeCursor iterates over a bucket starting after the key start,
+// wrapping around to the first key once the end is reached and stopping once it passes start again.
+type resumeCursor struct {
+	c       Cursor
+	start   []byte
+	wrapped bool
+
+	// after is set by reset to reposition the next call to Next after this key
+	after []byte
+}
+
+// A positionCursor is a Cursor that visits changes in an order other than ID order, such as the staging order.
+// Its Seek accepts the position of a change in that order as returned by position, instead of an ID.
+type positionCursor interface {
+	Cursor
+	// position returns the position of the most recently returned change.
+	position() []byte
+}
+
+// positionOf returns the position to pass to Seek of id, the most recently returned key of c.
+func positionOf(c Cursor, id []byte) []byte {
+	if p, ok := c.(positionCursor); ok {
+		return p.position()
+	}
+	return id
+}
+
+// newResumeCursor returns a cursor over c resuming after start.
+// If start is nil then the cursor visits every key once from the first.
+func newResumeCursor(c Cursor, start []byte) *resumeCursor {
+	return &resumeCursor{
+		c:       c,
+		start:   start,
+		wrapped: start == nil,
+	}
+}
+
+func (r *resumeCursor) First() ([]byte, []byte) {
+	return r.check(seekAfter(r.c, r.start))
+}
+
+func (r *resumeCursor) Next() ([]byte, []byte) {
+	if r.after != nil {
+		k, v := seekAfter(r.c, r.after)
+		r.after = nil
+		return r.check(k, v)
+	}
+	return r.check(r.c.Next())
+}
+
+// position returns the position of id, the most recently returned key, which can be given to reset or invalidate.
+func (r *resumeCursor) position(id []byte) []byte {
+	return positionOf(r.c, id)
+}
+
+// reset replaces the underlying cursor, such as after its transaction was committed,
+// so that the next call to Next returns the key after the position last.
+func (r *resumeCursor) reset(c Cursor, last []byte) {
+	r.c = c
+	r.invalidate(last)
+}
+
+// invalidate makes the next call to Next seek to the key after last instead of moving the cursor,
+// as deleting keys of a modified BoltDB bucket while it is being iterated makes the cursor skip the key that follows.
+func (r *resumeCursor) invalidate(last []byte) {
+	r.after = last
+}
+
+func (r *resumeCursor) check(k, v []byte) ([]byte, []byte) {
+	if k == nil && !r.wrapped {
+		r.wrapped = true
+		k, v = r.c.First()
+	}
+	if r.wrapped && r.start != nil && k != nil && bytes.Compare(k, r.start) > 0 {
+		return nil, nil
+	}
+	return k, v
+}
+
+// loadCursor returns the saved position of the last apply run of b, or nil if the last run completed.
+func loadCursor(b Bucket) []byte {
+	if v := b.Bucket(bucketMetadata).Get(infoCursor); v != nil {
+		return append([]byte(nil), v...)
+	}
+	return nil
+}
+
+// saveCursor saves last as the position to resume the next apply run of b from.
+// A nil position means that the run completed, so the next run starts from the first pending change.
+func saveCursor(b Bucket, last []byte) error {
+	bmd := b.Bucket(bucketMetadata)
+	if last == nil {
+		return bmd.Delete(infoCursor)
+	}
+	return bmd.Put(infoCursor, last)
+}
@@ -0,0 +1,63 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDifferential_ResumeEach(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff.ResumeEach()
+
+	for i := 0; i < 5; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var visited []string
+	visit := func(id []byte, data Decoder) error {
+		visited = append(visited, string(id))
+		if string(id) == "2" {
+			return errors.New("apply failed")
+		}
+		return nil
+	}
+
+	if err := diff.EachWith(context.Background(), visit, EachOpts{FailFast: true}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !reflect.DeepEqual(visited, []string{"0", "1", "2"}) {
+		t.Fatalf("Unexpected first run %v", visited)
+	}
+
+	// The next run continues after the failed change and wraps around to it
+	visited = nil
+	if err := diff.Each(context.Background(), visit); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !reflect.DeepEqual(visited, []string{"3", "4", "2"}) {
+		t.Fatalf("Expected the run to resume; got %v", visited)
+	}
+
+	// A completed run starts the next one from the first pending change
+	if _, err := diff.Add(NewIDObject([]byte("0"), -1)); err != nil {
+		t.Fatal(err)
+	}
+	visited = nil
+	if err := diff.Each(context.Background(), visit); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !reflect.DeepEqual(visited, []string{"0", "2"}) {
+		t.Fatalf("Expected the run to start from the first change; got %v", visited)
+	}
+}
@@ -0,0 +1,199 @@
+package diffdb
+
+import (
+	"bytes"
+	"log/slog"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// A DeadLetter is a pending change that was removed from the pending queue after repeatedly failing to apply.
+type DeadLetter struct {
+	ID      []byte `msgpack:"-"`
+	Hash    []byte
+	Payload []byte
+	// Attempts is the number of failed attempts before the change was dead-lettered.
+	Attempts  int
+	LastError string
+	// Time is when the change was dead-lettered.
+	Time time.Time
+
+	// StagedAt is when the change was staged, or the zero time if it is unknown.
+	StagedAt time.Time
+	// Metadata is the metadata attached to the change using WithMetadata.
+	Metadata Metadata
+	// Priority is the priority of the change set using WithPriority.
+	Priority uint64
+}
+
+// Decode decodes the payload of the dead-lettered change into x.
+func (dl DeadLetter) Decode(x interface{}) error {
+	return (&msgpackDecoder{data: dl.Payload}).Decode(x)
+}
+
+// DeadLetterAfter moves pending changes to a dead-letter bucket once they have failed to apply n times
+// instead of retrying them forever. Dead-lettered changes can be listed using DeadLetters
+// and returned to the pending queue using RequeueDeadLetter.
+// Setting n to zero disables dead-lettering.
+func (diff *Differential) DeadLetterAfter(n int) error {
+	if n < 0 {
+		n = 0
+	}
+	return diff.db.update(func(tx Tx) error {
+		tx.OnCommit(func() {
+			diff.maxAttempts = n
+		})
+
+		_, err := tx.Bucket(diff.q).CreateBucketIfNotExists(bucketDeadLetters)
+		return err
+	})
+}
+
+// DeadLetters returns all dead-lettered changes.
+func (diff *Differential) DeadLetters() ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := diff.db.view(func(tx Tx) error {
+		bdl := tx.Bucket(diff.q).Bucket(bucketDeadLetters)
+		if bdl == nil {
+			return nil
+		}
+
+		return bdl.ForEach(func(id, v []byte) error {
+			var dl DeadLetter
+			if err := msgpack.Unmarshal(v, &dl); err != nil {
+				return err
+			}
+			dl.ID = append([]byte(nil), id...)
+			letters = append(letters, dl)
+			return nil
+		})
+	})
+	return letters, err
+}
+
+// RequeueDeadLetter returns the dead-lettered change of id to the pending queue with a reset attempt counter.
+// The time the change was originally staged, its metadata and its priority are restored,
+// and the change counts towards the quota of the differential like an Add.
+// If a newer change for id has been added since, the dead-lettered change is dropped in favour of it.
+// ErrNotFound is returned if id is not dead-lettered.
+func (diff *Differential) RequeueDeadLetter(id []byte) error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		bdl := b.Bucket(bucketDeadLetters)
+		if bdl == nil {
+			return diff.wrapErr(id, ErrNotFound)
+		}
+		v := bdl.Get(id)
+		if v == nil {
+			return diff.wrapErr(id, ErrNotFound)
+		}
+
+		var dl DeadLetter
+		if err := msgpack.Unmarshal(v, &dl); err != nil {
+			return err
+		}
+		if err := bdl.Delete(id); err != nil {
+			return err
+		}
+
+		var (
+			bph       = b.Bucket(bucketPendingHashes)
+			committed = b.Bucket(bucketHashes).Get(id)
+		)
+		if bph.Get(id) != nil || bytes.Equal(committed, dl.Hash) {
+			return nil
+		}
+
+		if err := diff.reserve(b, id, dl.Payload); err != nil {
+			return diff.wrapErr(id, err)
+		}
+		if err := bph.Put(id, dl.Hash); err != nil {
+			return err
+		}
+		if err := b.Bucket(bucketPendingData).Put(id, dl.Payload); err != nil {
+			return err
+		}
+		if err := putChangeMetadata(b, id, dl.Metadata); err != nil {
+			return err
+		}
+		if err := putPriority(b, id, dl.Priority); err != nil {
+			return err
+		}
+		if !dl.StagedAt.IsZero() {
+			if err := putStagedAtTime(b, id, dl.StagedAt); err != nil {
+				return err
+			}
+		}
+		if stagingOrder(b) {
+			return putSequence(b, id)
+		}
+		return nil
+	})
+}
+
+// PurgeDeadLetter permanently removes the dead-lettered change of id.
+func (diff *Differential) PurgeDeadLetter(id []byte) error {
+	return diff.db.update(func(tx Tx) error {
+		bdl := tx.Bucket(diff.q).Bucket(bucketDeadLetters)
+		if bdl == nil {
+			return nil
+		}
+		return bdl.Delete(id)
+	})
+}
+
+// PurgeDeadLetters permanently removes all dead-lettered changes.
+func (diff *Differential) PurgeDeadLetters() error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		if b.Bucket(bucketDeadLetters) == nil {
+			return nil
+		}
+		if err := b.DeleteBucket(bucketDeadLetters); err != nil {
+			return err
+		}
+		_, err := b.CreateBucket(bucketDeadLetters)
+		return err
+	})
+}
+
+// deadLetter moves the pending change of id to the dead-letter bucket,
+// keeping its staged time, metadata and priority so that it can be restored by RequeueDeadLetter.
+func (diff *Differential) deadLetter(tx Tx, b Bucket, id, hash, data []byte, fc FailedChange) error {
+	var md Metadata
+	if v := changeMetadata(b, id); v != nil {
+		if err := msgpack.Unmarshal(v, &md); err != nil {
+			return err
+		}
+	}
+
+	raw, err := msgpack.Marshal(&DeadLetter{
+		Hash:      hash,
+		Payload:   data,
+		Attempts:  fc.Attempts,
+		LastError: fc.LastError,
+		Time:      fc.LastAttempt,
+		StagedAt:  stagedAt(b, id),
+		Metadata:  md,
+		Priority:  changePriority(b, id),
+	})
+	if err != nil {
+		return err
+	}
+
+	bdl, err := b.CreateBucketIfNotExists(bucketDeadLetters)
+	if err != nil {
+		return err
+	}
+	if err := bdl.Put(id, raw); err != nil {
+		return err
+	}
+
+	attrs := []any{logID(id), logHash(hash), slog.Int("attempts", fc.Attempts)}
+	tx.OnCommit(func() {
+		diff.log(slog.LevelError, "change dead-lettered", attrs...)
+	})
+
+	return diff.discardPending(tx, b, id)
+}
@@ -0,0 +1,144 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDifferential_DeadLetterAfter(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.DeadLetterAfter(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	fail := func(id []byte, data Decoder) error {
+		return errors.New("apply failed")
+	}
+	for i := 0; i < 2; i++ {
+		if err := diff.Each(context.Background(), fail); err == nil {
+			t.Fatal("Expected an apply error")
+		}
+	}
+
+	if pending := diff.CountChanges(); pending != 0 {
+		t.Fatalf("Expected change to be removed from pending; got %d", pending)
+	}
+
+	letters, err := diff.DeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || string(letters[0].ID) != "1" || letters[0].Attempts != 2 {
+		t.Fatalf("Unexpected dead letters %+v", letters)
+	}
+	var x struct{ Object int }
+	if err := letters[0].Decode(&x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Object != 1 {
+		t.Fatalf("Expected dead-lettered payload 1; got %d", x.Object)
+	}
+
+	if err := diff.RequeueDeadLetter([]byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected requeued change to be pending; got %d", pending)
+	}
+	if err := diff.RequeueDeadLetter([]byte("1")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %q requeueing twice; got %v", ErrNotFound, err)
+	}
+
+	if err := diff.Each(context.Background(), fail); err == nil {
+		t.Fatal("Expected an apply error")
+	}
+	if err := diff.Each(context.Background(), fail); err == nil {
+		t.Fatal("Expected an apply error")
+	}
+	if err := diff.PurgeDeadLetters(); err != nil {
+		t.Fatal(err)
+	}
+	letters, err = diff.DeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 0 {
+		t.Fatalf("Expected dead letters to be purged; got %d", len(letters))
+	}
+}
+
+// Test that a requeued change keeps the staged time, metadata and priority of the change that was dead-lettered.
+func TestDifferential_RequeueDeadLetter_ChangeInfo(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := WithPriority(WithMetadata(NewIDObject([]byte("1"), 1), Metadata{"source": "test"}), 5)
+	if _, err := diff.Add(obj); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := diff.PendingOlderThan(0)
+	if err != nil || len(staged) != 1 {
+		t.Fatalf("Expected 1 staged change; got %v, %v", staged, err)
+	}
+	if n, err := diff.EvictPendingOlderThan(0, EvictDeadLetter); err != nil || n != 1 {
+		t.Fatalf("Expected 1 evicted change; got %d, %v", n, err)
+	}
+
+	// The requeued change counts towards the quota
+	diff.SetQuota(Quota{MaxPending: 1})
+	if _, err := diff.Add(NewIDObject([]byte("2"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RequeueDeadLetter([]byte("1")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected %q; got %v", ErrQuotaExceeded, err)
+	}
+	if err := diff.DiscardPendingID([]byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	diff.SetQuota(Quota{})
+	if err := diff.RequeueDeadLetter([]byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	err = db.view(func(tx Tx) error {
+		if p := changePriority(tx.Bucket([]byte("test")), []byte("1")); p != 5 {
+			t.Errorf("Expected the priority to be restored; got %d", p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		if at, ok := StagedAt(data); !ok || !at.Equal(staged[0].StagedAt) {
+			t.Errorf("Expected the change to be staged at %s; got %s", staged[0].StagedAt, at)
+		}
+		md, err := MetadataOf(data)
+		if err != nil {
+			return err
+		}
+		if md["source"] != "test" {
+			t.Errorf("Expected the metadata to be restored; got %v", md)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
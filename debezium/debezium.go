@@ -0,0 +1,135 @@
+// Package debezium renders the change journal of a diffdb Differential as Debezium change event envelopes,
+// so that consumers built for Kafka Connect can ingest diffdb output without custom glue.
+package debezium
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/relvacode/diffdb"
+)
+
+// Connector is the name of the connector reported in the source block of each event.
+const Connector = "diffdb"
+
+// Debezium operation codes
+const (
+	OpCreate = "c"
+	OpUpdate = "u"
+	OpDelete = "d"
+)
+
+// Source describes the origin of a change event.
+type Source struct {
+	Version   string `json:"version"`
+	Connector string `json:"connector"`
+	// Name is the name of the differential.
+	Name string `json:"name"`
+	// TsMs is when the change was committed, in milliseconds since the Unix epoch.
+	TsMs int64 `json:"ts_ms"`
+	// Sequence is the journal sequence number of the change.
+	Sequence string `json:"sequence"`
+	// ID is the ID of the changed object.
+	ID string `json:"id"`
+}
+
+// An Envelope is the payload of a Debezium change event.
+type Envelope struct {
+	// Before is the state of the object before the change.
+	// It is only set for updates and deletes if the differential retains payloads.
+	Before interface{} `json:"before"`
+	// After is the state of the object after the change, or nil for deletes.
+	After  interface{} `json:"after"`
+	Source Source      `json:"source"`
+	Op     string      `json:"op"`
+	// TsMs is when the event was rendered, in milliseconds since the Unix epoch.
+	TsMs int64 `json:"ts_ms"`
+}
+
+// NewEnvelope renders a journal entry of the differential named name as a change event envelope.
+// Payloads are decoded into generic values, so objects are rendered using their msgpack field names.
+func NewEnvelope(name string, e diffdb.JournalEntry, tsMs int64) (*Envelope, error) {
+	env := &Envelope{
+		Source: Source{
+			Version:   "1",
+			Connector: Connector,
+			Name:      name,
+			TsMs:      e.Time.UnixNano() / 1e6,
+			Sequence:  fmt.Sprint(e.Seq),
+			ID:        string(e.ID),
+		},
+		TsMs: tsMs,
+	}
+
+	switch e.Kind {
+	case diffdb.ChangeCreate:
+		env.Op = OpCreate
+	case diffdb.ChangeUpdate:
+		env.Op = OpUpdate
+	case diffdb.ChangeDelete:
+		env.Op = OpDelete
+	default:
+		return nil, fmt.Errorf("debezium: unknown change kind %d", e.Kind)
+	}
+
+	if e.Payload != nil {
+		after, err := e.Data().DecodeJSON()
+		if err != nil {
+			return nil, err
+		}
+		env.After = json.RawMessage(after)
+	}
+	if e.PreviousPayload != nil {
+		before, err := e.PreviousData().DecodeJSON()
+		if err != nil {
+			return nil, err
+		}
+		env.Before = json.RawMessage(before)
+	}
+	return env, nil
+}
+
+// A Writer writes change event envelopes as newline delimited JSON.
+type Writer struct {
+	enc  *json.Encoder
+	name string
+	now  func() int64
+}
+
+// NewWriter creates a new Writer for the differential named name.
+func NewWriter(w io.Writer, name string) *Writer {
+	return &Writer{
+		enc:  json.NewEncoder(w),
+		name: name,
+		now:  func() int64 { return time.Now().UnixNano() / 1e6 },
+	}
+}
+
+// Write writes a single journal entry.
+func (w *Writer) Write(e diffdb.JournalEntry) error {
+	env, err := NewEnvelope(w.name, e, w.now())
+	if err != nil {
+		return err
+	}
+	return w.enc.Encode(env)
+}
+
+// Export writes every journal entry of diff after since to w, returning the sequence number of the last entry written.
+// If there are no new entries then since is returned.
+func Export(w io.Writer, diff *diffdb.Differential, since uint64) (uint64, error) {
+	entries, err := diff.Journal(since)
+	if err != nil {
+		return since, err
+	}
+
+	dw := NewWriter(w, diff.Name())
+	for _, e := range entries {
+		if err := dw.Write(e); err != nil {
+			return since, err
+		}
+		since = e.Seq
+	}
+	return since, nil
+}
@@ -0,0 +1,87 @@
+package debezium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/relvacode/diffdb"
+)
+
+type row struct {
+	Key   string
+	Value int
+}
+
+func (r row) ID() []byte {
+	return []byte(r.Key)
+}
+
+func TestExport(t *testing.T) {
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.EnableJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if _, err := diff.Add(row{Key: "a", Value: i}); err != nil {
+			t.Fatal(err)
+		}
+		err := diff.Each(context.Background(), func(id []byte, data diffdb.Decoder) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	last, err := Export(&buf, diff, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 2 {
+		t.Fatalf("Expected last sequence 2; got %d", last)
+	}
+
+	dec := json.NewDecoder(&buf)
+	var envs []map[string]interface{}
+	for dec.More() {
+		var env map[string]interface{}
+		if err := dec.Decode(&env); err != nil {
+			t.Fatal(err)
+		}
+		envs = append(envs, env)
+	}
+	if len(envs) != 2 {
+		t.Fatalf("Expected 2 events; got %d", len(envs))
+	}
+
+	if envs[0]["op"] != OpCreate || envs[0]["before"] != nil {
+		t.Fatalf("Unexpected create event %v", envs[0])
+	}
+	update := envs[1]
+	if update["op"] != OpUpdate {
+		t.Fatalf("Expected an update; got %v", update["op"])
+	}
+	if before := update["before"].(map[string]interface{}); before["Value"] != float64(1) {
+		t.Fatalf("Expected before value 1; got %v", before)
+	}
+	if after := update["after"].(map[string]interface{}); after["Value"] != float64(2) {
+		t.Fatalf("Expected after value 2; got %v", after)
+	}
+	source := update["source"].(map[string]interface{})
+	if source["connector"] != Connector || source["name"] != "rows" || source["sequence"] != "2" || source["id"] != "a" {
+		t.Fatalf("Unexpected source %v", source)
+	}
+}
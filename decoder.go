@@ -2,13 +2,30 @@ package diffdb
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"gopkg.in/vmihailenco/msgpack.v2"
 )
 
 // A Decoder decodes serialised byte data of a diff entry into a native object.
 // The object passed to Decode should be the same type added to the diff.
+// Objects that were stored using encoding.BinaryMarshaler must be decoded into an encoding.BinaryUnmarshaler.
 type Decoder interface {
 	Decode(interface{}) error
+
+	// Bytes returns the serialised object without decoding it, such as to forward it to a queue unchanged.
+	// It is msgpack unless the object was stored using AddRaw, encoding.BinaryMarshaler or a Codec.
+	// An error is returned if the payload cannot be read. The returned slice is only valid until ApplyFunc returns.
+	Bytes() ([]byte, error)
+
+	// DecodeJSON decodes the object without a concrete Go type and returns it encoded as JSON.
+	DecodeJSON() ([]byte, error)
+
+	// DecodeMap decodes the object without a concrete Go type into a map of its fields.
+	// An error is returned if the object was not encoded as a map, such as a struct, by msgpack.
+	DecodeMap() (map[string]interface{}, error)
 }
 
 var _ Decoder = (*msgpackDecoder)(nil)
@@ -16,9 +33,89 @@ var _ Decoder = (*msgpackDecoder)(nil)
 // msgpackDecoder uses the msgpack library to unmarshal differential data
 type msgpackDecoder struct {
 	data []byte
+	meta []byte
+	run  uint64
+
+	// staged is the time the change was staged, or the zero time if it is unknown.
+	staged time.Time
+
+	// released is set once data refers to memory of a transaction that may no longer be open, see EachOpts.ZeroCopy.
+	released bool
+}
+
+// release invalidates the decoder so that any later use panics instead of reading memory that may have been reused.
+func (msg *msgpackDecoder) release() {
+	msg.released = true
+	msg.data, msg.meta = nil, nil
+}
+
+func (msg *msgpackDecoder) check() {
+	if msg.released {
+		panic("diffdb: zero-copy Decoder used after ApplyFunc returned")
+	}
 }
 
 func (msg *msgpackDecoder) Decode(x interface{}) error {
-	r := bytes.NewReader(msg.data)
+	msg.check()
+	data, err := decompress(msg.data)
+	if err != nil {
+		return err
+	}
+	switch encoding, content := untagPayload(data); encoding {
+	case encodingRaw, encodingBinary:
+		return decodeRaw(content, x)
+	case encodingCodec:
+		return decodeCodec(content, x)
+	}
+	r := bytes.NewReader(data)
 	return msgpack.NewDecoder(r).Decode(x)
 }
+
+func (msg *msgpackDecoder) Bytes() ([]byte, error) {
+	msg.check()
+	data, err := decompress(msg.data)
+	if err != nil {
+		return nil, err
+	}
+	_, content := untagPayload(data)
+	return content, nil
+}
+
+func (msg *msgpackDecoder) DecodeJSON() ([]byte, error) {
+	var x interface{}
+	if err := msg.Decode(&x); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue(x))
+}
+
+func (msg *msgpackDecoder) DecodeMap() (map[string]interface{}, error) {
+	var x interface{}
+	if err := msg.Decode(&x); err != nil {
+		return nil, err
+	}
+	m, ok := jsonValue(x).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("diffdb: cannot decode %T into a map", x)
+	}
+	return m, nil
+}
+
+// jsonValue converts maps with interface keys produced by msgpack into maps that can be encoded as JSON.
+func jsonValue(x interface{}) interface{} {
+	switch v := x.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,80 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+type forwardRow struct {
+	Name string
+	Tags map[string]int
+}
+
+func (r forwardRow) ID() []byte {
+	return []byte(r.Name)
+}
+
+func TestDecoder_Forward(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.CompressPayloads(CompressionSnappy); err != nil {
+		t.Fatal(err)
+	}
+
+	row := forwardRow{Name: "a", Tags: map[string]int{"x": 1}}
+	if _, err := diff.Add(row); err != nil {
+		t.Fatal(err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		expect, err := msgpack.Marshal(row)
+		if err != nil {
+			return err
+		}
+		raw, err := data.Bytes()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(raw, expect) {
+			t.Fatalf("Expected the decompressed msgpack payload; got %x", raw)
+		}
+
+		js, err := data.DecodeJSON()
+		if err != nil {
+			return err
+		}
+		if string(js) != `{"Name":"a","Tags":{"x":1}}` {
+			t.Fatalf("Unexpected JSON %s", js)
+		}
+
+		m, err := data.DecodeMap()
+		if err != nil {
+			return err
+		}
+		if m["Name"] != "a" {
+			t.Fatalf("Unexpected map %v", m)
+		}
+		if tags, ok := m["Tags"].(map[string]interface{}); !ok || len(tags) != 1 {
+			t.Fatalf("Expected nested maps to have string keys; got %T", m["Tags"])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoder_Bytes_Corrupt(t *testing.T) {
+	data := &msgpackDecoder{data: []byte{compressedMarker, byte(CompressionSnappy), 0xff, 0xff}}
+	if raw, err := data.Bytes(); err == nil {
+		t.Fatalf("Expected an error reading a corrupt payload; got %x", raw)
+	}
+}
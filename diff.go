@@ -3,18 +3,33 @@ package diffdb
 import (
 	"bytes"
 	"context"
-	"github.com/boltdb/bolt"
-	"github.com/hashicorp/go-multierror"
-	"gopkg.in/vmihailenco/msgpack.v2"
 	"os"
+	"path/filepath"
 	"errors"
 	"github.com/mitchellh/hashstructure"
 	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	bolt "go.etcd.io/bbolt"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
 	// ErrConflictingKey indicates that MustNotConflict() was enabled and a conflicting ID was entered into the state database.
+	// It is matched by the *ConflictError returned by Add.
 	ErrConflictingKey = errors.New("diffdb: multiple objects with the same ID were added in the same change version")
+
+	// ErrReadOnly is returned by methods that would modify a database opened in read-only mode.
+	ErrReadOnly = errors.New("diffdb: database is open in read-only mode")
+
+	// ErrNotFound is returned when a requested ID has no retained state.
+	ErrNotFound = errors.New("diffdb: not found")
+
+	// ErrHashMismatch is returned by CompareAndAdd when the committed hash of an ID is not the expected hash.
+	ErrHashMismatch = errors.New("diffdb: committed hash does not match the expected hash")
 )
 
 // An Object is a Go object passed to a differential database to track changes on.
@@ -26,7 +41,9 @@ type Object interface {
 
 
 func HashOf(x interface{}) ([]byte, error) {
-	i, err := hashstructure.Hash(x, nil)
+	opts := hashOptions.Get().(*hashstructure.HashOptions)
+	i, err := hashstructure.Hash(x, opts)
+	hashOptions.Put(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -35,35 +52,325 @@ func HashOf(x interface{}) ([]byte, error) {
 	return b, nil
 }
 
+// NewOptions configures how the underlying BoltDB file is opened by NewWithOptions.
+type NewOptions struct {
+	// Timeout is the amount of time to wait to obtain a file lock on the database.
+	// When set to zero it will wait indefinitely.
+	Timeout time.Duration
+
+	// NoSync skips fsync() calls after each commit.
+	// This can speed up bulk ingestion but risks losing committed state if the host crashes.
+	NoSync bool
+
+	// FreelistType sets the freelist implementation used by BoltDB.
+	// When empty the BoltDB default (array) is used.
+	FreelistType bolt.FreelistType
+
+	// ReadOnly opens the database file with a shared lock, allowing multiple readers.
+	ReadOnly bool
+
+	// Logger is attached to the database as if by calling DB.SetLogger.
+	Logger *slog.Logger
+
+	// TracerProvider is used to trace database operations as if by calling DB.SetTracerProvider.
+	TracerProvider trace.TracerProvider
+
+	// BatchAdd coalesces concurrent calls to Add into fewer transactions as if by calling DB.SetBatchAdd.
+	BatchAdd bool
+
+	// MaxBatchSize and MaxBatchDelay override the BoltDB limits on the size of a batch
+	// and how long to wait for it to fill when BatchAdd is enabled. When zero the BoltDB defaults are used.
+	MaxBatchSize  int
+	MaxBatchDelay time.Duration
+
+	// FileMode sets the permissions of the database file if it is created. When zero 0600 is used.
+	FileMode os.FileMode
+
+	// CreateDirs creates any missing parent directories of the database file.
+	// Directories are created with the permissions of FileMode plus execute permission wherever it grants read permission.
+	CreateDirs bool
+
+	// InitialMmapSize is the initial size in bytes of the memory map of the database file.
+	// Setting it to the expected size of a large database avoids remapping the file as it grows,
+	// which blocks all transactions while it happens.
+	InitialMmapSize int
+
+	// MustExist returns an error wrapping os.ErrNotExist instead of creating the database file if it does not exist.
+	MustExist bool
+
+	// MustCreate returns an error wrapping os.ErrExist instead of opening the database file if it already exists.
+	MustCreate bool
+}
+
+func (opts *NewOptions) fileMode() os.FileMode {
+	if opts == nil || opts.FileMode == 0 {
+		return 0600
+	}
+	return opts.FileMode
+}
+
+func (opts *NewOptions) bolt() *bolt.Options {
+	if opts == nil {
+		return nil
+	}
+	options := &bolt.Options{
+		Timeout:         opts.Timeout,
+		NoSync:          opts.NoSync,
+		FreelistType:    opts.FreelistType,
+		ReadOnly:        opts.ReadOnly,
+		InitialMmapSize: opts.InitialMmapSize,
+	}
+	if opts.MustExist {
+		// The file is opened without O_CREATE, so it cannot be created if it is removed after being checked
+		options.OpenFile = func(name string, flag int, mode os.FileMode) (*os.File, error) {
+			f, err := os.OpenFile(name, flag&^os.O_CREATE, mode)
+			if err != nil {
+				return nil, fmt.Errorf("diffdb: database must exist: %w", err)
+			}
+			return f, nil
+		}
+	}
+	return options
+}
+
+// create prepares the database file at path according to CreateDirs, MustExist and MustCreate.
+func (opts *NewOptions) create(path string, mode os.FileMode) error {
+	if opts.MustExist && opts.MustCreate {
+		return errors.New("diffdb: MustExist and MustCreate cannot both be set")
+	}
+	if opts.MustExist {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("diffdb: database must exist: %w", err)
+		}
+		return nil
+	}
+	if opts.CreateDirs {
+		if err := os.MkdirAll(filepath.Dir(path), mode|(mode&0444)>>2); err != nil {
+			return err
+		}
+	}
+	if opts.MustCreate {
+		// The file is created exclusively here so that two processes cannot both create it,
+		// BoltDB initialises the empty file when it is opened
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
+		if err != nil {
+			return fmt.Errorf("diffdb: database must not exist: %w", err)
+		}
+		return f.Close()
+	}
+	return nil
+}
+
 // New creates a new hashing database using the given filename
 func New(path string) (*DB, error) {
-	db, err := bolt.Open(path, os.FileMode(0600), nil)
+	return NewWithOptions(path, nil)
+}
+
+// NewWithOptions creates a new hashing database using the given filename and BoltDB options.
+// If options is nil then the defaults used by New are applied.
+func NewWithOptions(path string, options *NewOptions) (*DB, error) {
+	mode := options.fileMode()
+	if options != nil {
+		if err := options.create(path, mode); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := options.bolt()
+	db, err := bolt.Open(path, mode, opts)
 	if err != nil {
 		return nil, err
 	}
 
+	dd := NewWithBackend(&boltBackend{db: db, opts: opts})
+	if options != nil {
+		dd.readOnly = options.ReadOnly
+		dd.SetLogger(options.Logger)
+		dd.SetTracerProvider(options.TracerProvider)
+		dd.SetBatchAdd(options.BatchAdd)
+		if options.MaxBatchSize > 0 {
+			db.MaxBatchSize = options.MaxBatchSize
+		}
+		if options.MaxBatchDelay > 0 {
+			db.MaxBatchDelay = options.MaxBatchDelay
+		}
+	}
+	return dd, nil
+}
+
+// OpenReadOnly opens an existing database file in read-only mode.
+// Multiple read-only processes may open the same file, allowing counts and pending changes
+// to be inspected while another process holds the file for writing.
+// Methods that would modify the database return ErrReadOnly.
+func OpenReadOnly(path string) (*DB, error) {
+	return NewWithOptions(path, &NewOptions{
+		ReadOnly: true,
+	})
+}
+
+// NewWithBackend creates a new hashing database stored in the given backend.
+func NewWithBackend(backend Backend) *DB {
 	return &DB{
-		db: db,
-	}, nil
+		backend: backend,
+	}
 }
 
 var (
 	bucketHashes          = []byte("_m")
 	bucketPendingHashes   = []byte("_ph")
-	bucketPendingHashData = []byte("_pd")
+	bucketPendingData     = []byte("_pi")
+	bucketPendingHashData = []byte("_pd") // legacy pending data keyed by hash, see migratePendingData
 	bucketUserData        = []byte("_ud")
 	bucketKeyConflicts    = []byte("_dk")
+	bucketHistory         = []byte("_hi")
+	bucketCommittedData   = []byte("_cd")
+	bucketFailures        = []byte("_fa")
+	bucketDeadLetters     = []byte("_dl")
+	bucketTouched         = []byte("_tt")
+	bucketMetadata        = []byte("_md")
+	bucketJournal         = []byte("_jn")
+	bucketInFlight        = []byte("_if")
+	bucketDelayed         = []byte("_dy")
+	bucketLeases          = []byte("_ls")
 )
 
-// A DB is a wrapper around a BoltDB to open multiple differential buckets
+// A DB is a wrapper around a Backend to open multiple differential buckets
 type DB struct {
-	db *bolt.DB
+	backend  Backend
+	readOnly bool
+	logger   atomic.Pointer[slog.Logger]
+	tracer   atomic.Pointer[tracerRef]
+	batchAdd atomic.Bool
+	handles  sync.Map
+}
+
+// begin starts a new transaction, returning ErrReadOnly if a writable transaction is requested in read-only mode.
+func (db *DB) begin(writable bool) (Tx, error) {
+	if writable && db.readOnly {
+		return nil, ErrReadOnly
+	}
+	tx, err := db.backend.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	if l := db.logger.Load(); l != nil && writable {
+		tx.OnCommit(func() {
+			l.Debug("transaction committed")
+		})
+	}
+	return tx, nil
+}
+
+// beginWait starts a new transaction like begin and returns ctx.Err() if ctx is cancelled first.
+// Only a single writable transaction can be open at a time, so this allows callers to abandon waiting for one.
+// A transaction started after ctx is cancelled is rolled back as soon as it is available.
+func (db *DB) beginWait(ctx context.Context, writable bool) (Tx, error) {
+	if ctx.Done() == nil {
+		return db.begin(writable)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		tx  Tx
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		tx, err := db.begin(writable)
+		ch <- result{tx, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.tx, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.err == nil {
+				r.tx.Rollback()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func (db *DB) view(f func(tx Tx) error) error {
+	return view(db.backend, f)
+}
+
+func (db *DB) update(f func(tx Tx) error) error {
+	return db.updateContext(context.Background(), f)
+}
+
+// updateContext executes f inside a read-write transaction which is committed if f returns nil.
+// The transaction is abandoned without being committed if ctx is cancelled before f returns.
+func (db *DB) updateContext(ctx context.Context, f func(tx Tx) error) error {
+	tx, err := db.beginContext(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Open opens a named differential or creates one if it does not exist.
+// In read-only mode the differential must already exist.
 func (db *DB) Open(name string) (*Differential, error) {
+	return db.OpenWithOptions(name, nil)
+}
+
+// OpenWithOptions opens a named differential or creates one using the given options if it does not exist.
+// If options is nil then the defaults used by Open are applied.
+func (db *DB) OpenWithOptions(name string, options *OpenOptions) (*Differential, error) {
+	if options == nil {
+		options = &OpenOptions{}
+	}
+
 	q := []byte(name)
-	err := db.db.Update(func(tx *bolt.Tx) error {
+	var (
+		width  HashWidth
+		mirror bool
+	)
+	if db.readOnly {
+		err := db.view(func(tx Tx) error {
+			b := tx.Bucket(q)
+			if b == nil {
+				return fmt.Errorf("diffdb: differential %q does not exist", name)
+			}
+			if b.Bucket(bucketPendingHashData) != nil {
+				return fmt.Errorf("diffdb: differential %q uses a legacy format and must be opened writable once to migrate it", name)
+			}
+			width = loadHashWidth(b)
+			if options.HashWidth != 0 && options.HashWidth != width {
+				return fmt.Errorf("diffdb: differential %q uses %d-bit hashes", name, width*8)
+			}
+			if options.Mirror && !isMirror(b) {
+				return fmt.Errorf("diffdb: differential %q is not a mirror", name)
+			}
+			mirror = isMirror(b)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return db.register(&Differential{
+			q:              q,
+			db:             db,
+			hashWidth:      width,
+			retainPayloads: mirror,
+		}), nil
+	}
+
+	err := db.update(func(tx Tx) error {
+		created := tx.Bucket(q) == nil
 		b, err := tx.CreateBucketIfNotExists(q)
 		if err != nil {
 			return err
@@ -77,7 +384,7 @@ func (db *DB) Open(name string) (*Differential, error) {
 		if err != nil {
 			return err
 		}
-		_, err = b.CreateBucketIfNotExists(bucketPendingHashData)
+		_, err = b.CreateBucketIfNotExists(bucketPendingData)
 		if err != nil {
 			return err
 		}
@@ -85,7 +392,29 @@ func (db *DB) Open(name string) (*Differential, error) {
 		if err != nil {
 			return err
 		}
+		_, err = b.CreateBucketIfNotExists(bucketFailures)
+		if err != nil {
+			return err
+		}
+		if err := migratePendingData(b); err != nil {
+			return err
+		}
 
+		if err := initMetadata(b); err != nil {
+			return err
+		}
+
+		width, err = initHashWidth(b, name, options.HashWidth, created)
+		if err != nil {
+			return err
+		}
+
+		if options.Mirror && !isMirror(b) {
+			if err := enableMirror(b); err != nil {
+				return err
+			}
+		}
+		mirror = isMirror(b)
 		return nil
 	})
 
@@ -93,32 +422,82 @@ func (db *DB) Open(name string) (*Differential, error) {
 		return nil, err
 	}
 
-	return &Differential{
-		q:  q,
-		db: db.db,
-	}, nil
+	return db.register(&Differential{
+		q:              q,
+		db:             db,
+		hashWidth:      width,
+		retainPayloads: mirror,
+	}), nil
+}
+
+// register records diff as the most recently opened Differential of its name, used by Txn.Add and Merge.
+// It is removed when the differential is deleted or renamed.
+func (db *DB) register(diff *Differential) *Differential {
+	db.handles.Store(string(diff.q), diff)
+	return diff
 }
 
 // Delete deletes the named differential.
 func (db *DB) Delete(name string) error {
 	q := []byte(name)
-	return db.db.Update(func(tx *bolt.Tx) error {
+	return db.update(func(tx Tx) error {
+		tx.OnCommit(func() {
+			db.handles.Delete(name)
+		})
 		return tx.DeleteBucket(q)
 	})
 }
 
+// List returns the names of all differentials stored in the database in name order.
+// Top-level buckets that were not created by Open, such as those used by other applications sharing the file, are excluded.
+func (db *DB) List() ([]string, error) {
+	var names []string
+	err := db.view(func(tx Tx) error {
+		return tx.ForEach(func(name []byte, b Bucket) error {
+			if b != nil && b.Bucket(bucketHashes) != nil && b.Bucket(bucketPendingHashes) != nil {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	})
+	return names, err
+}
+
 // Close closes the database file.
 func (db *DB) Close() error {
-	return db.db.Close()
+	return db.backend.Close()
 }
 
 // A Differential tracks changes between serialised Go objects.
 type Differential struct {
 	q    []byte
-	db   *bolt.DB
+	db   *DB
 	cols []string
 
+	logger atomic.Pointer[slog.Logger]
+
+	mu        sync.Mutex
+	watchers  map[chan Event]struct{}
+	hooks     map[EventKind][]Hook
+	conflicts map[string]int
+	indexes   map[string]IndexFunc
+
 	trackConflicts bool
+	conflictPolicy ConflictPolicy
+	conflictMerge  ConflictMergeFunc
+	historyN       int
+	retainPayloads bool
+	maxAttempts    int
+	compression    Compression
+	hashWidth      HashWidth
+	verifyContent  bool
+	comparator     Comparator
+	resume         bool
+	codec          Codec
+	normalizers    []Normalizer
+	quota          Quota
+	maxPendingAge  time.Duration
+	evictAction    EvictAction
 }
 
 func (diff *Differential) Name() string {
@@ -128,11 +507,14 @@ func (diff *Differential) Name() string {
 // MustNotConflict sets a flag to track duplicate IDs given to subsequent calls to Add.
 // This can be used as a debugging tool to check if additions in the same version
 // have conflicting IDs.
+// Conflicting adds fail with a *ConflictError unless another policy is set using SetConflictPolicy.
+// Conflicting IDs and how many times each was added can be retrieved using Conflicts.
 // Calling MustNotConflict will delete any existing conflict information.
 func (diff *Differential) MustNotConflict() error {
-	return diff.db.Update(func(tx *bolt.Tx) error {
+	return diff.db.update(func(tx Tx) error {
 		tx.OnCommit(func() {
 			diff.trackConflicts = true
+			diff.resetConflicts()
 		})
 
 		b := tx.Bucket(diff.q)
@@ -149,29 +531,93 @@ func (diff *Differential) MustNotConflict() error {
 	})
 }
 
-// AddTx adds an object to start tracking by using an existing BoltDB transaction.
-func (diff *Differential) AddTx(tx *bolt.Tx, obj Object) (bool, error) {
+// AddTx adds an object to start tracking by using an existing transaction.
+func (diff *Differential) AddTx(tx Tx, obj Object) (bool, error) {
+	return diff.AddTxContext(context.Background(), tx, obj)
+}
+
+// AddTxContext is like AddTx but returns ctx.Err() without making any changes if ctx is cancelled
+// before obj has been hashed and encoded.
+func (diff *Differential) AddTxContext(ctx context.Context, tx Tx, obj Object) (bool, error) {
+	updated, _, err := diff.addTxContext(ctx, tx, obj)
+	return updated, err
+}
+
+// addTx adds obj like AddTx and also returns the size of the stored payload.
+func (diff *Differential) addTx(tx Tx, obj Object) (bool, int, error) {
+	return diff.addTxContext(context.Background(), tx, obj)
+}
+
+// addTxContext adds obj like AddTxContext and also returns the size of the stored payload.
+func (diff *Differential) addTxContext(ctx context.Context, tx Tx, obj Object) (bool, int, error) {
+	var conflicted []byte
+	updated, size, err := diff.stage(ctx, tx, obj, &conflicted)
+	if conflicted != nil {
+		diff.recordConflict(conflicted)
+	}
+	return updated, size, err
+}
+
+// stage adds obj like addTxContext. If the ID of obj conflicts with an earlier add then it is set in conflicted
+// for the caller to count using recordConflict, even if the add fails, as a batched add may be called more than once.
+func (diff *Differential) stage(ctx context.Context, tx Tx, obj Object, conflicted *[]byte) (bool, int, error) {
+	if diff.db.readOnly {
+		return false, 0, ErrReadOnly
+	}
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+
 	b := tx.Bucket(diff.q)
 
 	var (
-		bh   = b.Bucket(bucketHashes)
-		bph  = b.Bucket(bucketPendingHashes)
-		bphd = b.Bucket(bucketPendingHashData)
+		bh  = b.Bucket(bucketHashes)
+		bph = b.Bucket(bucketPendingHashes)
+		bpd = b.Bucket(bucketPendingData)
 	)
 
+	_, md := splitMetadata(obj)
+	priority := priorityOf(obj)
+	obj = diff.normalize(obj)
 	id := obj.ID()
 
+	if err := diff.touch(b, id); err != nil {
+		return false, 0, err
+	}
+	if err := recordAdd(b); err != nil {
+		return false, 0, err
+	}
+
 	// Check ID conflicts
+	var conflict bool
 	if diff.trackConflicts {
 		bkc := b.Bucket(bucketKeyConflicts)
 		if bkc.Get(id) != nil {
-			return false, ErrConflictingKey
+			*conflicted = copyBytes(id)
+
+			resolved, err := diff.resolveConflict(b, id, obj)
+			if err != nil || resolved == nil {
+				return false, 0, err
+			}
+			obj, conflict = resolved, true
 		}
 	}
 
-	hash, err := HashOf(obj)
+	hash, err := diff.hash(obj)
 	if err != nil {
-		return false, err
+		return false, 0, err
+	}
+
+	// Content verification needs the encoding before deciding whether anything changed.
+	// Versions are supplied by the source system, so versioned objects are never verified.
+	_, versioned := decodeVersion(hash)
+	verify := diff.verifyContent && !versioned
+
+	var raw []byte
+	if verify {
+		if raw, err = diff.encode(obj); err != nil {
+			return false, 0, err
+		}
 	}
 
 	var (
@@ -180,8 +626,38 @@ func (diff *Differential) AddTx(tx *bolt.Tx, obj Object) (bool, error) {
 	)
 
 	// An existing committed hash is identical, no need for changes
-	if match {
-		return false, nil
+	if match && verify {
+		var stored []byte
+		if bcd := b.Bucket(bucketCommittedData); bcd != nil {
+			stored = bcd.Get(id)
+		}
+		if match, err = diff.sameContent(id, stored, raw); err != nil {
+			return false, 0, err
+		}
+	}
+	if match && conflict {
+		// The version that replaces the conflicting pending change is already committed
+		return false, 0, diff.discardPending(tx, b, id)
+	}
+	if match || notNewer(hash, existing) {
+		return false, 0, nil
+	}
+
+	// A custom comparator may still consider the contents equivalent to the committed payload
+	compare := diff.comparator != nil && !versioned
+	if compare {
+		if raw == nil {
+			if raw, err = diff.encode(obj); err != nil {
+				return false, 0, err
+			}
+		}
+		var stored []byte
+		if bcd := b.Bucket(bucketCommittedData); bcd != nil {
+			stored = bcd.Get(id)
+		}
+		if equivalent, err := diff.equivalent(stored, raw); err != nil || equivalent {
+			return false, 0, err
+		}
 	}
 
 	// Check if pending hash already exists
@@ -189,69 +665,88 @@ func (diff *Differential) AddTx(tx *bolt.Tx, obj Object) (bool, error) {
 
 		// Contents are identical to existing pending version, no need for changes
 		if len(pending) > 0 && bytes.Compare(pending, hash) == 0 {
-			return false, nil
+			same := true
+			if verify {
+				if same, err = diff.sameContent(id, bpd.Get(id), raw); err != nil {
+					return false, 0, err
+				}
+			}
+			if same {
+				return false, 0, nil
+			}
+		}
+		if notNewer(hash, pending) {
+			return false, 0, nil
+		}
+		if compare {
+			if equivalent, err := diff.equivalent(bpd.Get(id), raw); err != nil || equivalent {
+				return false, 0, err
+			}
+		}
+
+		// A new version resets failures of the previous version
+		if err := b.Bucket(bucketFailures).Delete(id); err != nil {
+			return false, 0, err
+		}
+		if err := clearDelay(b, id); err != nil {
+			return false, 0, err
 		}
+	}
 
-		if err := bphd.Delete(pending); err != nil {
-			return false, err
+	if raw == nil {
+		if raw, err = diff.encode(obj); err != nil {
+			return false, 0, err
 		}
 	}
+	raw = compress(diff.compression, raw)
+
+	// Hashing and encoding a large object can be slow, so the caller may have given up in the meantime
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+
+	if err := diff.reserve(b, id, raw); err != nil {
+		return false, 0, diff.wrapErr(id, err)
+	}
 
 	// Ensure this ID is ready to be tracked
 	if err := bph.Put(id, hash); err != nil {
-		return false, err
+		return false, 0, err
 	}
-
-	raw, err := msgpack.Marshal(obj)
-	if err != nil {
-		return false, err
+	if err := bpd.Put(id, raw); err != nil {
+		return false, 0, err
 	}
-	if err := bphd.Put(hash, raw); err != nil {
-		return false, err
+	if err := putChangeMetadata(b, id, md); err != nil {
+		return false, 0, err
+	}
+	if err := putPriority(b, id, priority); err != nil {
+		return false, 0, err
+	}
+	if err := putStagedAt(b, id); err != nil {
+		return false, 0, err
+	}
+	if stagingOrder(b) {
+		if err := putSequence(b, id); err != nil {
+			return false, 0, err
+		}
 	}
 
 	if diff.trackConflicts {
 		err := b.Bucket(bucketKeyConflicts).Put(id, nil)
 		if err != nil {
-			return false, err
+			return false, 0, err
 		}
 	}
 
-	return true, nil
+	diff.emit(tx, EventStaged, id, hash)
+	return true, len(raw), nil
 }
 
 // AddChan adds objects sent from a channel until the channel is closed, the object is nil,  or the context is cancelled.
 // AddChan may stop processing the stream if an error occurs in which case no more messages will be consumed
 // and that error will be returned.
 func (diff *Differential) AddChan(ctx context.Context, stream <-chan Object) error {
-	tx, err := diff.db.Begin(true)
-	if err != nil {
-		return err
-	}
-
-	defer tx.Rollback()
-
-	var obj Object
-	var i int
-	for ; ; i ++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case obj = <-stream:
-			if obj == nil {
-				return tx.Commit()
-			}
-		}
-
-		updated, err := diff.AddTx(tx, obj)
-		if err != nil {
-			return err
-		}
-		if !updated {
-			i --
-			continue
-		}
-	}
+	return diff.AddChanWith(ctx, stream, AddChanOpts{})
 }
 
 // Add as a new object x to the list of pending changes.
@@ -261,25 +756,160 @@ func (diff *Differential) AddChan(ctx context.Context, stream <-chan Object) err
 // If Add is called multiple times same ID before applying changes then
 // only the latest change will be taken to be applied.
 func (diff *Differential) Add(obj Object) (updated bool, err error) {
-	err = diff.db.Update(func(tx *bolt.Tx) error {
+	return diff.AddContext(context.Background(), obj)
+}
+
+// AddContext adds obj like Add but gives up if ctx is cancelled while waiting for the database writer
+// or while obj is being hashed and encoded, in which case ctx.Err() is returned and nothing is changed.
+func (diff *Differential) AddContext(ctx context.Context, obj Object) (updated bool, err error) {
+	ctx, span := diff.startSpan(ctx, "diffdb.Add")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	var (
+		size       int
+		conflicted []byte
+	)
+	err = diff.db.addContext(ctx, func(tx Tx) error {
 		var e error
-		updated, e = diff.AddTx(tx, obj)
+		conflicted = nil
+		updated, size, e = diff.stage(ctx, tx, obj, &conflicted)
 		return e
 	})
+	if conflicted != nil {
+		diff.recordConflict(conflicted)
+	}
+	span.SetAttributes(attrUpdated.Bool(updated), attrBytes.Int(size))
 	return
 }
 
+// AddBatch adds a slice of objects in a single transaction and returns how many of them were changed.
+// If any object fails to be added then none of the objects are added.
+func (diff *Differential) AddBatch(objs []Object) (updated int, err error) {
+	err = diff.db.update(func(tx Tx) error {
+		for _, obj := range objs {
+			ok, err := diff.AddTx(tx, obj)
+			if err != nil {
+				return err
+			}
+			if ok {
+				updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return
+}
+
+// CompareAndAdd adds obj like Add only if the committed hash of its ID is expectedHash,
+// as returned by HashFor, otherwise ErrHashMismatch is returned and nothing is changed.
+// A nil expectedHash expects the ID to have no committed version.
+//
+// CompareAndAdd gives optimistic concurrency to multiple processes adding to the same differential:
+// a process that read a committed version can only stage a change based on it if no other change has been applied since.
+func (diff *Differential) CompareAndAdd(obj Object, expectedHash []byte) (updated bool, err error) {
+	err = diff.db.update(func(tx Tx) error {
+		var e error
+		updated, e = diff.CompareAndAddTx(tx, obj, expectedHash)
+		return e
+	})
+	return
+}
+
+// CompareAndAddTx is like CompareAndAdd using an existing transaction.
+func (diff *Differential) CompareAndAddTx(tx Tx, obj Object, expectedHash []byte) (bool, error) {
+	id := diff.normalize(obj).ID()
+	if committed := tx.Bucket(diff.q).Bucket(bucketHashes).Get(id); !bytes.Equal(committed, expectedHash) {
+		return false, diff.wrapErr(id, ErrHashMismatch)
+	}
+	return diff.AddTx(tx, obj)
+}
+
 // Changed returns true if the hash of x has changed for its ID.
+// If x is an Object then it is normalized before it is hashed.
 func (diff *Differential) Changed(id []byte, x interface{}) (changed bool, err error) {
-	var hash []byte
-	hash, err = HashOf(x)
+	err = diff.db.view(func(tx Tx) error {
+		var e error
+		changed, e = diff.ChangedTx(tx, id, x)
+		return e
+	})
+	return
+}
+
+// ChangedTx is like Changed using an existing transaction.
+func (diff *Differential) ChangedTx(tx Tx, id []byte, x interface{}) (bool, error) {
+	if obj, ok := x.(Object); ok {
+		x = diff.normalize(obj)
+	}
+
+	hash, err := diff.hash(x)
+	if err != nil {
+		return false, err
+	}
+
+	compare := tx.Bucket(diff.q).Bucket(bucketHashes).Get(id)
+	return bytes.Compare(compare, hash) != 0 && !notNewer(hash, compare), nil
+}
+
+// A ChangedPair is an ID and value checked by ChangedBatch.
+type ChangedPair struct {
+	ID    []byte
+	Value interface{}
+}
+
+// ChangedBatch checks many pairs like Changed in a single read-only transaction.
+// The result at each index reports whether the pair at the same index has changed.
+func (diff *Differential) ChangedBatch(pairs []ChangedPair) ([]bool, error) {
+	changed := make([]bool, len(pairs))
+	err := diff.db.view(func(tx Tx) error {
+		for i, p := range pairs {
+			var err error
+			if changed[i], err = diff.ChangedTx(tx, p.ID, p.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return
+		return nil, err
 	}
+	return changed, nil
+}
 
-	err = diff.db.View(func(tx *bolt.Tx) error {
-		var compare = tx.Bucket(diff.q).Bucket(bucketHashes).Get(id)
-		changed = bytes.Compare(compare, hash) != 0
+// HashFor returns the committed and pending hashes stored for id, which can be compared against the hash of
+// an object to find out why it is seen as changed. Either hash is nil if id has no committed or pending version.
+func (diff *Differential) HashFor(id []byte) (committed, pending []byte, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		if hash := b.Bucket(bucketHashes).Get(id); hash != nil {
+			committed = append([]byte(nil), hash...)
+		}
+		if hash := b.Bucket(bucketPendingHashes).Get(id); hash != nil {
+			pending = append([]byte(nil), hash...)
+		}
+		return nil
+	})
+	return
+}
+
+// Exists returns true if id is tracked by the differential, either with a committed version or a pending change.
+func (diff *Differential) Exists(id []byte) (exists bool, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		exists = b.Bucket(bucketHashes).Get(id) != nil || b.Bucket(bucketPendingHashes).Get(id) != nil
+		return nil
+	})
+	return
+}
+
+// IsPending returns true if id has a pending change that has not been applied.
+func (diff *Differential) IsPending(id []byte) (pending bool, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		pending = tx.Bucket(diff.q).Bucket(bucketPendingHashes).Get(id) != nil
 		return nil
 	})
 	return
@@ -288,9 +918,9 @@ func (diff *Differential) Changed(id []byte, x interface{}) (changed bool, err e
 // CountTracking counts the number of entries in the hash tracking table.
 // In other words, this is the amount of all items tracked by the differential db.
 func (diff *Differential) CountTracking() (count int) {
-	diff.db.View(func(tx *bolt.Tx) error {
+	diff.db.view(func(tx Tx) error {
 		b := tx.Bucket(diff.q)
-		count = b.Bucket(bucketHashes).Stats().KeyN
+		count = b.Bucket(bucketHashes).KeyN()
 		return nil
 	})
 
@@ -299,9 +929,9 @@ func (diff *Differential) CountTracking() (count int) {
 
 // CountChanges returns the number of items in the change pending bucket.
 func (diff *Differential) CountChanges() (pending int) {
-	diff.db.View(func(tx *bolt.Tx) error {
+	diff.db.view(func(tx Tx) error {
 		b := tx.Bucket(diff.q)
-		pending = b.Bucket(bucketPendingHashes).Stats().KeyN
+		pending = b.Bucket(bucketPendingHashes).KeyN()
 		return nil
 	})
 
@@ -314,65 +944,63 @@ type ApplyFunc func(id []byte, data Decoder) error
 // EachN scans through each change until N items have been processed.
 // If n is <= 0 then all pending changes will be applied.
 func (diff *Differential) EachN(ctx context.Context, f ApplyFunc, n int) error {
-	tx, err := diff.db.Begin(true)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	b := tx.Bucket(diff.q)
-	var (
-		bh   = b.Bucket(bucketHashes)
-		bph  = b.Bucket(bucketPendingHashes)
-		bphd = b.Bucket(bucketPendingHashData)
-
-		decoder = new(msgpackDecoder)
-		cur     = bph.Cursor()
-	)
-
-	var updateErr *multierror.Error
-	var i int
-
-scan:
-	for id, hash := cur.First(); id != nil; id, hash = cur.Next() {
-		select {
-		case <-ctx.Done():
-			updateErr = multierror.Append(updateErr, ctx.Err())
-			break scan
-		default:
-		}
-
-		var data = bphd.Get(hash)
-		if data == nil {
-			panic("missing hash data")
-		}
-
-		decoder.data = data
-		if err := f(id, decoder); err != nil {
-			updateErr = multierror.Append(updateErr, err)
-			continue
-		}
+	return diff.EachWith(ctx, f, EachOpts{
+		N: n,
+	})
+}
 
-		if err := bh.Put(id, hash); err != nil {
+// commitChange promotes the pending change of id to the committed state after it has been successfully applied.
+func (diff *Differential) commitChange(tx Tx, b Bucket, id, hash, data []byte) error {
+	if diff.historyN > 0 {
+		if err := diff.appendHistory(b, id, hash, data); err != nil {
 			return err
 		}
-		if err := bph.Delete(id); err != nil {
-			return err
+	}
+	if journaled(b) {
+		kind, previous := ChangeCreate, b.Bucket(bucketHashes).Get(id)
+		if previous != nil {
+			kind = ChangeUpdate
 		}
-		if err := bphd.Delete(hash); err != nil {
+		if err := diff.appendJournal(b, kind, id, hash, previous, data); err != nil {
 			return err
 		}
-		i ++
-		if n > 0 && n == i {
-			break scan
+	}
+	if diff.retainPayloads {
+		if err := b.Bucket(bucketCommittedData).Put(id, data); err != nil {
+			return err
 		}
 	}
+	if err := diff.index(b, id, data); err != nil {
+		return err
+	}
 
-	if err := tx.Commit(); err != nil {
+	if err := b.Bucket(bucketHashes).Put(id, hash); err != nil {
+		return err
+	}
+	if err := b.Bucket(bucketFailures).Delete(id); err != nil {
+		return err
+	}
+	if err := b.Bucket(bucketPendingHashes).Delete(id); err != nil {
+		return err
+	}
+	if err := trackUsage(b, id, nil); err != nil {
+		return err
+	}
+	if err := b.Bucket(bucketPendingData).Delete(id); err != nil {
+		return err
+	}
+	if err := clearInFlight(b, id); err != nil {
+		return err
+	}
+	if err := clearDelay(b, id); err != nil {
+		return err
+	}
+	if err := clearChangeInfo(b, id); err != nil {
 		return err
 	}
 
-	return updateErr.ErrorOrNil()
+	diff.emit(tx, EventApplied, id, hash)
+	return nil
 }
 
 // Each scans through each change and attempts to apply f() to each item waiting to be changed
@@ -380,19 +1008,19 @@ func (diff *Differential) Each(ctx context.Context, f ApplyFunc) error {
 	return diff.EachN(ctx, f, -1)
 }
 
-// ViewUserData wraps a BoltDB view transaction to allow custom user data to be viewed in the differential database.
+// ViewUserData wraps a view transaction to allow custom user data to be viewed in the differential database.
 // This could include information such as run times, last exported differential, etc.
-func (diff *Differential) ViewUserData(f func(b *bolt.Bucket) error) error {
-	return diff.db.View(func(tx *bolt.Tx) error {
+func (diff *Differential) ViewUserData(f func(b Bucket) error) error {
+	return diff.db.view(func(tx Tx) error {
 		b := tx.Bucket(diff.q).Bucket(bucketUserData)
 		return f(b)
 	})
 }
 
-// UpdateUserData wraps a BoltDB update transaction to allow custom user data to viewed or updated
+// UpdateUserData wraps an update transaction to allow custom user data to viewed or updated
 // in the differential database.
-func (diff *Differential) UpdateUserData(f func(b *bolt.Bucket) error) error {
-	return diff.db.Update(func(tx *bolt.Tx) error {
+func (diff *Differential) UpdateUserData(f func(b Bucket) error) error {
+	return diff.db.update(func(tx Tx) error {
 		b := tx.Bucket(diff.q).Bucket(bucketUserData)
 		return f(b)
 	})
@@ -217,7 +217,7 @@ func TestDifferential_MustNotConflict(t *testing.T) {
 	if err == nil {
 		t.Fatal("Expected an error to be raised")
 	}
-	if err != ErrConflictingKey {
+	if !errors.Is(err, ErrConflictingKey) {
 		t.Fatalf("Expected %q as error; got %q", ErrConflictingKey, err)
 	}
 }
@@ -305,3 +305,423 @@ func BenchmarkHash(b *testing.B) {
 		}
 	}
 }
+
+func TestNewWithOptions(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.db")
+	db, err := NewWithOptions(path, &NewOptions{
+		Timeout: time.Second,
+		NoSync:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(IDMapper{id: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = NewWithOptions(path, &NewOptions{
+		ReadOnly: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.view(func(tx Tx) error {
+		if tx.Bucket([]byte("test")) == nil {
+			return errors.New("expected differential bucket to exist")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.db")
+	db, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(IDMapper{id: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Open("missing"); err == nil {
+		t.Fatal("Expected an error opening a missing differential")
+	}
+
+	diff, err = db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected 1 pending change; got %d", pending)
+	}
+	if _, err := diff.Add(IDMapper{id: []byte("2")}); err != ErrReadOnly {
+		t.Fatalf("Expected %q from Add; got %v", ErrReadOnly, err)
+	}
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != ErrReadOnly {
+		t.Fatalf("Expected %q from Each; got %v", ErrReadOnly, err)
+	}
+	if err := db.Delete("test"); err != ErrReadOnly {
+		t.Fatalf("Expected %q from Delete; got %v", ErrReadOnly, err)
+	}
+}
+
+func TestDifferential_AddBatch(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objs := []Object{
+		NewIDObject([]byte("1"), 1),
+		NewIDObject([]byte("2"), 2),
+		NewIDObject([]byte("2"), 2),
+	}
+	updated, err := diff.AddBatch(objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 2 {
+		t.Fatalf("Expected 2 updated objects; got %d", updated)
+	}
+	if pending := diff.CountChanges(); pending != 2 {
+		t.Fatalf("Expected 2 pending changes; got %d", pending)
+	}
+
+	if err := diff.MustNotConflict(); err != nil {
+		t.Fatal(err)
+	}
+	updated, err = diff.AddBatch([]Object{
+		NewIDObject([]byte("3"), 3),
+		NewIDObject([]byte("3"), 4),
+	})
+	if !errors.Is(err, ErrConflictingKey) {
+		t.Fatalf("Expected %q; got %v", ErrConflictingKey, err)
+	}
+	if updated != 0 || diff.CountChanges() != 2 {
+		t.Fatal("Expected a failed batch to add nothing")
+	}
+}
+
+func TestDB_List(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	for _, name := range []string{"b", "a"} {
+		if _, err := db.Open(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := db.update(func(tx Tx) error {
+		_, err := tx.CreateBucket([]byte("other"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := db.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatalf("Expected [a b]; got %v", names)
+	}
+}
+
+func TestDifferential_AddContext(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := diff.AddContext(ctx, NewIDObject([]byte("1"), 1)); err != context.Canceled {
+		t.Fatalf("Expected %q; got %v", context.Canceled, err)
+	}
+
+	// Waiting for another writer is abandoned once the context expires
+	tx, err := db.begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := diff.AddContext(ctx, NewIDObject([]byte("1"), 1)); err != context.DeadlineExceeded {
+		t.Fatalf("Expected %q; got %v", context.DeadlineExceeded, err)
+	}
+	tx.Rollback()
+
+	if pending := diff.CountChanges(); pending != 0 {
+		t.Fatalf("Expected no pending changes; got %d", pending)
+	}
+	if updated, err := diff.AddContext(context.Background(), NewIDObject([]byte("1"), 1)); err != nil || !updated {
+		t.Fatalf("Expected the object to be added; got %v, %v", updated, err)
+	}
+}
+
+func TestDifferential_HashFor(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := []byte("1")
+	committed, pending, err := diff.HashFor(id)
+	if err != nil || committed != nil || pending != nil {
+		t.Fatalf("Expected no hashes; got %x, %x, %v", committed, pending, err)
+	}
+
+	obj := NewIDObject(id, 1)
+	if _, err := diff.Add(obj); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := diff.hash(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, pending, err = diff.HashFor(id)
+	if err != nil || committed != nil || !bytes.Equal(pending, hash) {
+		t.Fatalf("Expected pending hash %x; got %x, %x, %v", hash, committed, pending, err)
+	}
+
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	committed, pending, err = diff.HashFor(id)
+	if err != nil || !bytes.Equal(committed, hash) || pending != nil {
+		t.Fatalf("Expected committed hash %x; got %x, %x, %v", hash, committed, pending, err)
+	}
+}
+
+func TestDifferential_CompareAndAdd(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := []byte("1")
+	if _, err := diff.CompareAndAdd(NewIDObject(id, 1), []byte("other")); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("Expected %q; got %v", ErrHashMismatch, err)
+	}
+	if updated, err := diff.CompareAndAdd(NewIDObject(id, 1), nil); err != nil || !updated {
+		t.Fatalf("Expected the object to be added; got %v, %v", updated, err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	committed, _, err := diff.HashFor(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A stale reader expecting no committed version loses
+	if _, err := diff.CompareAndAdd(NewIDObject(id, 2), nil); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("Expected %q; got %v", ErrHashMismatch, err)
+	}
+	if pending := diff.CountChanges(); pending != 0 {
+		t.Fatalf("Expected no pending changes; got %d", pending)
+	}
+	if updated, err := diff.CompareAndAdd(NewIDObject(id, 2), committed); err != nil || !updated {
+		t.Fatalf("Expected the object to be added; got %v, %v", updated, err)
+	}
+}
+
+func TestDifferential_ChangedBatch(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := diff.ChangedBatch([]ChangedPair{
+		{[]byte("1"), NewIDObject([]byte("1"), 1)},
+		{[]byte("1"), NewIDObject([]byte("1"), 2)},
+		{[]byte("2"), NewIDObject([]byte("2"), 1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 3 || changed[0] || !changed[1] || !changed[2] {
+		t.Fatalf("Expected [false true true]; got %v", changed)
+	}
+}
+
+func TestDifferential_ExistsIsPending(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := []byte("1")
+	check := func(exists, pending bool) {
+		t.Helper()
+		if ok, err := diff.Exists(id); err != nil || ok != exists {
+			t.Fatalf("Expected Exists %v; got %v, %v", exists, ok, err)
+		}
+		if ok, err := diff.IsPending(id); err != nil || ok != pending {
+			t.Fatalf("Expected IsPending %v; got %v, %v", pending, ok, err)
+		}
+	}
+
+	check(false, false)
+	if _, err := diff.Add(NewIDObject(id, 1)); err != nil {
+		t.Fatal(err)
+	}
+	check(true, true)
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	check(true, false)
+}
+
+func TestNewWithOptions_CreateDirs(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a", "b", "state.db")
+	db, err := NewWithOptions(path, &NewOptions{
+		FileMode:        0640,
+		CreateDirs:      true,
+		InitialMmapSize: 1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode&^0640 != 0 {
+		t.Fatalf("Expected file mode within 0640; got %o", mode)
+	}
+	fi, err = os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode&^0750 != 0 || mode&0700 != 0700 {
+		t.Fatalf("Expected directory mode within 0750; got %o", mode)
+	}
+
+	// A second writer gives up waiting for the file lock
+	if _, err := NewWithOptions(path, &NewOptions{Timeout: 10 * time.Millisecond}); err == nil {
+		t.Fatal("Expected an error while the database is locked")
+	}
+}
+
+func TestNewWithOptions_MustExist(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.db")
+	if _, err := NewWithOptions(path, &NewOptions{MustExist: true}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected %q; got %v", os.ErrNotExist, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("Expected the database file not to be created")
+	}
+
+	db, err := NewWithOptions(path, &NewOptions{MustCreate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Open("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWithOptions(path, &NewOptions{MustCreate: true}); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Expected %q; got %v", os.ErrExist, err)
+	}
+	db, err = NewWithOptions(path, &NewOptions{MustExist: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if names, err := db.List(); err != nil || len(names) != 1 {
+		t.Fatalf("Expected the existing database to be opened; got %v, %v", names, err)
+	}
+}
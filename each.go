@@ -0,0 +1,426 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// EachOpts controls how EachWith applies pending changes and handles errors returned by ApplyFunc.
+// The zero value applies all pending changes and collects every error.
+type EachOpts struct {
+	// N is the maximum number of changes to apply.
+	// If N is <= 0 then all pending changes will be applied.
+	N int
+
+	// FailFast stops the run at the first error returned by ApplyFunc.
+	FailFast bool
+
+	// MaxDuration stops the run without an error once it has been running for this long.
+	// Changes applied so far are committed, so later runs continue where this one stopped.
+	// If MaxDuration is <= 0 then there is no limit.
+	MaxDuration time.Duration
+
+	// TwoPhase marks changes that were successfully applied as in-flight instead of committing them.
+	// In-flight changes are skipped by later runs until they are promoted to the committed state using Confirm,
+	// or returned to the pending queue using Abort.
+	TwoPhase bool
+
+	// CommitEvery commits the transaction and starts a new one after every CommitEvery applied changes,
+	// so that a long run does not hold a single huge transaction and does not lose all of its progress if the process dies.
+	// If CommitEvery is <= 0 then the run is committed once when it ends.
+	CommitEvery int
+
+	// MaxErrors stops the run once this many errors have been collected.
+	// If MaxErrors is <= 0 then there is no limit.
+	MaxErrors int
+
+	// OnError is called for each error returned by ApplyFunc.
+	// The error it returns is collected in place of the original error;
+	// returning nil marks the error as handled so that it is neither collected nor counted towards MaxErrors.
+	// The change remains pending in either case.
+	OnError func(id []byte, err error) error
+
+	// RecoverPanics recovers a panic raised by ApplyFunc and handles it like an error returned for that change,
+	// so that one bad change does not abort the whole run. The error is a *PanicError, which is also included in the Result.
+	RecoverPanics bool
+
+	// Progress is called every ProgressEvery visited changes and once when the run ends.
+	Progress      ProgressFunc
+	ProgressEvery int
+
+	// Where is called for each pending change before it is applied, and changes for which it returns false are
+	// left pending without being applied. An error returned by Where is collected like an error returned by ApplyFunc,
+	// but the change is not recorded as failed.
+	Where func(id []byte, data Decoder) (bool, error)
+
+	// RateLimit limits the run to applying this many changes per second, such as to avoid overwhelming a fragile downstream API.
+	// Changes that are skipped are not counted. If RateLimit is <= 0 then there is no limit.
+	RateLimit float64
+
+	// Limiter is waited on before each change is applied, in place of RateLimit,
+	// such as a *rate.Limiter that is shared between runs or processes calling the same API.
+	Limiter Limiter
+
+	// SkipCorrupt skips pending changes whose state is inconsistent, such as a change without a stored payload,
+	// instead of stopping the run with a *CorruptPendingError. Skipped changes are included in the Result
+	// and recorded so that they can be listed using CorruptPending until they are repaired by GC.
+	SkipCorrupt bool
+
+	// ZeroCopy gives ApplyFunc the ID and payload of each change in the memory of the transaction instead of copies of them,
+	// which avoids allocating for large payloads. The ID, Decoder and any slice returned by Decoder.Bytes are then only valid
+	// until ApplyFunc returns, and must be copied to be retained. Using the Decoder after that panics,
+	// so that a retained Decoder is detected by tests instead of reading memory that may have been reused.
+	//
+	// By default the ID and payload are copied, so they remain valid after ApplyFunc returns.
+	ZeroCopy bool
+
+	// Prefix restricts the run to pending changes whose ID starts with Prefix, see EachPrefix.
+	Prefix []byte
+
+	// partition restricts the run to a single partition, see EachPartition.
+	partition *partitionFilter
+}
+
+// Result summarises an apply run, see EachWithResult.
+type Result struct {
+	// Applied is the number of changes that were applied, or marked as in-flight by a TwoPhase run.
+	Applied int
+	// Failed is the number of changes for which ApplyFunc returned an error.
+	Failed int
+	// Skipped is the number of visited changes that were left pending without being applied,
+	// because they were in-flight, excluded by Where or corrupt.
+	Skipped int
+	// Bytes is the total size of the stored payloads of the changes given to ApplyFunc.
+	Bytes int
+	// Duration is how long the run took.
+	Duration time.Duration
+	// Panics are the panics recovered from ApplyFunc if EachOpts.RecoverPanics is set.
+	Panics []*PanicError
+	// Corrupt are the IDs of the corrupt pending changes that were skipped if EachOpts.SkipCorrupt is set.
+	Corrupt [][]byte
+}
+
+// EachWith scans through each pending change and attempts to apply f() to it using the given options.
+// Changes applied before the run stops are committed even if an error is returned.
+func (diff *Differential) EachWith(ctx context.Context, f ApplyFunc, opts EachOpts) error {
+	_, err := diff.EachWithResult(ctx, f, opts)
+	return err
+}
+
+// EachWithResult is like EachWith but also returns a summary of the run, which is valid even if an error is returned.
+func (diff *Differential) EachWithResult(ctx context.Context, f ApplyFunc, opts EachOpts) (Result, error) {
+	return diff.each(ctx, func(_ context.Context, id []byte, data Decoder) error {
+		return f(id, data)
+	}, opts)
+}
+
+// ApplyContextFunc is like ApplyFunc but is also given the context of the run,
+// so that calls made to apply a change, such as SQL queries or HTTP requests, are cancelled with the run.
+type ApplyContextFunc func(ctx context.Context, id []byte, data Decoder) error
+
+// EachContext is like Each but calls f with the context of the run.
+func (diff *Differential) EachContext(ctx context.Context, f ApplyContextFunc) error {
+	return diff.EachContextWith(ctx, f, EachOpts{})
+}
+
+// EachContextWith is like EachWith but calls f with the context of the run.
+func (diff *Differential) EachContextWith(ctx context.Context, f ApplyContextFunc, opts EachOpts) error {
+	_, err := diff.each(ctx, f, opts)
+	return err
+}
+
+func (diff *Differential) each(ctx context.Context, f ApplyContextFunc, opts EachOpts) (res Result, err error) {
+	var (
+		applied, failed, skipped, size int
+		panics                         []*PanicError
+		corrupt                        [][]byte
+	)
+
+	started := time.Now()
+	ctx, span := diff.startSpan(ctx, "diffdb.Each")
+	defer func() {
+		res = Result{
+			Applied:  applied,
+			Failed:   failed,
+			Skipped:  skipped,
+			Bytes:    size,
+			Duration: time.Since(started),
+			Panics:   panics,
+			Corrupt:  corrupt,
+		}
+		span.SetAttributes(attrApplied.Int(applied), attrFailed.Int(failed), attrBytes.Int(size))
+		endSpan(span, err)
+	}()
+
+	// Evict stale changes in a transaction of their own,
+	// as deleting pending changes of a BoltDB bucket before iterating it can make the cursor skip keys
+	if diff.maxPendingAge > 0 {
+		err = diff.db.updateContext(ctx, func(tx Tx) error {
+			_, err := diff.evict(tx, tx.Bucket(diff.q), diff.maxPendingAge, diff.evictAction)
+			return err
+		})
+		if err != nil {
+			return res, err
+		}
+	}
+
+	tx, err := diff.db.beginContext(ctx, true)
+	if err != nil {
+		return res, err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	b := tx.Bucket(diff.q)
+
+	var (
+		bpd = b.Bucket(bucketPendingData)
+
+		run     = nextRun(b)
+		decoder *msgpackDecoder
+		start   []byte
+		last    []byte
+		mark    []byte // the position of last in the order of the run
+	)
+	// The saved position is shared by all runs, so partitioned and prefixed runs always start from their first pending change.
+	// Positions are IDs, so runs in staging or priority order also start from the first pending change.
+	ordered, prioritised := stagingOrder(b), prioritised(b)
+	resume := diff.resume && opts.partition == nil && len(opts.Prefix) == 0 && !ordered && !prioritised
+	if resume {
+		start = loadCursor(b)
+	}
+	if ordered {
+		if _, err := b.CreateBucketIfNotExists(bucketSequence); err != nil {
+			return res, err
+		}
+	}
+
+	// pending returns a cursor over the pending changes of b visited by the run
+	pending := func(b Bucket) Cursor {
+		var c Cursor
+		if ordered {
+			c = newSequenceCursor(b)
+		} else {
+			c = withPrefix(b.Bucket(bucketPendingHashes).Cursor(), opts.Prefix)
+		}
+		if prioritised {
+			c = newPriorityCursor(b, c)
+		}
+		return c
+	}
+	cur := newResumeCursor(pending(b), start)
+
+	var updateErr *multierror.Error
+	var i, errN int
+
+	var deadline time.Time
+	if opts.MaxDuration > 0 {
+		deadline = time.Now().Add(opts.MaxDuration)
+	}
+
+	progress := newProgressReporter(opts.Progress, opts.ProgressEvery)
+	defer progress.done()
+
+	limiter := newLimiter(opts)
+	if opts.RecoverPanics {
+		f = recoverPanics(f)
+	}
+
+	// A zero-copy decoder refers to memory of the transaction, so it is released once the next change is visited or the run ends
+	defer func() {
+		if opts.ZeroCopy && decoder != nil {
+			decoder.release()
+		}
+	}()
+
+	id, hash := cur.First()
+scan:
+	for ; id != nil; id, hash = cur.Next() {
+		if opts.ZeroCopy && decoder != nil {
+			decoder.release()
+		}
+		select {
+		case <-ctx.Done():
+			updateErr = multierror.Append(updateErr, ctx.Err())
+			break scan
+		default:
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			break scan
+		}
+		last = append(last[:0], id...)
+		mark = append(mark[:0], cur.position(id)...)
+
+		if opts.partition.skip(id) || !bytes.HasPrefix(id, opts.Prefix) {
+			continue
+		}
+		if inFlight(b, id) {
+			skipped++
+			continue
+		}
+
+		var data = bpd.Get(id)
+		if data == nil {
+			if !opts.SkipCorrupt {
+				return res, missingPayload(diff.Name(), id)
+			}
+			if err := recordCorrupt(b, id); err != nil {
+				return res, err
+			}
+			corrupt = append(corrupt, copyBytes(id))
+			skipped++
+			continue
+		}
+
+		// Each change has its own decoder so that a decoder retained by ApplyFunc never sees another change
+		decoder = &msgpackDecoder{run: run, data: data, meta: changeMetadata(b, id), staged: stagedAt(b, id)}
+		if !opts.ZeroCopy {
+			id = copyBytes(id)
+			decoder.data, decoder.meta = copyBytes(decoder.data), copyBytes(decoder.meta)
+		}
+		if opts.Where != nil {
+			ok, err := opts.Where(id, decoder)
+			if err != nil {
+				skipped++
+				updateErr = multierror.Append(updateErr, err)
+				errN++
+				if opts.FailFast || (opts.MaxErrors > 0 && errN >= opts.MaxErrors) {
+					break scan
+				}
+				continue
+			}
+			if !ok {
+				skipped++
+				continue
+			}
+		}
+
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				updateErr = multierror.Append(updateErr, err)
+				break scan
+			}
+		}
+
+		size += len(data)
+		if err := f(ctx, id, decoder); err != nil {
+			failed++
+			if pe, ok := err.(*PanicError); ok {
+				panics = append(panics, pe)
+			}
+			progress.processed(false)
+			if err := diff.failChange(tx, b, id, hash, data, err); err != nil {
+				return res, err
+			}
+			cur.invalidate(mark)
+
+			if opts.OnError != nil {
+				err = opts.OnError(id, err)
+			}
+			if err == nil {
+				continue
+			}
+
+			updateErr = multierror.Append(updateErr, err)
+			errN++
+			if opts.FailFast || (opts.MaxErrors > 0 && errN >= opts.MaxErrors) {
+				break scan
+			}
+			continue
+		}
+
+		if opts.TwoPhase {
+			err = markInFlight(b, id, hash, 0)
+		} else {
+			err = diff.commitChange(tx, b, id, hash, data)
+		}
+		if err != nil {
+			return res, err
+		}
+		cur.invalidate(mark)
+		progress.processed(true)
+		applied++
+		i++
+		if opts.N > 0 && opts.N == i {
+			break scan
+		}
+
+		if opts.CommitEvery > 0 && applied%opts.CommitEvery == 0 {
+			if resume {
+				if err := saveCursor(b, last); err != nil {
+					return res, err
+				}
+			}
+			if err := recordRun(b, run); err != nil {
+				return res, err
+			}
+			if err := tx.Commit(); err != nil {
+				return res, err
+			}
+
+			next, err := diff.db.beginContext(ctx, true)
+			if err != nil {
+				return res, err
+			}
+			tx, b = next, next.Bucket(diff.q)
+			bpd = b.Bucket(bucketPendingData)
+			cur.reset(pending(b), mark)
+		}
+	}
+
+	// The run completed if the cursor reached the end, otherwise the next run resumes after the last visited change
+	if resume && (id == nil || last != nil) {
+		if id == nil {
+			last = nil
+		}
+		if err := saveCursor(b, last); err != nil {
+			return res, err
+		}
+	}
+
+	if err := recordRun(b, run); err != nil {
+		return res, err
+	}
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+
+	return res, updateErr.ErrorOrNil()
+}
+
+// EachFor applies pending changes until they have all been applied or d has elapsed, then commits.
+// This allows scheduled apply jobs to bound their runtime while still making forward progress.
+// A change that is being applied when d elapses is allowed to finish.
+// If d is <= 0 then all pending changes will be applied.
+func (diff *Differential) EachFor(ctx context.Context, f ApplyFunc, d time.Duration) error {
+	return diff.EachWith(ctx, f, EachOpts{
+		MaxDuration: d,
+	})
+}
+
+// EachWhere applies the pending changes for which pred returns true using f like Each,
+// leaving the other changes pending for a later run, such as to apply high-priority entity types first.
+// pred may decode the change to decide, in which case it is decoded again by f.
+func (diff *Differential) EachWhere(ctx context.Context, pred func(id []byte, data Decoder) (bool, error), f ApplyFunc) error {
+	return diff.EachWith(ctx, f, EachOpts{
+		Where: pred,
+	})
+}
+
+// seekAfter positions c at the first key after the position last, or at the first key if last is nil.
+// It allows iteration to continue safely after the bucket has been modified,
+// which would otherwise invalidate the cursor position.
+func seekAfter(c Cursor, last []byte) ([]byte, []byte) {
+	if last == nil {
+		return c.First()
+	}
+	k, v := c.Seek(last)
+	if k != nil && bytes.Equal(positionOf(c, k), last) {
+		return c.Next()
+	}
+	return k, v
+}
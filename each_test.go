@@ -0,0 +1,290 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+func TestDifferential_EachWith(t *testing.T) {
+	errApply := errors.New("apply failed")
+	fail := func(id []byte, data Decoder) error {
+		if id[0]%2 == 0 {
+			return errApply
+		}
+		return nil
+	}
+
+	cases := []struct {
+		Name    string
+		Opts    EachOpts
+		Errors  int
+		Applied int
+	}{
+		{Name: "continue", Opts: EachOpts{}, Errors: 5, Applied: 5},
+		{Name: "fail fast", Opts: EachOpts{FailFast: true}, Errors: 1, Applied: 0},
+		{Name: "max errors", Opts: EachOpts{MaxErrors: 3}, Errors: 3, Applied: 2},
+		{Name: "on error", Opts: EachOpts{OnError: func(id []byte, err error) error { return nil }}, Errors: 0, Applied: 5},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			db := NewMemory()
+			defer db.Close()
+
+			diff, err := db.Open("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 10; i++ {
+				if _, err := diff.Add(NewIDObject([]byte{byte(i)}, strconv.Itoa(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = diff.EachWith(context.Background(), fail, tc.Opts)
+
+			var errs int
+			if me, ok := err.(*multierror.Error); ok {
+				errs = len(me.Errors)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if errs != tc.Errors {
+				t.Fatalf("Expected %d errors; got %d", tc.Errors, errs)
+			}
+			if applied := diff.CountTracking(); applied != tc.Applied {
+				t.Fatalf("Expected %d applied changes; got %d", tc.Applied, applied)
+			}
+		})
+	}
+}
+
+func TestDifferential_EachFor(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var applied int
+	err = diff.EachFor(context.Background(), func(id []byte, data Decoder) error {
+		time.Sleep(5 * time.Millisecond)
+		applied++
+		return nil
+	}, 12*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied == 0 || applied == 10 {
+		t.Fatalf("Expected the run to stop part way through; applied %d", applied)
+	}
+	if pending := diff.CountChanges(); pending != 10-applied {
+		t.Fatalf("Expected %d pending changes; got %d", 10-applied, pending)
+	}
+}
+
+func TestDifferential_EachWith_CommitEvery(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Apply hooks are only called once a change is committed
+	var committed int
+	diff.OnApply(func(id, hash []byte) {
+		committed++
+	})
+
+	var visited int
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		if want := visited / 3 * 3; committed != want {
+			t.Fatalf("Expected %d committed changes before applying %s; got %d", want, id, committed)
+		}
+		visited++
+		return nil
+	}, EachOpts{CommitEvery: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visited != 10 || committed != 10 {
+		t.Fatalf("Expected 10 committed changes; got %d of %d", committed, visited)
+	}
+}
+
+func TestDifferential_EachWhere(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	even := func(id []byte, data Decoder) (bool, error) {
+		var obj struct{ Object int }
+		if err := data.Decode(&obj); err != nil {
+			return false, err
+		}
+		return obj.Object%2 == 0, nil
+	}
+	var applied int
+	err = diff.EachWhere(context.Background(), even, func(id []byte, data Decoder) error {
+		applied++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 5 || diff.CountChanges() != 5 {
+		t.Fatalf("Expected 5 applied and 5 pending changes; got %d and %d", applied, diff.CountChanges())
+	}
+
+	fail := errors.New("fail")
+	err = diff.EachWhere(context.Background(), func(id []byte, data Decoder) (bool, error) {
+		return false, fail
+	}, func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected the predicate error to be returned")
+	}
+	if failed, err := diff.FailedChanges(); err != nil || len(failed) != 0 {
+		t.Fatalf("Expected no failed changes; got %v, %v", failed, err)
+	}
+}
+
+func TestDifferential_EachWithResult(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := diff.EachWithResult(context.Background(), func(id []byte, data Decoder) error {
+		if string(id) == "1" {
+			return errors.New("fail")
+		}
+		return nil
+	}, EachOpts{
+		Where: func(id []byte, data Decoder) (bool, error) {
+			return string(id) != "5", nil
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected the apply error to be returned")
+	}
+	if res.Applied != 4 || res.Failed != 1 || res.Skipped != 1 {
+		t.Fatalf("Expected 4 applied, 1 failed and 1 skipped; got %+v", res)
+	}
+	if res.Bytes == 0 || res.Duration <= 0 {
+		t.Fatalf("Expected the bytes and duration of the run; got %+v", res)
+	}
+}
+
+func TestDifferential_EachContext(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "run"))
+	err = diff.EachContext(ctx, func(ctx context.Context, id []byte, data Decoder) error {
+		if ctx.Value(key{}) != "run" {
+			t.Fatal("Expected the context of the run")
+		}
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the run to be cancelled; got %v", err)
+	}
+	if n := diff.CountChanges(); n != 3 {
+		t.Fatalf("Expected the cancelled change to remain pending; got %d pending changes", n)
+	}
+}
+
+func TestEachOpts_ZeroCopy(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(opts EachOpts) (retained []Decoder) {
+		for i := 0; i < 2; i++ {
+			if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+			retained = append(retained, data)
+			return nil
+		}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := diff.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// Copies remain valid after the run
+	for i, data := range run(EachOpts{}) {
+		var obj struct{ Object int }
+		if err := data.Decode(&obj); err != nil || obj.Object != i {
+			t.Fatalf("Expected a retained decoder to decode %d; got %d, %v", i, obj.Object, err)
+		}
+	}
+
+	retained := run(EachOpts{ZeroCopy: true})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a retained zero-copy decoder to panic")
+		}
+	}()
+	retained[0].Bytes()
+}
@@ -0,0 +1,114 @@
+package diffdb
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+)
+
+// Payloads that are not encoded using msgpack are prefixed by compressedMarker followed by their encoding,
+// which is numbered after the compression algorithms so that both can share the same marker.
+// Tagged payloads can themselves be compressed, in which case the tag is found after decompressing.
+const (
+	encodingRaw byte = 0x10 + iota
+	encodingBinary
+	encodingCodec
+)
+
+// rawObject is the Object staged by AddRaw.
+type rawObject struct {
+	id      []byte
+	payload []byte
+}
+
+func (o rawObject) ID() []byte {
+	return o.id
+}
+
+// AddRaw adds a pre-encoded payload for id, such as raw JSON returned by an API.
+// The payload is hashed and stored as is, without hashstructure or msgpack,
+// so the source must always produce the same bytes for the same contents.
+//
+// Decoders of raw payloads can only decode into a *[]byte or *interface{}, which receive a copy of the payload,
+// or an encoding.BinaryUnmarshaler.
+func (diff *Differential) AddRaw(id, payload []byte) (bool, error) {
+	return diff.Add(rawObject{id: id, payload: payload})
+}
+
+// AddRawTx adds a pre-encoded payload like AddRaw using an existing transaction.
+func (diff *Differential) AddRawTx(tx Tx, id, payload []byte) (bool, error) {
+	return diff.AddTx(tx, rawObject{id: id, payload: payload})
+}
+
+// encode returns the stored payload of obj before compression.
+// If a Codec is set then it is used for every object except those added using AddRaw.
+// Otherwise objects implementing encoding.BinaryMarshaler are stored using MarshalBinary so that they round-trip exactly.
+func (diff *Differential) encode(obj interface{}) ([]byte, error) {
+	obj = unwrapObject(obj)
+	switch o := obj.(type) {
+	case rawObject:
+		return tagPayload(encodingRaw, o.payload), nil
+	}
+	if c := diff.codec; c != nil {
+		data, err := c.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		return tagCodecPayload(c.Name(), data), nil
+	}
+
+	switch o := obj.(type) {
+	case encoding.BinaryMarshaler:
+		data, err := o.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return tagPayload(encodingBinary, data), nil
+	}
+	return marshal(obj)
+}
+
+func tagPayload(encoding byte, data []byte) []byte {
+	out := make([]byte, 2+len(data))
+	out[0], out[1] = compressedMarker, encoding
+	copy(out[2:], data)
+	return out
+}
+
+// untagPayload returns the encoding of an uncompressed payload and its contents without the tag.
+// msgpack payloads are untagged and are returned with an encoding of zero.
+func untagPayload(data []byte) (byte, []byte) {
+	if len(data) < 2 || data[0] != compressedMarker || data[1] < encodingRaw {
+		return 0, data
+	}
+	return data[1], data[2:]
+}
+
+// decodeRaw decodes a payload stored by AddRaw or encoding.BinaryMarshaler into x.
+func decodeRaw(data []byte, x interface{}) error {
+	switch v := x.(type) {
+	case encoding.BinaryUnmarshaler:
+		return v.UnmarshalBinary(append([]byte(nil), data...))
+	case *[]byte:
+		*v = append([]byte(nil), data...)
+	case *interface{}:
+		*v = append([]byte(nil), data...)
+	default:
+		return fmt.Errorf("diffdb: cannot decode a raw payload into %T", x)
+	}
+	return nil
+}
+
+// hashBytes hashes data with the given width using the same lanes as hashOf.
+func hashBytes(data []byte, width HashWidth) []byte {
+	if width == 0 {
+		width = HashWidth64
+	}
+	b := make([]byte, width)
+	for lane := 0; lane < int(width)/8; lane++ {
+		h := newLaneHash(byte(lane))
+		h.Write(data)
+		binary.LittleEndian.PutUint64(b[lane*8:], h.Sum64())
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_AddRaw(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.CompressPayloads(CompressionSnappy); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"name":"value","padding":"` + string(make([]byte, 100)) + `"}`)
+	if updated, err := diff.AddRaw([]byte("1"), payload); err != nil || !updated {
+		t.Fatalf("Expected the payload to be added; got %v, %v", updated, err)
+	}
+	if updated, err := diff.AddRaw([]byte("1"), payload); err != nil || updated {
+		t.Fatalf("Expected an identical payload to be unchanged; got %v, %v", updated, err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var got []byte
+		if err := data.Decode(&got); err != nil {
+			return err
+		}
+		if string(got) != string(payload) {
+			t.Fatalf("Expected the payload to round trip; got %q", got)
+		}
+
+		var x struct{ Name string }
+		if err := data.Decode(&x); err == nil {
+			t.Fatal("Expected an error decoding a raw payload into a struct")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated, err := diff.AddRaw([]byte("1"), []byte(`{}`)); err != nil || !updated {
+		t.Fatalf("Expected a different payload to be changed; got %v, %v", updated, err)
+	}
+}
+
+// binaryObject encodes its unexported state using a custom binary format.
+type binaryObject struct {
+	Key   string
+	value uint16
+}
+
+func (o *binaryObject) ID() []byte {
+	return []byte(o.Key)
+}
+
+func (o *binaryObject) MarshalBinary() ([]byte, error) {
+	return append([]byte{byte(o.value >> 8), byte(o.value)}, o.Key...), nil
+}
+
+func (o *binaryObject) UnmarshalBinary(data []byte) error {
+	o.value = uint16(data[0])<<8 | uint16(data[1])
+	o.Key = string(data[2:])
+	return nil
+}
+
+func TestDifferential_AddBinaryMarshaler(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(&binaryObject{Key: "1", value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// The binary encoding is hashed, so a change to unexported state is detected
+	if updated, err := diff.Add(&binaryObject{Key: "1", value: 513}); err != nil || !updated {
+		t.Fatalf("Expected a change to the binary encoding to be detected; got %v, %v", updated, err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var o binaryObject
+		if err := data.Decode(&o); err != nil {
+			return err
+		}
+		if o.Key != "1" || o.value != 513 {
+			t.Fatalf("Expected the object to round trip; got %+v", o)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package diffdb
+
+import (
+	"fmt"
+)
+
+// Error wraps an error concerning a single ID of a differential, so that callers can tell which object or change failed.
+// The wrapped error, such as ErrNotFound, ErrHashMismatch or ErrQuotaExceeded, can be matched using errors.Is.
+type Error struct {
+	Differential string
+	ID           []byte
+	Err          error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (differential %q, id %q)", e.Err, e.Differential, e.ID)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// wrapErr returns err wrapped in an *Error for id, or nil if err is nil.
+func (diff *Differential) wrapErr(id []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Differential: diff.Name(), ID: copyBytes(id), Err: err}
+}
+
+// ConflictError is returned by Add when MustNotConflict is enabled and an ID is added more than once
+// in the same change version. It matches ErrConflictingKey using errors.Is.
+type ConflictError struct {
+	Differential string
+	ID           []byte
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: id %q in differential %q", ErrConflictingKey, e.ID, e.Differential)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflictingKey
+}
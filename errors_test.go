@@ -0,0 +1,41 @@
+package diffdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v int
+	err = diff.Get([]byte("1"), &v)
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected an *Error wrapping ErrNotFound; got %v", err)
+	}
+	if e.Differential != "test" || string(e.ID) != "1" {
+		t.Fatalf("Expected the differential and ID of the error; got %+v", e)
+	}
+
+	if err := diff.MustNotConflict(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = diff.Add(NewIDObject([]byte("1"), 2))
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflictingKey) {
+		t.Fatalf("Expected a *ConflictError; got %v", err)
+	}
+	if conflict.Differential != "test" || string(conflict.ID) != "1" {
+		t.Fatalf("Expected the differential and ID of the conflict; got %+v", conflict)
+	}
+}
@@ -0,0 +1,94 @@
+package diffdb
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// An EvictAction is what happens to a pending change that is evicted for being too old.
+type EvictAction int
+
+const (
+	// EvictDiscard discards evicted changes as if by DiscardPendingID.
+	EvictDiscard EvictAction = iota
+	// EvictDeadLetter moves evicted changes to the dead-letter bucket, where they can be inspected and requeued.
+	EvictDeadLetter
+)
+
+// EvictPendingOlderThan evicts pending changes that were staged more than d ago using action,
+// preventing a backlog from growing forever when the target system is no longer applying changes.
+// In-flight changes and changes staged before staged times were recorded are never evicted.
+// It returns the number of evicted changes.
+func (diff *Differential) EvictPendingOlderThan(d time.Duration, action EvictAction) (n int, err error) {
+	err = diff.db.update(func(tx Tx) error {
+		var e error
+		n, e = diff.evict(tx, tx.Bucket(diff.q), d, action)
+		return e
+	})
+	if err != nil {
+		return 0, err
+	}
+	return
+}
+
+// SetPendingMaxAge evicts pending changes older than d using action at the start of every apply run started by Each,
+// as if by calling EvictPendingOlderThan just before the run. Setting d to zero disables automatic eviction.
+func (diff *Differential) SetPendingMaxAge(d time.Duration, action EvictAction) {
+	diff.maxPendingAge = d
+	diff.evictAction = action
+}
+
+// evict evicts pending changes of b older than d.
+func (diff *Differential) evict(tx Tx, b Bucket, d time.Duration, action EvictAction) (int, error) {
+	bsa := b.Bucket(bucketStagedAt)
+	if bsa == nil {
+		return 0, nil
+	}
+
+	var (
+		cutoff  = time.Now().Add(-d)
+		evicted [][]byte
+	)
+	err := bsa.ForEach(func(id, v []byte) error {
+		if decodeTime(v).Before(cutoff) && !inFlight(b, id) {
+			evicted = append(evicted, append([]byte(nil), id...))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range evicted {
+		if action != EvictDeadLetter {
+			if err := diff.discardPending(tx, b, id); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		hash, data := b.Bucket(bucketPendingHashes).Get(id), b.Bucket(bucketPendingData).Get(id)
+		if hash == nil || data == nil {
+			continue
+		}
+		var fc FailedChange
+		if v := b.Bucket(bucketFailures).Get(id); v != nil {
+			if err := msgpack.Unmarshal(v, &fc); err != nil {
+				return 0, err
+			}
+		}
+		fc.LastError = fmt.Sprintf("diffdb: evicted after being pending for longer than %s", d)
+		fc.LastAttempt = time.Now().UTC()
+		if err := diff.deadLetter(tx, b, id, hash, data, fc); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(evicted) > 0 {
+		diff.log(slog.LevelWarn, "evicted stale pending changes", slog.Int("count", len(evicted)), slog.Duration("age", d))
+	}
+	return len(evicted), nil
+}
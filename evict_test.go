@@ -0,0 +1,79 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDifferential_EvictPendingOlderThan(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		action      EvictAction
+		deadLetters int
+	}{
+		{"Discard", EvictDiscard, 0},
+		{"DeadLetter", EvictDeadLetter, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			db := NewMemory()
+			defer db.Close()
+
+			diff, err := db.Open("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := diff.EvictPendingOlderThan(time.Hour, c.action); err != nil || n != 0 {
+				t.Fatalf("Expected no evicted changes; got %d, %v", n, err)
+			}
+			if n, err := diff.EvictPendingOlderThan(0, c.action); err != nil || n != 1 {
+				t.Fatalf("Expected 1 evicted change; got %d, %v", n, err)
+			}
+
+			if pending := diff.CountChanges(); pending != 0 {
+				t.Fatalf("Expected no pending changes; got %d", pending)
+			}
+			letters, err := diff.DeadLetters()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(letters) != c.deadLetters {
+				t.Fatalf("Expected %d dead letters; got %d", c.deadLetters, len(letters))
+			}
+		})
+	}
+}
+
+func TestDifferential_SetPendingMaxAge(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := diff.Add(NewIDObject([]byte("2"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	diff.SetPendingMaxAge(25*time.Millisecond, EvictDiscard)
+
+	var applied []string
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		applied = append(applied, string(id))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0] != "2" {
+		t.Fatalf("Expected only the recent change to be applied; got %v", applied)
+	}
+}
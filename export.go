@@ -0,0 +1,75 @@
+package diffdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A Format is an encoding used to export pending changes.
+type Format int
+
+const (
+	// NDJSON writes each pending change as a JSON object on its own line
+	// containing the ID as a string, the hex encoded hash, and the decoded payload.
+	NDJSON Format = iota
+)
+
+func (f Format) String() string {
+	switch f {
+	case NDJSON:
+		return "ndjson"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// exportRecord is the JSON representation of a pending change.
+type exportRecord struct {
+	ID   string      `json:"id"`
+	Hash string      `json:"hash"`
+	Data interface{} `json:"data"`
+}
+
+// Export streams all pending changes to w in the given format without consuming them.
+func (diff *Differential) Export(w io.Writer, format Format) error {
+	if format != NDJSON {
+		return fmt.Errorf("diffdb: unsupported export format %s", format)
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	err := diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		var (
+			bph = b.Bucket(bucketPendingHashes)
+			bpd = b.Bucket(bucketPendingData)
+		)
+
+		return bph.ForEach(func(id, hash []byte) error {
+			data := bpd.Get(id)
+			if data == nil {
+				return missingPayload(diff.Name(), id)
+			}
+
+			var x interface{}
+			if err := (&msgpackDecoder{data: data}).Decode(&x); err != nil {
+				return err
+			}
+
+			return enc.Encode(exportRecord{
+				ID:   string(id),
+				Hash: hex.EncodeToString(hash),
+				Data: jsonValue(x),
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
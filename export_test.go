@@ -0,0 +1,65 @@
+package diffdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDifferential_Export(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := []IDObject{
+		NewIDObject([]byte("1"), map[string]interface{}{"Name": "one"}),
+		NewIDObject([]byte("2"), map[string]interface{}{"Name": "two"}),
+	}
+	for _, obj := range objects {
+		if _, err := diff.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := diff.Export(&buf, NDJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []exportRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r struct {
+			ID   string
+			Hash string
+			Data struct {
+				Object struct {
+					Name string
+				}
+			}
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, exportRecord{ID: r.ID, Hash: r.Hash, Data: r.Data.Object.Name})
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 exported records; got %d", len(records))
+	}
+	if records[0].ID != "1" || records[0].Data != "one" || records[1].ID != "2" || records[1].Data != "two" {
+		t.Fatalf("Unexpected exported records %+v", records)
+	}
+	if len(records[0].Hash) != 16 {
+		t.Fatalf("Expected a hex encoded 64-bit hash; got %q", records[0].Hash)
+	}
+
+	if pending := diff.CountChanges(); pending != 2 {
+		t.Fatalf("Expected export to leave 2 pending changes; got %d", pending)
+	}
+}
@@ -0,0 +1,32 @@
+package diffdb
+
+// Forget stops tracking id, removing its committed hash, any pending or dead-lettered change,
+// and any retained payload and history, so that the next Add of id is treated as brand new.
+// This is needed when the target system has lost an object outside of the differential,
+// such as a row that was deleted by hand, and it must be recreated on the next sync.
+//
+// Unlike ExpireOlderThan no EventExpired event or journal entry is recorded, because the target system
+// is assumed to no longer have the object. An EventDiscarded event is emitted if a pending change is removed.
+func (diff *Differential) Forget(id []byte) error {
+	return diff.db.update(func(tx Tx) error {
+		return diff.ForgetTx(tx, id)
+	})
+}
+
+// ForgetTx is like Forget using an existing transaction.
+func (diff *Differential) ForgetTx(tx Tx, id []byte) error {
+	if diff.db.readOnly {
+		return ErrReadOnly
+	}
+
+	b := tx.Bucket(diff.q)
+	if err := diff.discardPending(tx, b, id); err != nil {
+		return err
+	}
+	if bdl := b.Bucket(bucketDeadLetters); bdl != nil {
+		if err := bdl.Delete(id); err != nil {
+			return err
+		}
+	}
+	return removeCommitted(b, id)
+}
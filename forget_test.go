@@ -0,0 +1,48 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDifferential_Forget(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := diff.Forget([]byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := diff.Exists([]byte("1")); err != nil || exists {
+		t.Fatalf("Expected the ID to be forgotten; got %v, %v", exists, err)
+	}
+	var v int
+	if err := diff.Get([]byte("1"), &v); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %q; got %v", ErrNotFound, err)
+	}
+
+	// The previously committed version is now seen as new
+	if updated, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil || !updated {
+		t.Fatalf("Expected the object to be added; got %v, %v", updated, err)
+	}
+}
@@ -0,0 +1,158 @@
+package diffdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMissingPayload indicates that a pending change has no stored payload.
+// The differential can be repaired by calling GC.
+var ErrMissingPayload = errors.New("diffdb: missing hash data")
+
+// bucketCorruptPending records the IDs of corrupt pending changes skipped by apply runs, see EachOpts.SkipCorrupt.
+var bucketCorruptPending = []byte("_cp")
+
+// CorruptPendingError is returned for a pending change whose state is inconsistent, such as a change without a stored payload.
+// It matches ErrMissingPayload using errors.Is.
+type CorruptPendingError struct {
+	Differential string
+	ID           []byte
+}
+
+func (e *CorruptPendingError) Error() string {
+	return fmt.Sprintf("%s for %q in differential %q", ErrMissingPayload, e.ID, e.Differential)
+}
+
+func (e *CorruptPendingError) Is(target error) bool {
+	return target == ErrMissingPayload
+}
+
+// missingPayload returns a *CorruptPendingError for id in the differential name.
+func missingPayload(name string, id []byte) error {
+	return &CorruptPendingError{Differential: name, ID: copyBytes(id)}
+}
+
+// recordCorrupt records id as a corrupt pending change to be repaired.
+func recordCorrupt(b Bucket, id []byte) error {
+	bcp, err := b.CreateBucketIfNotExists(bucketCorruptPending)
+	if err != nil {
+		return err
+	}
+	return bcp.Put(id, encodeTime(time.Now()))
+}
+
+// CorruptPending returns the IDs of corrupt pending changes that were skipped by apply runs using EachOpts.SkipCorrupt
+// and have not yet been repaired by GC or by adding them again.
+func (diff *Differential) CorruptPending() (ids [][]byte, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		bcp := b.Bucket(bucketCorruptPending)
+		if bcp == nil {
+			return nil
+		}
+		var (
+			bph = b.Bucket(bucketPendingHashes)
+			bpd = b.Bucket(bucketPendingData)
+		)
+		return bcp.ForEach(func(id, _ []byte) error {
+			if bph.Get(id) != nil && bpd.Get(id) == nil {
+				ids = append(ids, copyBytes(id))
+			}
+			return nil
+		})
+	})
+	return
+}
+
+// A GCReport describes the inconsistencies found and repaired by GC.
+type GCReport struct {
+	// MissingPayloads are the IDs of pending changes that were discarded because their payload was missing.
+	MissingPayloads [][]byte
+	// OrphanedPayloads is the number of stored payloads that were removed because no pending change referenced them.
+	OrphanedPayloads int
+	// OrphanedFailures is the number of failure records that were removed because their change was no longer pending.
+	OrphanedFailures int
+}
+
+// Clean returns true if no inconsistencies were found.
+func (r GCReport) Clean() bool {
+	return len(r.MissingPayloads) == 0 && r.OrphanedPayloads == 0 && r.OrphanedFailures == 0
+}
+
+// GC scans the differential for inconsistencies between pending changes, their payloads and failure records,
+// and removes anything that cannot be repaired.
+// Pending changes without a payload cannot be applied, so they are discarded and must be added again.
+func (diff *Differential) GC() (report GCReport, err error) {
+	err = diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		var (
+			bph = b.Bucket(bucketPendingHashes)
+			bpd = b.Bucket(bucketPendingData)
+			bfa = b.Bucket(bucketFailures)
+		)
+
+		err := bph.ForEach(func(id, _ []byte) error {
+			if bpd.Get(id) == nil {
+				report.MissingPayloads = append(report.MissingPayloads, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range report.MissingPayloads {
+			if err := diff.discardPending(tx, b, id); err != nil {
+				return err
+			}
+		}
+
+		var orphaned [][]byte
+		err = bpd.ForEach(func(id, _ []byte) error {
+			if bph.Get(id) == nil {
+				orphaned = append(orphaned, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range orphaned {
+			if err := trackUsage(b, id, nil); err != nil {
+				return err
+			}
+			if err := bpd.Delete(id); err != nil {
+				return err
+			}
+		}
+		report.OrphanedPayloads = len(orphaned)
+
+		orphaned = orphaned[:0]
+		err = bfa.ForEach(func(id, _ []byte) error {
+			if bph.Get(id) == nil {
+				orphaned = append(orphaned, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range orphaned {
+			if err := bfa.Delete(id); err != nil {
+				return err
+			}
+		}
+		report.OrphanedFailures = len(orphaned)
+
+		// Every corrupt pending change has now been repaired
+		if b.Bucket(bucketCorruptPending) != nil {
+			return b.DeleteBucket(bucketCorruptPending)
+		}
+		return nil
+	})
+	if err != nil {
+		return GCReport{}, err
+	}
+	return
+}
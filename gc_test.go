@@ -0,0 +1,124 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDifferential_GC(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Remove the payload of 1 and leave behind an unreferenced payload and failure record
+	err = db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		if err := b.Bucket(bucketPendingData).Delete([]byte("1")); err != nil {
+			return err
+		}
+		if err := b.Bucket(bucketPendingData).Put([]byte("orphan"), []byte{}); err != nil {
+			return err
+		}
+		return b.Bucket(bucketFailures).Put([]byte("3"), []byte{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if !errors.Is(err, ErrMissingPayload) {
+		t.Fatalf("Expected %q; got %v", ErrMissingPayload, err)
+	}
+
+	report, err := diff.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.MissingPayloads) != 1 || string(report.MissingPayloads[0]) != "1" {
+		t.Fatalf("Expected missing payload for 1; got %v", report.MissingPayloads)
+	}
+	if report.OrphanedPayloads != 1 || report.OrphanedFailures != 1 {
+		t.Fatalf("Expected 1 orphaned payload and failure; got %+v", report)
+	}
+
+	if report, err := diff.GC(); err != nil || !report.Clean() {
+		t.Fatalf("Expected a clean report after repair; got %+v, %v", report, err)
+	}
+
+	var applied []string
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		applied = append(applied, string(id))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0] != "2" {
+		t.Fatalf("Expected only 2 to be applied; got %v", applied)
+	}
+}
+
+func TestEachOpts_SkipCorrupt(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.update(func(tx Tx) error {
+		return tx.Bucket(diff.q).Bucket(bucketPendingData).Delete([]byte("1"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	var corrupt *CorruptPendingError
+	if !errors.As(err, &corrupt) || string(corrupt.ID) != "1" {
+		t.Fatalf("Expected a corrupt pending error for 1; got %v", err)
+	}
+
+	res, err := diff.EachWithResult(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, EachOpts{SkipCorrupt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Applied != 1 || res.Skipped != 1 || len(res.Corrupt) != 1 || string(res.Corrupt[0]) != "1" {
+		t.Fatalf("Expected 1 applied and 1 corrupt change; got %+v", res)
+	}
+
+	ids, err := diff.CorruptPending()
+	if err != nil || len(ids) != 1 || string(ids[0]) != "1" {
+		t.Fatalf("Expected 1 to be recorded for repair; got %q, %v", ids, err)
+	}
+
+	if _, err := diff.GC(); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := diff.CorruptPending(); err != nil || len(ids) != 0 {
+		t.Fatalf("Expected no corrupt changes after repair; got %q, %v", ids, err)
+	}
+}
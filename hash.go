@@ -0,0 +1,161 @@
+package diffdb
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/mitchellh/hashstructure"
+)
+
+// A HashWidth is the number of bytes in the hashes a differential uses to detect changes.
+// Wider hashes make collisions between objects negligible when tracking many millions of IDs,
+// at the cost of hashing each object once for every 64 bits.
+type HashWidth int
+
+const (
+	// HashWidth64 is the default width, as returned by HashOf.
+	HashWidth64 HashWidth = 8
+	// HashWidth128 uses 128-bit hashes.
+	HashWidth128 HashWidth = 16
+	// HashWidth256 uses 256-bit hashes.
+	HashWidth256 HashWidth = 32
+)
+
+var infoHashWidth = []byte("hash_width")
+
+func (w HashWidth) valid() bool {
+	switch w {
+	case HashWidth64, HashWidth128, HashWidth256:
+		return true
+	default:
+		return false
+	}
+}
+
+// OpenOptions configures a differential created by DB.OpenWithOptions.
+type OpenOptions struct {
+	// HashWidth is the width of the hashes used by a new differential.
+	// The width is recorded when the differential is created and cannot be changed afterwards.
+	// When zero, HashWidth64 is used.
+	HashWidth HashWidth
+
+	// Mirror enables mirror mode on the differential, see Differential.Mirror.
+	// Once enabled it is recorded in the differential, so it does not need to be given again.
+	Mirror bool
+}
+
+// hashOf returns the hash of x with the given width.
+// The first 64 bits are always equal to HashOf(x),
+// every further 64 bits are computed using a differently seeded hash function.
+func hashOf(x interface{}, width HashWidth) ([]byte, error) {
+	if width == 0 || width == HashWidth64 {
+		return HashOf(x)
+	}
+
+	lanes := laneHashOptions.Get().(*[HashWidth256 / 8]*hashstructure.HashOptions)
+	defer laneHashOptions.Put(lanes)
+
+	b := make([]byte, width)
+	for lane := 0; lane < int(width)/8; lane++ {
+		i, err := hashstructure.Hash(x, lanes[lane])
+		if err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint64(b[lane*8:], i)
+	}
+	return b, nil
+}
+
+// laneHash is an FNV-1 hash whose state is seeded with a lane number after every Reset.
+// Lane 0 is unseeded so that it matches the hash function used by HashOf.
+type laneHash struct {
+	hash.Hash64
+	lane byte
+}
+
+func newLaneHash(lane byte) hash.Hash64 {
+	h := &laneHash{Hash64: fnv.New64(), lane: lane}
+	h.Reset()
+	return h
+}
+
+func (h *laneHash) Reset() {
+	h.Hash64.Reset()
+	if h.lane > 0 {
+		h.Hash64.Write([]byte{h.lane})
+	}
+}
+
+// hash returns the hash of x using the width of the differential.
+// The version of an ObjectVersioned is used in place of its hash,
+// and the payload of AddRaw or the output of the Codec or encoding.BinaryMarshaler is hashed directly
+// so that state hidden in unexported fields is detected.
+func (diff *Differential) hash(x interface{}) ([]byte, error) {
+	x = unwrapObject(x)
+	switch v := x.(type) {
+	case ObjectVersioned:
+		return encodeVersion(v.Version()), nil
+	case rawObject:
+		return hashBytes(v.payload, diff.hashWidth), nil
+	}
+	if c := diff.codec; c != nil {
+		data, err := c.Marshal(x)
+		if err != nil {
+			return nil, err
+		}
+		return hashBytes(data, diff.hashWidth), nil
+	}
+
+	switch v := x.(type) {
+	case encoding.BinaryMarshaler:
+		data, err := v.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return hashBytes(data, diff.hashWidth), nil
+	}
+	return hashOf(x, diff.hashWidth)
+}
+
+// HashWidth returns the width of the hashes used by the differential.
+func (diff *Differential) HashWidth() HashWidth {
+	return diff.hashWidth
+}
+
+// loadHashWidth returns the hash width recorded in the metadata of b.
+// Differentials created before the width was recorded use HashWidth64.
+func loadHashWidth(b Bucket) HashWidth {
+	if bmd := b.Bucket(bucketMetadata); bmd != nil {
+		if v := bmd.Get(infoHashWidth); len(v) == 1 {
+			return HashWidth(v[0])
+		}
+	}
+	return HashWidth64
+}
+
+// initHashWidth records the hash width of b if it is not already set and returns the width in use.
+// The requested width only applies to a newly created differential and must otherwise match the recorded width.
+func initHashWidth(b Bucket, name string, requested HashWidth, created bool) (HashWidth, error) {
+	if requested != 0 && !requested.valid() {
+		return 0, fmt.Errorf("diffdb: invalid hash width %d", requested)
+	}
+
+	bmd := b.Bucket(bucketMetadata)
+	recorded := bmd.Get(infoHashWidth) != nil
+
+	width := loadHashWidth(b)
+	if !recorded && created && requested != 0 {
+		width = requested
+	}
+	if requested != 0 && requested != width {
+		return 0, fmt.Errorf("diffdb: differential %q uses %d-bit hashes", name, width*8)
+	}
+
+	if recorded {
+		return width, nil
+	}
+	return width, bmd.Put(infoHashWidth, []byte{byte(width)})
+}
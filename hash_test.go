@@ -0,0 +1,69 @@
+package diffdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHashOf_Width(t *testing.T) {
+	x := struct{ Key, Value string }{"1", "value"}
+
+	short, err := HashOf(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, width := range []HashWidth{HashWidth128, HashWidth256} {
+		hash, err := hashOf(x, width)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hash) != int(width) {
+			t.Fatalf("Expected a %d byte hash; got %d", width, len(hash))
+		}
+		if !bytes.Equal(hash[:8], short) {
+			t.Fatal("Expected the first 64 bits to match HashOf")
+		}
+		if bytes.Equal(hash[:8], hash[8:16]) {
+			t.Fatal("Expected each lane to use a different seed")
+		}
+	}
+}
+
+func TestDB_OpenWithOptions_HashWidth(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.OpenWithOptions("test", &OpenOptions{HashWidth: HashWidth128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The recorded width is used on reopen
+	diff, err = db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.HashWidth() != HashWidth128 {
+		t.Fatalf("Expected 128-bit hashes; got %d", diff.HashWidth()*8)
+	}
+	if updated, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil || updated {
+		t.Fatalf("Expected an unchanged object; got %v, %v", updated, err)
+	}
+
+	if _, err := db.OpenWithOptions("test", &OpenOptions{HashWidth: HashWidth256}); err == nil {
+		t.Fatal("Expected an error when reopening with a different width")
+	}
+	if _, err := db.OpenWithOptions("other", &OpenOptions{HashWidth: 3}); err == nil {
+		t.Fatal("Expected an error for an invalid width")
+	}
+
+	if _, err := db.Open("default"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge("default", "test", MergePreferSrc); err == nil {
+		t.Fatal("Expected an error when merging differentials with different widths")
+	}
+}
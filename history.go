@@ -0,0 +1,108 @@
+package diffdb
+
+import (
+	"encoding/binary"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// A Version is a previously applied payload of an ID retained by RetainHistory.
+type Version struct {
+	// Hash is the hash of the object when it was applied.
+	Hash []byte
+	// Time is when the change was applied.
+	Time time.Time
+	// Payload is the serialised object.
+	Payload []byte
+}
+
+// Decode decodes the payload of the version into x.
+// x should be the same type as the object originally added.
+func (v Version) Decode(x interface{}) error {
+	return (&msgpackDecoder{data: v.Payload}).Decode(x)
+}
+
+// RetainHistory keeps the last n applied payloads of each ID so that they can be retrieved using History.
+// Setting n to zero stops recording new history but retains existing history.
+func (diff *Differential) RetainHistory(n int) error {
+	if n < 0 {
+		n = 0
+	}
+	return diff.db.update(func(tx Tx) error {
+		tx.OnCommit(func() {
+			diff.historyN = n
+		})
+
+		_, err := tx.Bucket(diff.q).CreateBucketIfNotExists(bucketHistory)
+		return err
+	})
+}
+
+// History returns the retained versions of id ordered from oldest to newest.
+// If no history is available for id then an empty slice is returned.
+func (diff *Differential) History(id []byte) ([]Version, error) {
+	var versions []Version
+	err := diff.db.view(func(tx Tx) error {
+		bhi := tx.Bucket(diff.q).Bucket(bucketHistory)
+		if bhi == nil {
+			return nil
+		}
+		hb := bhi.Bucket(id)
+		if hb == nil {
+			return nil
+		}
+
+		return hb.ForEach(func(_, v []byte) error {
+			var version Version
+			if err := msgpack.Unmarshal(v, &version); err != nil {
+				return err
+			}
+			versions = append(versions, version)
+			return nil
+		})
+	})
+	return versions, err
+}
+
+// appendHistory records a newly applied payload of id, removing the oldest versions beyond the retention limit.
+func (diff *Differential) appendHistory(b Bucket, id, hash, data []byte) error {
+	hb, err := b.Bucket(bucketHistory).CreateBucketIfNotExists(id)
+	if err != nil {
+		return err
+	}
+
+	seq, err := hb.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	raw, err := msgpack.Marshal(&Version{
+		Hash:    hash,
+		Time:    time.Now().UTC(),
+		Payload: data,
+	})
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	if err := hb.Put(key, raw); err != nil {
+		return err
+	}
+
+	// KeyN is not updated until a BoltDB transaction commits, so count the versions with a cursor
+	var keys [][]byte
+	c := hb.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for len(keys) > diff.historyN {
+		if err := hb.Delete(keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_History(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainHistory(2); err != nil {
+		t.Fatal(err)
+	}
+
+	id := []byte("1")
+	for i := 1; i <= 3; i++ {
+		if _, err := diff.Add(NewIDObject(id, i)); err != nil {
+			t.Fatal(err)
+		}
+		err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := diff.History(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 retained versions; got %d", len(versions))
+	}
+
+	for i, v := range versions {
+		var x struct{ Object int }
+		if err := v.Decode(&x); err != nil {
+			t.Fatal(err)
+		}
+		if x.Object != i+2 {
+			t.Fatalf("Expected version %d to contain %d; got %d", i, i+2, x.Object)
+		}
+		if v.Time.IsZero() || len(v.Hash) == 0 {
+			t.Fatalf("Expected version %d to have a hash and time", i)
+		}
+	}
+
+	versions, err = diff.History([]byte("missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("Expected no history for a missing ID; got %d", len(versions))
+	}
+}
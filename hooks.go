@@ -0,0 +1,39 @@
+package diffdb
+
+// A Hook is called with the ID and hash of a change after the transaction containing it has committed.
+// Hooks are called synchronously by the goroutine that committed the transaction, so they should return quickly.
+type Hook func(id, hash []byte)
+
+// OnAdd registers a hook called after a new pending change is staged.
+func (diff *Differential) OnAdd(h Hook) {
+	diff.addHook(EventStaged, h)
+}
+
+// OnApply registers a hook called after a pending change is successfully applied.
+func (diff *Differential) OnApply(h Hook) {
+	diff.addHook(EventApplied, h)
+}
+
+// OnDiscard registers a hook called after a pending change is removed without being applied,
+// either explicitly or because it was dead-lettered.
+func (diff *Differential) OnDiscard(h Hook) {
+	diff.addHook(EventDiscarded, h)
+}
+
+// OnExpire registers a hook called after a tracked ID is expired by ExpireOlderThan.
+func (diff *Differential) OnExpire(h Hook) {
+	diff.addHook(EventExpired, h)
+}
+
+func (diff *Differential) addHook(kind EventKind, h Hook) {
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+
+	if diff.hooks == nil {
+		diff.hooks = make(map[EventKind][]Hook)
+	}
+	// Copy on write so that hooks being called outside of the lock are not modified
+	hooks := make([]Hook, len(diff.hooks[kind]), len(diff.hooks[kind])+1)
+	copy(hooks, diff.hooks[kind])
+	diff.hooks[kind] = append(hooks, h)
+}
@@ -0,0 +1,49 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_Hooks(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var added, applied, discarded []string
+	diff.OnAdd(func(id, hash []byte) { added = append(added, string(id)) })
+	diff.OnApply(func(id, hash []byte) { applied = append(applied, string(id)) })
+	diff.OnDiscard(func(id, hash []byte) { discarded = append(discarded, string(id)) })
+
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := diff.DiscardPendingID([]byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	err = diff.EachN(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.DiscardPending(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(added) != 3 {
+		t.Fatalf("Expected 3 add hooks; got %v", added)
+	}
+	if len(applied) != 1 || applied[0] != "2" {
+		t.Fatalf("Expected apply hook for 2; got %v", applied)
+	}
+	if len(discarded) != 2 || discarded[0] != "1" || discarded[1] != "3" {
+		t.Fatalf("Expected discard hooks for 1 and 3; got %v", discarded)
+	}
+}
@@ -0,0 +1,168 @@
+package diffdb
+
+import (
+	"fmt"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+var (
+	bucketIndexes   = []byte("_ix") // index name to entries keyed by value and ID
+	bucketIndexKeys = []byte("_ik") // index name to the indexed values of each ID
+)
+
+// An IndexFunc extracts the values of a secondary index from a committed object, such as an email address or a set of tags.
+// data decodes the object like the Decoder given to ApplyFunc. An object may have any number of values, including none.
+type IndexFunc func(id []byte, data Decoder) ([][]byte, error)
+
+// AddIndex declares a secondary index named name whose values are extracted from committed objects using f,
+// so that IDs can be looked up by value using QueryIndex. The index is updated as changes are applied, seeded or replicated,
+// and entries are removed when an ID expires or is forgotten.
+//
+// If payloads are retained using RetainPayloads then the index is built from the objects that are already committed,
+// otherwise only objects committed from now on are indexed. Indexes are not persisted,
+// so AddIndex must be called each time the differential is opened.
+func (diff *Differential) AddIndex(name string, f IndexFunc) error {
+	return diff.db.update(func(tx Tx) error {
+		tx.OnCommit(func() {
+			diff.mu.Lock()
+			defer diff.mu.Unlock()
+			if diff.indexes == nil {
+				diff.indexes = make(map[string]IndexFunc)
+			}
+			diff.indexes[name] = f
+		})
+
+		b := tx.Bucket(diff.q)
+		if _, err := indexBuckets(b, name); err != nil {
+			return err
+		}
+		bcd := b.Bucket(bucketCommittedData)
+		if bcd == nil {
+			return nil
+		}
+		return bcd.ForEach(func(id, data []byte) error {
+			return updateIndex(b, name, f, id, data)
+		})
+	})
+}
+
+// QueryIndex returns the IDs whose objects have value in the index name, in ID order.
+// The index must have been added to this Differential using AddIndex, as entries are not kept up to date otherwise.
+func (diff *Differential) QueryIndex(name string, value []byte) (ids [][]byte, err error) {
+	diff.mu.Lock()
+	_, ok := diff.indexes[name]
+	diff.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("diffdb: index %q has not been added", name)
+	}
+
+	err = diff.db.view(func(tx Tx) error {
+		bix := tx.Bucket(diff.q).Bucket(bucketIndexes)
+		if bix == nil || bix.Bucket([]byte(name)) == nil {
+			return fmt.Errorf("diffdb: index %q does not exist", name)
+		}
+
+		c := withPrefix(bix.Bucket([]byte(name)).Cursor(), CompositeKey(value))
+		for k, id := c.First(); k != nil; k, id = c.Next() {
+			ids = append(ids, copyBytes(id))
+		}
+		return nil
+	})
+	return
+}
+
+// indexBuckets returns the entry and value buckets of the index name, creating them if necessary.
+func indexBuckets(b Bucket, name string) ([2]Bucket, error) {
+	var buckets [2]Bucket
+	for i, parent := range [][]byte{bucketIndexes, bucketIndexKeys} {
+		p, err := b.CreateBucketIfNotExists(parent)
+		if err != nil {
+			return buckets, err
+		}
+		if buckets[i], err = p.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return buckets, err
+		}
+	}
+	return buckets, nil
+}
+
+// indexed returns true if the differential has any indexes.
+func (diff *Differential) indexed() bool {
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+	return len(diff.indexes) > 0
+}
+
+// index updates every index with the committed payload data of id.
+func (diff *Differential) index(b Bucket, id, data []byte) error {
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+	for name, f := range diff.indexes {
+		if err := updateIndex(b, name, f, id, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateIndex replaces the entries of id in the index name with the values extracted from data using f.
+func updateIndex(b Bucket, name string, f IndexFunc, id, data []byte) error {
+	values, err := f(id, &msgpackDecoder{data: data})
+	if err != nil {
+		return fmt.Errorf("diffdb: index %q of %q: %w", name, id, err)
+	}
+
+	buckets, err := indexBuckets(b, name)
+	if err != nil {
+		return err
+	}
+	bix, bik := buckets[0], buckets[1]
+	if err := unindex(bix, bik, id); err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	for _, v := range values {
+		if err := bix.Put(CompositeKey(v, id), id); err != nil {
+			return err
+		}
+	}
+	raw, err := msgpack.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return bik.Put(id, raw)
+}
+
+// unindex removes the entries of id from an index.
+func unindex(bix, bik Bucket, id []byte) error {
+	raw := bik.Get(id)
+	if raw == nil {
+		return nil
+	}
+	var values [][]byte
+	if err := msgpack.Unmarshal(raw, &values); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := bix.Delete(CompositeKey(v, id)); err != nil {
+			return err
+		}
+	}
+	return bik.Delete(id)
+}
+
+// clearIndexes removes the entries of id from every index of b.
+func clearIndexes(b Bucket, id []byte) error {
+	bik := b.Bucket(bucketIndexKeys)
+	if bik == nil {
+		return nil
+	}
+	bix := b.Bucket(bucketIndexes)
+	return bik.ForEach(func(name, _ []byte) error {
+		return unindex(bix.Bucket(name), bik.Bucket(name), id)
+	})
+}
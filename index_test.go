@@ -0,0 +1,124 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+type indexRow struct {
+	Name string
+	Tags []string
+}
+
+func (r indexRow) ID() []byte {
+	return []byte(r.Name)
+}
+
+func TestDifferential_AddIndex(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+
+	apply := func(rows ...indexRow) {
+		for _, r := range rows {
+			if _, err := diff.Add(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query := func(tag string, expect ...string) {
+		t.Helper()
+		ids, err := diff.QueryIndex("tag", []byte(tag))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != len(expect) {
+			t.Fatalf("Expected %q for %s; got %q", expect, tag, ids)
+		}
+		for i := range expect {
+			if string(ids[i]) != expect[i] {
+				t.Fatalf("Expected %q for %s; got %q", expect, tag, ids)
+			}
+		}
+	}
+
+	// Objects committed before the index is declared are indexed from their retained payloads
+	apply(indexRow{"a", []string{"red"}})
+
+	err = diff.AddIndex("tag", func(id []byte, data Decoder) ([][]byte, error) {
+		var r indexRow
+		if err := data.Decode(&r); err != nil {
+			return nil, err
+		}
+		values := make([][]byte, len(r.Tags))
+		for i, tag := range r.Tags {
+			values[i] = []byte(tag)
+		}
+		return values, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apply(indexRow{"b", []string{"red", "blue"}}, indexRow{"c", []string{"redder"}})
+	query("red", "a", "b")
+	query("blue", "b")
+
+	apply(indexRow{"a", []string{"blue"}})
+	query("red", "b")
+	query("blue", "a", "b")
+
+	if err := diff.Forget([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	query("red")
+	query("blue", "a")
+	query("redder", "c")
+
+	if _, err := diff.QueryIndex("missing", []byte("red")); err == nil {
+		t.Fatal("Expected an error querying an undeclared index")
+	}
+
+	// A merged ID is indexed under its new value
+	src, err := db.Open("src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Add(indexRow{"a", []string{"red"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge("test", "src", MergePreferSrc); err != nil {
+		t.Fatal(err)
+	}
+	query("red", "a")
+	query("blue")
+
+	// The index is not maintained by a handle that has not added it
+	reopened, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.QueryIndex("tag", []byte("red")); err == nil {
+		t.Fatal("Expected an error querying an index that has not been added to the handle")
+	}
+}
@@ -0,0 +1,98 @@
+package diffdb
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+var (
+	infoCreated   = []byte("created")
+	infoLastAdd   = []byte("last_add")
+	infoLastApply = []byte("last_apply")
+	infoRuns      = []byte("runs")
+)
+
+// Info describes the lifetime of a differential.
+// It is maintained automatically as changes are added and applied.
+type Info struct {
+	Name string
+	// Created is when the differential was created.
+	// For differentials created by an earlier version it is the first time the differential was opened by this version.
+	Created time.Time
+	// LastAdd is when an object was last added.
+	LastAdd time.Time
+	// LastApply is when an apply run last completed.
+	LastApply time.Time
+	// Runs is the total number of completed apply runs.
+	// Runs are numbered sequentially from 1, so it is also the sequence number of the most recent run.
+	Runs uint64
+}
+
+// Info returns the metadata of the differential.
+func (diff *Differential) Info() (info Info, err error) {
+	info.Name = diff.Name()
+	err = diff.db.view(func(tx Tx) error {
+		bmd := tx.Bucket(diff.q).Bucket(bucketMetadata)
+		if bmd == nil {
+			return nil
+		}
+
+		info.Created = decodeTime(bmd.Get(infoCreated))
+		info.LastAdd = decodeTime(bmd.Get(infoLastAdd))
+		info.LastApply = decodeTime(bmd.Get(infoLastApply))
+		if v := bmd.Get(infoRuns); len(v) == 8 {
+			info.Runs = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return
+}
+
+// initMetadata creates the metadata bucket of b, recording the creation time if it is not already set.
+func initMetadata(b Bucket) error {
+	bmd, err := b.CreateBucketIfNotExists(bucketMetadata)
+	if err != nil {
+		return err
+	}
+	if bmd.Get(infoCreated) != nil {
+		return nil
+	}
+	return bmd.Put(infoCreated, encodeTime(time.Now()))
+}
+
+// recordAdd records that an object was added to b.
+func recordAdd(b Bucket) error {
+	return b.Bucket(bucketMetadata).Put(infoLastAdd, encodeTime(time.Now()))
+}
+
+// RunOf returns the sequence number of the apply run the change being decoded by d is being applied in,
+// which can be recorded by the target system to identify the generation of the diff that produced it.
+// It returns 0 if the change is not being applied.
+func RunOf(d Decoder) uint64 {
+	msg, ok := d.(*msgpackDecoder)
+	if !ok {
+		return 0
+	}
+	return msg.run
+}
+
+// nextRun returns the sequence number of a new apply run in b.
+// The number is only consumed once the run is recorded by recordRun.
+func nextRun(b Bucket) uint64 {
+	if v := b.Bucket(bucketMetadata).Get(infoRuns); len(v) == 8 {
+		return binary.BigEndian.Uint64(v) + 1
+	}
+	return 1
+}
+
+// recordRun records the completion of the apply run numbered run in b.
+func recordRun(b Bucket, run uint64) error {
+	bmd := b.Bucket(bucketMetadata)
+
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, run)
+	if err := bmd.Put(infoRuns, v); err != nil {
+		return err
+	}
+	return bmd.Put(infoLastApply, encodeTime(time.Now()))
+}
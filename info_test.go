@@ -0,0 +1,88 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDifferential_Info(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	start := time.Now()
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := diff.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "test" || info.Created.Before(start) || !info.LastAdd.IsZero() || info.Runs != 0 {
+		t.Fatalf("Unexpected info for a new differential %+v", info)
+	}
+
+	// Opening again does not reset the creation time
+	if _, err := db.Open("test"); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := diff.Info(); !again.Created.Equal(info.Created) {
+		t.Fatalf("Expected created time %s; got %s", info.Created, again.Created)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err = diff.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LastAdd.Before(info.Created) || info.LastApply.Before(info.LastAdd) {
+		t.Fatalf("Unexpected timestamps %+v", info)
+	}
+	if info.Runs != 2 {
+		t.Fatalf("Expected 2 runs; got %d", info.Runs)
+	}
+}
+
+func TestDecoder_Run(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var runs []uint64
+	for i := 0; i < 3; i++ {
+		if _, err := diff.Add(NewIDObject([]byte("1"), i)); err != nil {
+			t.Fatal(err)
+		}
+		err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+			runs = append(runs, RunOf(data))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(runs) != 3 || runs[0] != 1 || runs[1] != 2 || runs[2] != 3 {
+		t.Fatalf("Expected runs [1 2 3]; got %v", runs)
+	}
+	if info, _ := diff.Info(); info.Runs != 3 {
+		t.Fatalf("Expected Info to report run 3; got %d", info.Runs)
+	}
+}
@@ -0,0 +1,83 @@
+//go:build go1.23
+
+package diffdb
+
+import (
+	"context"
+	"iter"
+)
+
+// errDecoder is a Decoder that always returns an error, used when a payload cannot be read.
+type errDecoder struct {
+	err error
+}
+
+func (d errDecoder) Decode(interface{}) error {
+	return d.err
+}
+
+func (d errDecoder) Bytes() ([]byte, error) {
+	return nil, d.err
+}
+
+func (d errDecoder) DecodeJSON() ([]byte, error) {
+	return nil, d.err
+}
+
+func (d errDecoder) DecodeMap() (map[string]interface{}, error) {
+	return nil, d.err
+}
+
+// Pending returns an iterator over pending changes in a read-only transaction without applying them:
+//
+//	for id, data := range diff.Pending(ctx) {
+//		...
+//	}
+//
+// The transaction is held open for the duration of the loop, so the ID and Decoder are only valid
+// until the loop ends. Iteration stops early if the context is cancelled or the transaction cannot be started,
+// which can be detected using ctx.Err().
+func (diff *Differential) Pending(ctx context.Context) iter.Seq2[[]byte, Decoder] {
+	return func(yield func([]byte, Decoder) bool) {
+		diff.db.view(func(tx Tx) error {
+			b := tx.Bucket(diff.q)
+			var (
+				bpd = b.Bucket(bucketPendingData)
+				cur = b.Bucket(bucketPendingHashes).Cursor()
+			)
+
+			for id, _ := cur.First(); id != nil; id, _ = cur.Next() {
+				if ctx.Err() != nil {
+					return nil
+				}
+
+				var decoder Decoder
+				if data := bpd.Get(id); data != nil {
+					decoder = &msgpackDecoder{data: data, meta: changeMetadata(b, id), staged: stagedAt(b, id)}
+				} else {
+					decoder = errDecoder{err: missingPayload(diff.Name(), id)}
+				}
+				if !yield(id, decoder) {
+					return nil
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// Committed returns an iterator over the IDs and hashes of all applied changes in a read-only transaction.
+// The same lifetime and cancellation rules as Pending apply.
+func (diff *Differential) Committed(ctx context.Context) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		diff.db.view(func(tx Tx) error {
+			cur := tx.Bucket(diff.q).Bucket(bucketHashes).Cursor()
+			for id, hash := cur.First(); id != nil; id, hash = cur.Next() {
+				if ctx.Err() != nil || !yield(id, hash) {
+					return nil
+				}
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,60 @@
+//go:build go1.23
+
+package diffdb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestDifferential_Pending(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var n int
+	for id, data := range diff.Pending(context.Background()) {
+		var x struct{ Object int }
+		if err := data.Decode(&x); err != nil {
+			t.Fatal(err)
+		}
+		if strconv.Itoa(x.Object) != string(id) {
+			t.Fatalf("Expected payload %s; got %d", id, x.Object)
+		}
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Fatalf("Expected to stop after 3 changes; got %d", n)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n = 0
+	for id, hash := range diff.Committed(context.Background()) {
+		if len(id) == 0 || len(hash) == 0 {
+			t.Fatal("Expected an ID and hash")
+		}
+		n++
+	}
+	if n != 5 {
+		t.Fatalf("Expected 5 committed IDs; got %d", n)
+	}
+}
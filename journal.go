@@ -0,0 +1,172 @@
+package diffdb
+
+import (
+	"encoding/binary"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// A ChangeKind describes how an applied change affected the state of its ID.
+type ChangeKind uint8
+
+const (
+	// ChangeCreate is a change to an ID that was not previously tracked.
+	ChangeCreate ChangeKind = iota + 1
+	// ChangeUpdate is a change to an ID that was already tracked.
+	ChangeUpdate
+	// ChangeDelete is the removal of a tracked ID.
+	ChangeDelete
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeCreate:
+		return "create"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// A JournalEntry records a single change to the committed state of a differential.
+type JournalEntry struct {
+	// Seq is the position of the entry in the journal, starting from 1.
+	Seq  uint64 `msgpack:"-"`
+	ID   []byte
+	Kind ChangeKind
+	// Hash is the committed hash after the change, or nil for ChangeDelete.
+	Hash []byte
+	// PreviousHash is the committed hash before the change, or nil for ChangeCreate.
+	PreviousHash []byte
+	// Payload is the serialised object that was applied, or nil for ChangeDelete.
+	Payload []byte
+	// PreviousPayload is the serialised object before the change.
+	// It is only available if payloads are retained using RetainPayloads.
+	PreviousPayload []byte
+	// Time is when the change was committed.
+	Time time.Time
+}
+
+// Decode decodes the payload of the entry into x.
+// x should be the same type as the object originally added.
+func (e JournalEntry) Decode(x interface{}) error {
+	return (&msgpackDecoder{data: e.Payload}).Decode(x)
+}
+
+// DecodePrevious decodes the previous payload of the entry into x.
+func (e JournalEntry) DecodePrevious(x interface{}) error {
+	return (&msgpackDecoder{data: e.PreviousPayload}).Decode(x)
+}
+
+// Data returns a Decoder of the payload of the entry, such as to render it without a concrete type using DecodeJSON.
+func (e JournalEntry) Data() Decoder {
+	return &msgpackDecoder{data: e.Payload}
+}
+
+// PreviousData returns a Decoder of the previous payload of the entry.
+func (e JournalEntry) PreviousData() Decoder {
+	return &msgpackDecoder{data: e.PreviousPayload}
+}
+
+// EnableJournal appends an entry to an append-only journal for every change applied to, or expired from,
+// the committed state of the differential, so that other consumers can follow changes using Journal.
+// Only changes made after EnableJournal is called are journaled.
+//
+// The journal is stored in the differential, so changes are journaled by every handle
+// and whenever the differential is opened again, whether or not EnableJournal is called.
+func (diff *Differential) EnableJournal() error {
+	return diff.db.update(func(tx Tx) error {
+		_, err := tx.Bucket(diff.q).CreateBucketIfNotExists(bucketJournal)
+		return err
+	})
+}
+
+// journaled returns true if the journal is enabled for b.
+func journaled(b Bucket) bool {
+	return b.Bucket(bucketJournal) != nil
+}
+
+// Journal returns all journal entries with a sequence number greater than since, in order.
+// Consumers should store the sequence number of the last entry they processed and pass it as since on the next call.
+func (diff *Differential) Journal(since uint64) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	err := diff.db.view(func(tx Tx) error {
+		bjn := tx.Bucket(diff.q).Bucket(bucketJournal)
+		if bjn == nil {
+			return nil
+		}
+
+		c := bjn.Cursor()
+		for k, v := c.Seek(journalKey(since + 1)); k != nil; k, v = c.Next() {
+			var e JournalEntry
+			if err := msgpack.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			e.Seq = binary.BigEndian.Uint64(k)
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// TruncateJournal removes all journal entries with a sequence number less than or equal to upTo,
+// once every consumer has processed them.
+// Sequence numbers are never reused.
+func (diff *Differential) TruncateJournal(upTo uint64) error {
+	return diff.db.update(func(tx Tx) error {
+		bjn := tx.Bucket(diff.q).Bucket(bucketJournal)
+		if bjn == nil {
+			return nil
+		}
+
+		c := bjn.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= upTo; k, _ = c.First() {
+			if err := bjn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// appendJournal records a change to id if the journal is enabled.
+func (diff *Differential) appendJournal(b Bucket, kind ChangeKind, id, hash, previous, data []byte) error {
+	bjn := b.Bucket(bucketJournal)
+	if bjn == nil {
+		return nil
+	}
+	seq, err := bjn.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	var previousData []byte
+	if bcd := b.Bucket(bucketCommittedData); bcd != nil && previous != nil {
+		previousData = bcd.Get(id)
+	}
+
+	raw, err := msgpack.Marshal(&JournalEntry{
+		ID:              id,
+		Kind:            kind,
+		Hash:            hash,
+		PreviousHash:    previous,
+		Payload:         data,
+		PreviousPayload: previousData,
+		Time:            time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return bjn.Put(journalKey(seq), raw)
+}
+
+func journalKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
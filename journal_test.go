@@ -0,0 +1,110 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDifferential_Journal(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.EnableJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.TrackTouched(); err != nil {
+		t.Fatal(err)
+	}
+
+	apply := func() {
+		err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= 2; i++ {
+		if _, err := diff.Add(NewIDObject([]byte("1"), i)); err != nil {
+			t.Fatal(err)
+		}
+		apply()
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := diff.ExpireOlderThan(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := diff.Journal(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries; got %d", len(entries))
+	}
+	for i, kind := range []ChangeKind{ChangeCreate, ChangeUpdate, ChangeDelete} {
+		if entries[i].Seq != uint64(i+1) || entries[i].Kind != kind {
+			t.Fatalf("Expected entry %d to be %s; got %d %s", i+1, kind, entries[i].Seq, entries[i].Kind)
+		}
+	}
+
+	var x struct{ Object int }
+	if err := entries[1].Decode(&x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Object != 2 || string(entries[1].PreviousHash) != string(entries[0].Hash) {
+		t.Fatalf("Unexpected update entry %+v", entries[1])
+	}
+
+	if entries, err := diff.Journal(2); err != nil || len(entries) != 1 || entries[0].Seq != 3 {
+		t.Fatalf("Expected only entry 3 since 2; got %v, %v", entries, err)
+	}
+
+	if err := diff.TruncateJournal(2); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := diff.Journal(0); err != nil || len(entries) != 1 || entries[0].Seq != 3 {
+		t.Fatalf("Expected only entry 3 after truncating; got %v, %v", entries, err)
+	}
+}
+
+// Test that changes are journaled by a handle that did not call EnableJournal.
+func TestDifferential_Journal_Reopen(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.EnableJournal(); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err = db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := diff.Journal(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Kind != ChangeCreate {
+		t.Fatalf("Expected the change to be journaled; got %+v", entries)
+	}
+}
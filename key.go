@@ -0,0 +1,86 @@
+package diffdb
+
+import (
+	"encoding/binary"
+)
+
+// A Key is an object ID encoded so that the byte-wise order of keys matches the natural order of the values
+// they were built from. Differentials store IDs in byte-wise order, so IDs built from keys are visited by Each
+// and the iterators in the same order as their values.
+type Key []byte
+
+// StringKey returns the key of a string, which is ordered byte-wise.
+func StringKey(s string) Key {
+	return Key(s)
+}
+
+// Uint64Key returns the key of an unsigned integer as 8 big-endian bytes.
+func Uint64Key(v uint64) Key {
+	k := make(Key, 8)
+	binary.BigEndian.PutUint64(k, v)
+	return k
+}
+
+// Int64Key returns the key of a signed integer as 8 big-endian bytes with the sign bit flipped,
+// so that negative values are ordered before positive values.
+func Int64Key(v int64) Key {
+	return Uint64Key(uint64(v) ^ 1<<63)
+}
+
+// CompositeKey returns the key of a tuple of keys, which is ordered by each part in turn.
+// Each part is escaped and terminated so that a part is always ordered before any longer part it is a prefix of,
+// and so that no two different tuples produce the same key.
+func CompositeKey(parts ...Key) Key {
+	var n int
+	for _, p := range parts {
+		n += len(p) + 2
+	}
+
+	k := make(Key, 0, n)
+	for _, p := range parts {
+		for _, c := range p {
+			k = append(k, c)
+			if c == 0x00 {
+				k = append(k, 0xff)
+			}
+		}
+		k = append(k, 0x00, 0x01)
+	}
+	return k
+}
+
+// ObjectStringID is implemented by objects that are identified by a string.
+// Such objects can be added to a differential by wrapping them using WithStringID.
+type ObjectStringID interface {
+	StringID() string
+}
+
+// stringIDObject adapts an ObjectStringID to an Object.
+type stringIDObject struct {
+	obj ObjectStringID
+}
+
+func (o stringIDObject) ID() []byte {
+	return StringKey(o.obj.StringID())
+}
+
+// WithStringID returns an Object for obj whose ID is the key of obj.StringID().
+// The object itself, not the wrapper, is hashed and stored, so decoders decode into the same type as obj.
+func WithStringID(obj ObjectStringID) Object {
+	return stringIDObject{obj: obj}
+}
+
+// unwrapObject returns the object that should be hashed and stored in place of x.
+func unwrapObject(x interface{}) interface{} {
+	switch o := x.(type) {
+	case metadataObject:
+		return unwrapObject(o.obj)
+	case priorityObject:
+		return unwrapObject(o.obj)
+	case stringIDObject:
+		return o.obj
+	case autoIDObject:
+		return o.obj
+	}
+	return x
+}
@@ -0,0 +1,83 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+)
+
+func TestKey_Order(t *testing.T) {
+	for _, c := range []struct {
+		a, b Key
+	}{
+		{Uint64Key(1), Uint64Key(256)},
+		{Int64Key(math.MinInt64), Int64Key(-1)},
+		{Int64Key(-1), Int64Key(0)},
+		{Int64Key(0), Int64Key(1)},
+		{StringKey("a"), StringKey("b")},
+		{CompositeKey(StringKey("a"), Uint64Key(2)), CompositeKey(StringKey("a"), Uint64Key(10))},
+		// A shorter part is ordered first even if it is a prefix of the other
+		{CompositeKey(StringKey("a"), StringKey("z")), CompositeKey(StringKey("ab"), StringKey("a"))},
+		{CompositeKey(StringKey("a\x00"), StringKey("z")), CompositeKey(StringKey("a\x00\x00"), StringKey("a"))},
+	} {
+		if bytes.Compare(c.a, c.b) >= 0 {
+			t.Fatalf("Expected %x to be ordered before %x", c.a, c.b)
+		}
+	}
+}
+
+func TestCompositeKey_Unique(t *testing.T) {
+	a := CompositeKey(StringKey("a\x00"), StringKey("b"))
+	b := CompositeKey(StringKey("a"), StringKey("\x00b"))
+	if bytes.Equal(a, b) {
+		t.Fatalf("Expected different tuples to have different keys; got %x", a)
+	}
+}
+
+type stringIDRow struct {
+	Name  string
+	Value int
+}
+
+func (r stringIDRow) StringID() string {
+	return r.Name
+}
+
+func TestWithStringID(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(WithStringID(stringIDRow{Name: "a", Value: 1})); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []stringIDRow
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var r stringIDRow
+		if err := data.Decode(&r); err != nil {
+			return err
+		}
+		if string(id) != r.Name {
+			t.Fatalf("Expected ID %q; got %q", r.Name, id)
+		}
+		seen = append(seen, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || seen[0].Value != 1 {
+		t.Fatalf("Expected the wrapped object to be stored; got %+v", seen)
+	}
+
+	// The wrapper is hashed the same as the object itself
+	changed, err := diff.Changed([]byte("a"), stringIDRow{Name: "a", Value: 1})
+	if err != nil || changed {
+		t.Fatalf("Expected the object to be unchanged; got %v, %v", changed, err)
+	}
+}
@@ -0,0 +1,44 @@
+package diffdb
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+)
+
+// SetLogger attaches a structured logger to the database.
+// Transaction commits are logged at debug level and the logger is used by every Differential
+// that has not been given its own logger using Differential.SetLogger.
+// A nil logger disables logging.
+func (db *DB) SetLogger(l *slog.Logger) {
+	db.logger.Store(l)
+}
+
+// SetLogger attaches a structured logger to the differential, overriding the logger of the database.
+// Apply failures, dead-lettered changes, conflicting IDs and chunk commits are logged
+// with the name of the differential as an attribute.
+func (diff *Differential) SetLogger(l *slog.Logger) {
+	diff.logger.Store(l)
+}
+
+// log writes a record to the logger of the differential, or the database if the differential has none.
+func (diff *Differential) log(level slog.Level, msg string, args ...any) {
+	l := diff.logger.Load()
+	if l == nil {
+		l = diff.db.logger.Load()
+	}
+	if l == nil {
+		return
+	}
+	l.Log(context.Background(), level, msg, append([]any{slog.String("differential", diff.Name())}, args...)...)
+}
+
+// logID returns a log attribute for the ID of a change.
+func logID(id []byte) slog.Attr {
+	return slog.String("id", string(id))
+}
+
+// logHash returns a log attribute for the hash of a change.
+func logHash(hash []byte) slog.Attr {
+	return slog.String("hash", hex.EncodeToString(hash))
+}
@@ -0,0 +1,52 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDifferential_SetLogger(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	var dbLog, diffLog bytes.Buffer
+	db.SetLogger(slog.New(slog.NewTextHandler(&dbLog, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.DeadLetterAfter(1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dbLog.String(), "transaction committed") {
+		t.Fatalf("Expected transaction commits to be logged; got %q", dbLog.String())
+	}
+
+	diff.SetLogger(slog.New(slog.NewTextHandler(&diffLog, nil)))
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return errors.New("apply error")
+	})
+	if err == nil {
+		t.Fatal("Expected an apply error")
+	}
+
+	out := diffLog.String()
+	for _, expect := range []string{
+		`msg="apply failed" differential=test id=1`,
+		`error="apply error"`,
+		`msg="change dead-lettered" differential=test id=1`,
+	} {
+		if !strings.Contains(out, expect) {
+			t.Fatalf("Expected log to contain %q; got %q", expect, out)
+		}
+	}
+}
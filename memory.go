@@ -0,0 +1,408 @@
+package diffdb
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	errTxClosed          = errors.New("diffdb: transaction closed")
+	errTxNotWritable     = errors.New("diffdb: transaction not writable")
+	errBucketExists      = errors.New("diffdb: bucket already exists")
+	errBucketNotFound    = errors.New("diffdb: bucket not found")
+	errKeyRequired       = errors.New("diffdb: key required")
+	errIncompatibleValue = errors.New("diffdb: incompatible value")
+)
+
+var _ Backend = (*memoryBackend)(nil)
+
+// NewMemory creates a new hashing database held entirely in memory.
+// Nothing is written to disk and all state is lost when the database is closed.
+//
+// Writable transactions hold an exclusive lock on the database for their duration,
+// so a read-only transaction must not be started by the goroutine that holds an open writable transaction.
+func NewMemory() *DB {
+	return NewWithBackend(&memoryBackend{
+		root: newMemBucket(),
+	})
+}
+
+// memoryBackend is a Backend storing buckets in ordered in-memory maps.
+// Writes are applied in place and reverted using an undo log if the transaction is rolled back.
+type memoryBackend struct {
+	mu   sync.RWMutex
+	root *memBucket
+}
+
+func (m *memoryBackend) Begin(writable bool) (Tx, error) {
+	if writable {
+		m.mu.Lock()
+	} else {
+		m.mu.RLock()
+	}
+	if m.root == nil {
+		m.unlock(writable)
+		return nil, errors.New("diffdb: database not open")
+	}
+	return &memTx{
+		m:        m,
+		writable: writable,
+	}, nil
+}
+
+func (m *memoryBackend) unlock(writable bool) {
+	if writable {
+		m.mu.Unlock()
+	} else {
+		m.mu.RUnlock()
+	}
+}
+
+func (m *memoryBackend) Close() error {
+	m.mu.Lock()
+	m.root = nil
+	m.mu.Unlock()
+	return nil
+}
+
+// memBucket holds the contents of a single bucket.
+// keys is kept sorted and includes the names of nested buckets.
+type memBucket struct {
+	keys    [][]byte
+	values  map[string][]byte
+	buckets map[string]*memBucket
+	seq     uint64
+}
+
+func newMemBucket() *memBucket {
+	return &memBucket{
+		values:  make(map[string][]byte),
+		buckets: make(map[string]*memBucket),
+	}
+}
+
+// search returns the index of the first key >= key.
+func (b *memBucket) search(key []byte) int {
+	return sort.Search(len(b.keys), func(i int) bool {
+		return bytes.Compare(b.keys[i], key) >= 0
+	})
+}
+
+func (b *memBucket) insertKey(key []byte) {
+	i := b.search(key)
+	if i < len(b.keys) && bytes.Equal(b.keys[i], key) {
+		return
+	}
+	b.keys = append(b.keys, nil)
+	copy(b.keys[i+1:], b.keys[i:])
+	b.keys[i] = key
+}
+
+func (b *memBucket) removeKey(key []byte) {
+	i := b.search(key)
+	if i < len(b.keys) && bytes.Equal(b.keys[i], key) {
+		b.keys = append(b.keys[:i], b.keys[i+1:]...)
+	}
+}
+
+type memTx struct {
+	m        *memoryBackend
+	writable bool
+	closed   bool
+	undo     []func()
+	onCommit []func()
+}
+
+func (tx *memTx) check() error {
+	if tx.closed {
+		return errTxClosed
+	}
+	if !tx.writable {
+		return errTxNotWritable
+	}
+	return nil
+}
+
+func (tx *memTx) root() *memTxBucket {
+	return &memTxBucket{tx: tx, b: tx.m.root}
+}
+
+func (tx *memTx) Bucket(name []byte) Bucket {
+	return tx.root().Bucket(name)
+}
+
+func (tx *memTx) CreateBucket(name []byte) (Bucket, error) {
+	return tx.root().CreateBucket(name)
+}
+
+func (tx *memTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	return tx.root().CreateBucketIfNotExists(name)
+}
+
+func (tx *memTx) DeleteBucket(name []byte) error {
+	return tx.root().DeleteBucket(name)
+}
+
+func (tx *memTx) ForEach(f func(name []byte, b Bucket) error) error {
+	return tx.root().ForEach(func(k, _ []byte) error {
+		return f(k, tx.Bucket(k))
+	})
+}
+
+func (tx *memTx) OnCommit(f func()) {
+	tx.onCommit = append(tx.onCommit, f)
+}
+
+func (tx *memTx) Writable() bool {
+	return tx.writable
+}
+
+func (tx *memTx) Commit() error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	tx.closed = true
+	tx.undo = nil
+	tx.m.unlock(true)
+
+	for _, f := range tx.onCommit {
+		f()
+	}
+	return nil
+}
+
+func (tx *memTx) Rollback() error {
+	if tx.closed {
+		return errTxClosed
+	}
+	tx.closed = true
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+	tx.m.unlock(tx.writable)
+	return nil
+}
+
+// memTxBucket binds a memBucket to the transaction it was accessed from.
+type memTxBucket struct {
+	tx *memTx
+	b  *memBucket
+}
+
+func (b *memTxBucket) Get(key []byte) []byte {
+	return b.b.values[string(key)]
+}
+
+func (b *memTxBucket) Put(key, value []byte) error {
+	if err := b.tx.check(); err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return errKeyRequired
+	}
+	k := string(key)
+	if _, ok := b.b.buckets[k]; ok {
+		return errIncompatibleValue
+	}
+
+	old, exists := b.b.values[k]
+	mb := b.b
+	if exists {
+		b.tx.undo = append(b.tx.undo, func() {
+			mb.values[k] = old
+		})
+	} else {
+		b.tx.undo = append(b.tx.undo, func() {
+			delete(mb.values, k)
+			mb.removeKey([]byte(k))
+		})
+		mb.insertKey([]byte(k))
+	}
+
+	mb.values[k] = append(make([]byte, 0, len(value)), value...)
+	return nil
+}
+
+func (b *memTxBucket) Delete(key []byte) error {
+	if err := b.tx.check(); err != nil {
+		return err
+	}
+	k := string(key)
+	if _, ok := b.b.buckets[k]; ok {
+		return errIncompatibleValue
+	}
+	old, exists := b.b.values[k]
+	if !exists {
+		return nil
+	}
+
+	mb := b.b
+	b.tx.undo = append(b.tx.undo, func() {
+		mb.values[k] = old
+		mb.insertKey([]byte(k))
+	})
+	delete(mb.values, k)
+	mb.removeKey(key)
+	return nil
+}
+
+func (b *memTxBucket) Bucket(name []byte) Bucket {
+	nb, ok := b.b.buckets[string(name)]
+	if !ok {
+		return nil
+	}
+	return &memTxBucket{tx: b.tx, b: nb}
+}
+
+func (b *memTxBucket) CreateBucket(name []byte) (Bucket, error) {
+	if err := b.tx.check(); err != nil {
+		return nil, err
+	}
+	if len(name) == 0 {
+		return nil, errKeyRequired
+	}
+	k := string(name)
+	if _, ok := b.b.buckets[k]; ok {
+		return nil, errBucketExists
+	}
+	if _, ok := b.b.values[k]; ok {
+		return nil, errIncompatibleValue
+	}
+
+	mb := b.b
+	b.tx.undo = append(b.tx.undo, func() {
+		delete(mb.buckets, k)
+		mb.removeKey([]byte(k))
+	})
+	nb := newMemBucket()
+	mb.buckets[k] = nb
+	mb.insertKey([]byte(k))
+	return &memTxBucket{tx: b.tx, b: nb}, nil
+}
+
+func (b *memTxBucket) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	if nb := b.Bucket(name); nb != nil {
+		if err := b.tx.check(); err != nil {
+			return nil, err
+		}
+		return nb, nil
+	}
+	return b.CreateBucket(name)
+}
+
+func (b *memTxBucket) DeleteBucket(name []byte) error {
+	if err := b.tx.check(); err != nil {
+		return err
+	}
+	k := string(name)
+	nb, ok := b.b.buckets[k]
+	if !ok {
+		return errBucketNotFound
+	}
+
+	mb := b.b
+	b.tx.undo = append(b.tx.undo, func() {
+		mb.buckets[k] = nb
+		mb.insertKey([]byte(k))
+	})
+	delete(mb.buckets, k)
+	mb.removeKey(name)
+	return nil
+}
+
+func (b *memTxBucket) Cursor() Cursor {
+	return &memCursor{b: b}
+}
+
+func (b *memTxBucket) ForEach(f func(k, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *memTxBucket) KeyN() int {
+	return len(b.b.keys)
+}
+
+func (b *memTxBucket) Sequence() uint64 {
+	return b.b.seq
+}
+
+func (b *memTxBucket) SetSequence(v uint64) error {
+	if err := b.tx.check(); err != nil {
+		return err
+	}
+	mb, old := b.b, b.b.seq
+	b.tx.undo = append(b.tx.undo, func() {
+		mb.seq = old
+	})
+	mb.seq = v
+	return nil
+}
+
+func (b *memTxBucket) NextSequence() (uint64, error) {
+	if err := b.SetSequence(b.b.seq + 1); err != nil {
+		return 0, err
+	}
+	return b.b.seq, nil
+}
+
+// memCursor tracks its position by key so that it remains valid while the bucket is modified.
+type memCursor struct {
+	b   *memTxBucket
+	key []byte
+}
+
+func (c *memCursor) at(i int) ([]byte, []byte) {
+	keys := c.b.b.keys
+	if i < 0 || i >= len(keys) {
+		c.key = nil
+		return nil, nil
+	}
+	c.key = keys[i]
+	return c.key, c.b.b.values[string(c.key)]
+}
+
+func (c *memCursor) First() ([]byte, []byte) {
+	return c.at(0)
+}
+
+func (c *memCursor) Last() ([]byte, []byte) {
+	return c.at(len(c.b.b.keys) - 1)
+}
+
+func (c *memCursor) Next() ([]byte, []byte) {
+	if c.key == nil {
+		return nil, nil
+	}
+	i := c.b.b.search(c.key)
+	if i < len(c.b.b.keys) && bytes.Equal(c.b.b.keys[i], c.key) {
+		i++
+	}
+	return c.at(i)
+}
+
+func (c *memCursor) Prev() ([]byte, []byte) {
+	if c.key == nil {
+		return nil, nil
+	}
+	return c.at(c.b.b.search(c.key) - 1)
+}
+
+func (c *memCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.at(c.b.b.search(seek))
+}
+
+func (c *memCursor) Delete() error {
+	if c.key == nil {
+		return nil
+	}
+	return c.b.Delete(c.key)
+}
@@ -0,0 +1,101 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestNewMemory(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pending := diff.CountChanges(); pending != 10 {
+		t.Fatalf("Expected 10 changes; got %d", pending)
+	}
+
+	var seen []string
+	err = diff.EachN(context.Background(), func(id []byte, data Decoder) error {
+		var x struct{ Object int }
+		if err := data.Decode(&x); err != nil {
+			return err
+		}
+		if strconv.Itoa(x.Object) != string(id) {
+			t.Fatalf("Expected value %s; got %d", id, x.Object)
+		}
+		seen = append(seen, string(id))
+		return nil
+	}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != 5 || seen[0] != "0" || seen[4] != "4" {
+		t.Fatalf("Expected the first 5 changes in key order; got %v", seen)
+	}
+	if pending := diff.CountChanges(); pending != 5 {
+		t.Fatalf("Expected 5 remaining changes; got %d", pending)
+	}
+	if tracking := diff.CountTracking(); tracking != 5 {
+		t.Fatalf("Expected 5 items to be tracked; got %d", tracking)
+	}
+}
+
+func TestNewMemory_Rollback(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	errAbort := errors.New("abort")
+	err = db.update(func(tx Tx) error {
+		if _, err := diff.AddTx(tx, NewIDObject([]byte("1"), 2)); err != nil {
+			return err
+		}
+		if _, err := diff.AddTx(tx, NewIDObject([]byte("2"), 2)); err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket([]byte("test")); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("Expected %q; got %v", errAbort, err)
+	}
+
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected 1 pending change after rollback; got %d", pending)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var x struct{ Object int }
+		if err := data.Decode(&x); err != nil {
+			return err
+		}
+		if x.Object != 1 {
+			t.Fatalf("Expected the original value to be restored; got %d", x.Object)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
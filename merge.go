@@ -0,0 +1,185 @@
+package diffdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrMergeConflict is returned by Merge using MergeError when both differentials have a different hash for the same ID.
+var ErrMergeConflict = errors.New("diffdb: conflicting ID in merge")
+
+// A MergeStrategy decides which version of an ID is kept when both differentials of a Merge have a different hash for it.
+type MergeStrategy int
+
+const (
+	// MergePreferDst keeps the version in the destination differential.
+	MergePreferDst MergeStrategy = iota
+	// MergePreferSrc replaces the version in the destination differential with the version in the source.
+	MergePreferSrc
+	// MergeError aborts the merge with an error wrapping ErrMergeConflict.
+	MergeError
+)
+
+// Merge folds the committed hashes and pending changes of the differential src into dst in a single transaction.
+// Committed hashes and pending changes are merged separately: an ID is only in conflict if both differentials
+// have a committed hash, or both have a pending change, and the hashes differ.
+// A pending change in src is dropped if dst has already committed the same hash.
+//
+// Index entries of a replaced committed ID are removed. If src retained the payload of the ID then they are rebuilt
+// using the indexes added to the most recently opened Differential of dst, if it is open.
+//
+// The src differential is left unchanged and can be removed using Delete once the merge is complete.
+func (db *DB) Merge(dst, src string, strategy MergeStrategy) error {
+	return db.update(func(tx Tx) error {
+		srcB := tx.Bucket([]byte(src))
+		if srcB == nil {
+			return fmt.Errorf("diffdb: differential %q does not exist", src)
+		}
+		dstB := tx.Bucket([]byte(dst))
+		if dstB == nil {
+			return fmt.Errorf("diffdb: differential %q does not exist", dst)
+		}
+		// Hashes of different widths never compare equal, so every ID would be in conflict
+		if loadHashWidth(dstB) != loadHashWidth(srcB) {
+			return fmt.Errorf("diffdb: cannot merge differentials %q and %q with different hash widths", src, dst)
+		}
+
+		var index func(b Bucket, id, data []byte) error
+		if diff, ok := db.handles.Load(dst); ok {
+			index = diff.(*Differential).index
+		}
+		if err := mergeCommitted(dstB, srcB, strategy, index); err != nil {
+			return err
+		}
+		return mergePending(dstB, srcB, src, strategy)
+	})
+}
+
+// resolve returns true if the version in src should replace the existing version in dst.
+func (s MergeStrategy) resolve(id, existing, hash []byte) (bool, error) {
+	if existing == nil {
+		return true, nil
+	}
+	if bytes.Equal(existing, hash) {
+		return false, nil
+	}
+	switch s {
+	case MergePreferSrc:
+		return true, nil
+	case MergeError:
+		return false, fmt.Errorf("%w %q", ErrMergeConflict, id)
+	default:
+		return false, nil
+	}
+}
+
+// mergeCommitted merges the committed hashes of src into dst.
+// index is used to rebuild the index entries of a replaced ID from its retained payload, if not nil.
+func mergeCommitted(dst, src Bucket, strategy MergeStrategy, index func(b Bucket, id, data []byte) error) error {
+	var (
+		bh   = dst.Bucket(bucketHashes)
+		bcd  = dst.Bucket(bucketCommittedData)
+		sbcd = src.Bucket(bucketCommittedData)
+	)
+
+	return src.Bucket(bucketHashes).ForEach(func(id, hash []byte) error {
+		replace, err := strategy.resolve(id, bh.Get(id), hash)
+		if err != nil || !replace {
+			return err
+		}
+
+		if err := bh.Put(id, hash); err != nil {
+			return err
+		}
+		if err := clearIndexes(dst, id); err != nil {
+			return err
+		}
+
+		var data []byte
+		if sbcd != nil {
+			data = sbcd.Get(id)
+		}
+		if data != nil && index != nil {
+			if err := index(dst, id, data); err != nil {
+				return err
+			}
+		}
+		if bcd == nil {
+			return nil
+		}
+		// Retained payloads must match the committed hash, so a payload of the previous version is removed
+		if data != nil {
+			return bcd.Put(id, data)
+		}
+		return bcd.Delete(id)
+	})
+}
+
+func mergePending(dst, src Bucket, srcName string, strategy MergeStrategy) error {
+	var (
+		bh  = dst.Bucket(bucketHashes)
+		bph = dst.Bucket(bucketPendingHashes)
+		bpd = dst.Bucket(bucketPendingData)
+		bfa = dst.Bucket(bucketFailures)
+		spd = src.Bucket(bucketPendingData)
+	)
+
+	return src.Bucket(bucketPendingHashes).ForEach(func(id, hash []byte) error {
+		if bytes.Equal(bh.Get(id), hash) {
+			return nil
+		}
+
+		existing := bph.Get(id)
+		replace, err := strategy.resolve(id, existing, hash)
+		if err != nil || !replace {
+			return err
+		}
+
+		data := spd.Get(id)
+		if data == nil {
+			return missingPayload(srcName, id)
+		}
+
+		if existing != nil {
+			if err := bfa.Delete(id); err != nil {
+				return err
+			}
+		}
+		if err := bph.Put(id, hash); err != nil {
+			return err
+		}
+		if err := trackUsage(dst, id, data); err != nil {
+			return err
+		}
+		if err := bpd.Put(id, data); err != nil {
+			return err
+		}
+
+		// The staged time, priority and metadata of the change in src replace those of the previous pending change
+		if err := clearChangeInfo(dst, id); err != nil {
+			return err
+		}
+		if t := stagedAt(src, id); !t.IsZero() {
+			if err := putStagedAtTime(dst, id, t); err != nil {
+				return err
+			}
+		}
+		if stagingOrder(dst) {
+			if err := putSequence(dst, id); err != nil {
+				return err
+			}
+		}
+		if err := putPriority(dst, id, changePriority(src, id)); err != nil {
+			return err
+		}
+		if md := changeMetadata(src, id); md != nil {
+			bcm, err := dst.CreateBucketIfNotExists(bucketChangeMetadata)
+			if err != nil {
+				return err
+			}
+			return bcm.Put(id, md)
+		}
+		return nil
+	})
+}
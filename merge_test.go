@@ -0,0 +1,74 @@
+package diffdb
+
+import (
+	"errors"
+	"testing"
+)
+
+// mergeFixture creates two differentials where ID 1 is pending in both with different contents,
+// 2 is only pending in src and 3 is only pending in dst.
+func mergeFixture(t *testing.T) (*DB, *Differential) {
+	db := NewMemory()
+
+	dst, err := db.Open("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := db.Open("src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, obj := range []Object{NewIDObject([]byte("1"), "dst"), NewIDObject([]byte("3"), 3)} {
+		if _, err := dst.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, obj := range []Object{NewIDObject([]byte("1"), "src"), NewIDObject([]byte("2"), 2)} {
+		if _, err := src.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, dst
+}
+
+func TestDB_Merge(t *testing.T) {
+	for _, tc := range []struct {
+		strategy MergeStrategy
+		expect   string
+	}{
+		{MergePreferDst, "dst"},
+		{MergePreferSrc, "src"},
+	} {
+		db, dst := mergeFixture(t)
+
+		if err := db.Merge("dst", "src", tc.strategy); err != nil {
+			t.Fatal(err)
+		}
+		if pending := dst.CountChanges(); pending != 3 {
+			t.Fatalf("Expected 3 pending changes; got %d", pending)
+		}
+
+		var x struct{ Object string }
+		if ok, err := dst.GetPending([]byte("1"), &x); err != nil || !ok {
+			t.Fatalf("Expected a pending change for 1; got %v, %v", ok, err)
+		}
+		if x.Object != tc.expect {
+			t.Fatalf("Expected %s to be kept; got %s", tc.expect, x.Object)
+		}
+		db.Close()
+	}
+}
+
+func TestDB_Merge_Error(t *testing.T) {
+	db, dst := mergeFixture(t)
+	defer db.Close()
+
+	err := db.Merge("dst", "src", MergeError)
+	if !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("Expected %q; got %v", ErrMergeConflict, err)
+	}
+	if pending := dst.CountChanges(); pending != 2 {
+		t.Fatalf("Expected the merge to be rolled back; got %d pending", pending)
+	}
+}
@@ -0,0 +1,116 @@
+package diffdb
+
+import (
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// bucketChangeMetadata stores the Metadata attached to pending changes, keyed by ID.
+var bucketChangeMetadata = []byte("_cm")
+
+// Metadata is information about a change that is not part of the object itself, such as the source system,
+// ingest batch or time it was received. Values must be encodable by msgpack.
+type Metadata map[string]interface{}
+
+// metadataObject is an Object with attached Metadata.
+type metadataObject struct {
+	obj Object
+	md  Metadata
+}
+
+func (o metadataObject) ID() []byte {
+	return o.obj.ID()
+}
+
+// WithMetadata attaches md to the pending change staged when obj is added, without it affecting the hash of obj.
+// The metadata of the most recently staged version of an ID replaces any previous metadata,
+// and it is removed once the change is applied or discarded.
+// If obj is unchanged then nothing is staged and md is ignored.
+//
+// The metadata of a change can be read within an ApplyFunc using MetadataOf.
+func WithMetadata(obj Object, md Metadata) Object {
+	return metadataObject{obj: obj, md: md}
+}
+
+// splitMetadata returns the object wrapped by WithMetadata and WithPriority, and its metadata.
+func splitMetadata(obj Object) (Object, Metadata) {
+	var md Metadata
+	for {
+		switch o := obj.(type) {
+		case metadataObject:
+			obj, md = o.obj, o.md
+		case priorityObject:
+			obj = o.obj
+		default:
+			return obj, md
+		}
+	}
+}
+
+// MetadataOf returns the metadata attached to the change being decoded by d using WithMetadata,
+// or nil if no metadata was attached.
+func MetadataOf(d Decoder) (Metadata, error) {
+	msg, ok := d.(*msgpackDecoder)
+	if !ok || msg.meta == nil {
+		return nil, nil
+	}
+	var md Metadata
+	if err := msgpack.Unmarshal(msg.meta, &md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+// putChangeMetadata replaces the metadata of the pending change of id with md.
+func putChangeMetadata(b Bucket, id []byte, md Metadata) error {
+	if md == nil {
+		return clearChangeMetadata(b, id)
+	}
+	raw, err := msgpack.Marshal(md)
+	if err != nil {
+		return err
+	}
+	bcm, err := b.CreateBucketIfNotExists(bucketChangeMetadata)
+	if err != nil {
+		return err
+	}
+	return bcm.Put(id, raw)
+}
+
+// changeMetadata returns the encoded metadata of the pending change of id, or nil if it has none.
+func changeMetadata(b Bucket, id []byte) []byte {
+	bcm := b.Bucket(bucketChangeMetadata)
+	if bcm == nil {
+		return nil
+	}
+	return bcm.Get(id)
+}
+
+// copyBytes returns a copy of v, or nil if v is nil.
+func copyBytes(v []byte) []byte {
+	if v == nil {
+		return nil
+	}
+	return append([]byte(nil), v...)
+}
+
+// clearChangeInfo removes the metadata, priority, staged time and staging order of the pending change of id.
+func clearChangeInfo(b Bucket, id []byte) error {
+	if err := clearChangeMetadata(b, id); err != nil {
+		return err
+	}
+	if err := clearPriority(b, id); err != nil {
+		return err
+	}
+	if err := clearSequence(b, id); err != nil {
+		return err
+	}
+	return clearStagedAt(b, id)
+}
+
+func clearChangeMetadata(b Bucket, id []byte) error {
+	bcm := b.Bucket(bucketChangeMetadata)
+	if bcm == nil {
+		return nil
+	}
+	return bcm.Delete(id)
+}
@@ -0,0 +1,62 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithMetadata(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(WithMetadata(NewIDObject([]byte("1"), 1), Metadata{"source": "a"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("2"), 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Metadata does not affect the hash
+	updated, err := diff.Add(WithMetadata(NewIDObject([]byte("1"), 1), Metadata{"source": "b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Fatal("Expected the object to be unchanged")
+	}
+
+	seen := make(map[string]Metadata)
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var obj struct{ Object int }
+		if err := data.Decode(&obj); err != nil {
+			return err
+		}
+		md, err := MetadataOf(data)
+		if err != nil {
+			return err
+		}
+		seen[string(id)] = md
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != 2 || seen["1"]["source"] != "a" || seen["2"] != nil {
+		t.Fatalf("Expected metadata only for ID 1; got %v", seen)
+	}
+
+	err = diff.db.view(func(tx Tx) error {
+		if md := changeMetadata(tx.Bucket(diff.q), []byte("1")); md != nil {
+			t.Fatal("Expected metadata to be removed once applied")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
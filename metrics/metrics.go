@@ -0,0 +1,107 @@
+// Package metrics exposes Prometheus metrics for a diffdb Differential.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relvacode/diffdb"
+)
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// A Collector is a prometheus.Collector reporting the state of a single Differential.
+//
+// Tracked and pending counts are read from the database on each scrape.
+// Staged and applied changes are counted using lifecycle hooks,
+// apply errors and durations are only recorded for apply functions wrapped using Instrument.
+type Collector struct {
+	diff *diffdb.Differential
+
+	tracked     *prometheus.Desc
+	pending     *prometheus.Desc
+	adds        prometheus.Counter
+	applies     prometheus.Counter
+	applyErrors prometheus.Counter
+	duration    prometheus.Histogram
+}
+
+// New creates a new Collector for diff. All metrics are labelled with the name of the differential,
+// so Collectors of differentials with different names can be registered with the same registry.
+func New(diff *diffdb.Differential) *Collector {
+	labels := prometheus.Labels{"differential": diff.Name()}
+	c := &Collector{
+		diff: diff,
+		tracked: prometheus.NewDesc(
+			"diffdb_tracked",
+			"Number of IDs with a committed hash.",
+			nil, labels,
+		),
+		pending: prometheus.NewDesc(
+			"diffdb_pending",
+			"Number of pending changes waiting to be applied.",
+			nil, labels,
+		),
+		adds: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "diffdb_adds_total",
+			Help:        "Total number of changes staged.",
+			ConstLabels: labels,
+		}),
+		applies: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "diffdb_applies_total",
+			Help:        "Total number of changes successfully applied.",
+			ConstLabels: labels,
+		}),
+		applyErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "diffdb_apply_errors_total",
+			Help:        "Total number of changes that failed to apply.",
+			ConstLabels: labels,
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "diffdb_apply_duration_seconds",
+			Help:        "Time taken to apply a single change.",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+	}
+
+	diff.OnAdd(func(id, hash []byte) {
+		c.adds.Inc()
+	})
+	diff.OnApply(func(id, hash []byte) {
+		c.applies.Inc()
+	})
+
+	return c
+}
+
+// Instrument wraps f so that the duration of each call and any returned errors are recorded.
+func (c *Collector) Instrument(f diffdb.ApplyFunc) diffdb.ApplyFunc {
+	return func(id []byte, data diffdb.Decoder) error {
+		start := time.Now()
+		err := f(id, data)
+		c.duration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			c.applyErrors.Inc()
+		}
+		return err
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tracked
+	ch <- c.pending
+	c.adds.Describe(ch)
+	c.applies.Describe(ch)
+	c.applyErrors.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.tracked, prometheus.GaugeValue, float64(c.diff.CountTracking()))
+	ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(c.diff.CountChanges()))
+	c.adds.Collect(ch)
+	c.applies.Collect(ch)
+	c.applyErrors.Collect(ch)
+	c.duration.Collect(ch)
+}
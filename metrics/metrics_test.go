@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relvacode/diffdb"
+)
+
+type object struct {
+	Key string
+}
+
+func (o object) ID() []byte {
+	return []byte(o.Key)
+}
+
+func TestCollector(t *testing.T) {
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(diff)
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := diff.Add(object{Key: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errApply := errors.New("apply")
+	err = diff.Each(context.Background(), c.Instrument(func(id []byte, data diffdb.Decoder) error {
+		if string(id) == "2" {
+			return errApply
+		}
+		return nil
+	}))
+	if err == nil {
+		t.Fatal("Expected an apply error")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]float64{
+		"diffdb_tracked":            2,
+		"diffdb_pending":            1,
+		"diffdb_adds_total":         3,
+		"diffdb_applies_total":      2,
+		"diffdb_apply_errors_total": 1,
+	}
+	for _, mf := range families {
+		m := mf.GetMetric()[0]
+		switch mf.GetName() {
+		case "diffdb_apply_duration_seconds":
+			if n := m.GetHistogram().GetSampleCount(); n != 3 {
+				t.Fatalf("Expected 3 apply duration samples; got %d", n)
+			}
+			continue
+		case "diffdb_tracked", "diffdb_pending":
+			if v := m.GetGauge().GetValue(); v != expect[mf.GetName()] {
+				t.Fatalf("Expected %s to be %v; got %v", mf.GetName(), expect[mf.GetName()], v)
+			}
+		default:
+			if v := m.GetCounter().GetValue(); v != expect[mf.GetName()] {
+				t.Fatalf("Expected %s to be %v; got %v", mf.GetName(), expect[mf.GetName()], v)
+			}
+		}
+		delete(expect, mf.GetName())
+	}
+	if len(expect) > 0 {
+		t.Fatalf("Missing metrics %v", expect)
+	}
+}
+
+func TestCollector_Differentials(t *testing.T) {
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	for i, name := range []string{"a", "b"} {
+		diff, err := db.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j <= i; j++ {
+			if _, err := diff.Add(object{Key: string(rune('1' + j))}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := reg.Register(New(diff)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "diffdb_pending" {
+			continue
+		}
+		pending := make(map[string]float64)
+		for _, m := range mf.GetMetric() {
+			pending[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+		}
+		if pending["a"] != 1 || pending["b"] != 2 {
+			t.Fatalf("Expected 1 pending change of a and 2 of b; got %v", pending)
+		}
+		return
+	}
+	t.Fatal("Missing diffdb_pending")
+}
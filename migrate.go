@@ -0,0 +1,26 @@
+package diffdb
+
+// migratePendingData moves pending payloads from the legacy bucket keyed by hash into the bucket keyed by ID.
+// Keying payloads by hash meant that two IDs with identical content shared a single payload,
+// so applying one of them removed the payload of the other.
+// Pending changes whose payload is already missing are left for GC to report.
+func migratePendingData(b Bucket) error {
+	legacy := b.Bucket(bucketPendingHashData)
+	if legacy == nil {
+		return nil
+	}
+
+	bpd := b.Bucket(bucketPendingData)
+	err := b.Bucket(bucketPendingHashes).ForEach(func(id, hash []byte) error {
+		data := legacy.Get(hash)
+		if data == nil {
+			return nil
+		}
+		return bpd.Put(id, append([]byte(nil), data...))
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.DeleteBucket(bucketPendingHashData)
+}
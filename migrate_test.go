@@ -0,0 +1,108 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_IdenticalContent(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both objects hash the same but must be applied independently
+	for _, id := range []string{"1", "2"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), "same")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, EachOpts{N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []string
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var x struct{ Object string }
+		if err := data.Decode(&x); err != nil {
+			return err
+		}
+		applied = append(applied, string(id))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0] != "2" {
+		t.Fatalf("Expected 2 to remain pending; got %v", applied)
+	}
+}
+
+func TestDB_Open_MigratePendingData(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the pending payload into the legacy layout keyed by hash
+	err = db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		hash := b.Bucket(bucketPendingHashes).Get([]byte("1"))
+		data := append([]byte(nil), b.Bucket(bucketPendingData).Get([]byte("1"))...)
+		if err := b.DeleteBucket(bucketPendingData); err != nil {
+			return err
+		}
+		legacy, err := b.CreateBucket(bucketPendingHashData)
+		if err != nil {
+			return err
+		}
+		return legacy.Put(hash, data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err = db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		var x struct{ Object string }
+		if err := data.Decode(&x); err != nil {
+			return err
+		}
+		value = x.Object
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "value" {
+		t.Fatalf("Expected the migrated payload to apply; got %q", value)
+	}
+
+	err = db.view(func(tx Tx) error {
+		if tx.Bucket(diff.q).Bucket(bucketPendingHashData) != nil {
+			t.Fatal("Expected the legacy bucket to be removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
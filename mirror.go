@@ -0,0 +1,80 @@
+package diffdb
+
+import (
+	"context"
+)
+
+// infoMirror is set in the metadata of a differential in mirror mode.
+var infoMirror = []byte("mirror")
+
+// Mirror enables mirror mode, which makes the differential a local copy of the state of the target system
+// rather than only a record of its hashes. In mirror mode the most recently applied payload of each ID is stored,
+// as if RetainPayloads was enabled, so that it can be read using Get or visited using EachCommitted,
+// such as to sync the local state back to another system. The last time each ID was added is tracked,
+// as if TrackTouched was enabled, so that IDs that the source system no longer produces can be deleted
+// using ExpireOlderThan. RetainHistory can be enabled in addition to also keep previous payloads.
+//
+// Mirror mode is recorded in the differential, so it is enabled whenever the differential is opened,
+// and it cannot be disabled. Only changes applied after it is enabled have a stored payload.
+func (diff *Differential) Mirror() error {
+	return diff.db.update(func(tx Tx) error {
+		tx.OnCommit(func() {
+			diff.retainPayloads = true
+		})
+
+		b := tx.Bucket(diff.q)
+		if isMirror(b) {
+			return nil
+		}
+		return enableMirror(b)
+	})
+}
+
+// IsMirror returns true if mirror mode is enabled for the differential.
+func (diff *Differential) IsMirror() (mirror bool, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		mirror = isMirror(tx.Bucket(diff.q))
+		return nil
+	})
+	return
+}
+
+// isMirror returns true if mirror mode is enabled for b.
+func isMirror(b Bucket) bool {
+	return b.Bucket(bucketMetadata).Get(infoMirror) != nil
+}
+
+// enableMirror records b as a mirror and creates the buckets it requires.
+func enableMirror(b Bucket) error {
+	if err := b.Bucket(bucketMetadata).Put(infoMirror, []byte{1}); err != nil {
+		return err
+	}
+	if _, err := b.CreateBucketIfNotExists(bucketCommittedData); err != nil {
+		return err
+	}
+	return initTouched(b)
+}
+
+// EachCommitted visits the most recently applied payload of each committed ID in a read-only transaction, in ID order,
+// such as to sync the local state of a mirror back to another system. f is given a Decoder like ApplyFunc,
+// but nothing is applied. IDs without a stored payload, such as those applied before payloads were retained, are skipped.
+// Iteration stops at the first error returned by f or when the context is cancelled.
+func (diff *Differential) EachCommitted(ctx context.Context, f ApplyFunc) error {
+	return diff.db.view(func(tx Tx) error {
+		bcd := tx.Bucket(diff.q).Bucket(bucketCommittedData)
+		if bcd == nil {
+			return nil
+		}
+
+		c := bcd.Cursor()
+		for id, data := c.First(); id != nil; id, data = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := f(id, &msgpackDecoder{data: data}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDifferential_Mirror(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.db")
+	db, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := db.OpenWithOptions("test", &OpenOptions{Mirror: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("a"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mirror mode is enabled when reopened without the option
+	db, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	diff, err = db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mirror, err := diff.IsMirror(); err != nil || !mirror {
+		t.Fatalf("Expected the differential to be a mirror; got %v, %v", mirror, err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("b"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct{ Object int }
+	if err := diff.Get([]byte("b"), &v); err != nil || v.Object != 2 {
+		t.Fatalf("Expected the applied payload of b; got %d, %v", v.Object, err)
+	}
+
+	mirrored := make(map[string]int)
+	err = diff.EachCommitted(context.Background(), func(id []byte, data Decoder) error {
+		var v struct{ Object int }
+		if err := data.Decode(&v); err != nil {
+			return err
+		}
+		mirrored[string(id)] = v.Object
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mirrored) != 2 || mirrored["a"] != 1 || mirrored["b"] != 2 {
+		t.Fatalf("Expected the mirrored state; got %v", mirrored)
+	}
+
+	// IDs that are no longer added are deleted by absence
+	time.Sleep(50 * time.Millisecond)
+	if _, err := diff.Add(NewIDObject([]byte("a"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := diff.ExpireOlderThan(25 * time.Millisecond); err != nil || n != 1 {
+		t.Fatalf("Expected b to expire; got %d, %v", n, err)
+	}
+	if err := diff.Get([]byte("b"), &v); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected the payload of b to be removed; got %v", err)
+	}
+}
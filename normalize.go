@@ -0,0 +1,26 @@
+package diffdb
+
+// A Normalizer transforms an object before it is hashed and stored, such as lowercasing email addresses
+// or rounding floats, so that cosmetic differences in source data are not seen as changes.
+// The returned object replaces obj entirely, including its ID.
+// Normalizers should be idempotent and must not modify obj in place if the caller may reuse it.
+type Normalizer func(obj Object) Object
+
+// SetNormalizer sets normalizers that are applied in order to every object given to Add, Seed and Changed.
+// Calling SetNormalizer with no arguments removes any existing normalizers.
+//
+// Changing the normalizers of an existing differential changes the hash of objects that they modify,
+// so those objects will be seen as changed the next time they are added.
+func (diff *Differential) SetNormalizer(f ...Normalizer) {
+	diff.normalizers = f
+}
+
+// normalize returns obj after applying every normalizer.
+// Metadata attached using WithMetadata is removed.
+func (diff *Differential) normalize(obj Object) Object {
+	obj, _ = splitMetadata(obj)
+	for _, f := range diff.normalizers {
+		obj = f(obj)
+	}
+	return obj
+}
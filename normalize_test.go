@@ -0,0 +1,64 @@
+package diffdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type emailRow struct {
+	Email string
+	Name  string
+}
+
+func (r emailRow) ID() []byte {
+	return []byte(r.Email)
+}
+
+func TestDifferential_SetNormalizer(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff.SetNormalizer(
+		func(obj Object) Object {
+			r := obj.(emailRow)
+			r.Email = strings.ToLower(r.Email)
+			return r
+		},
+		func(obj Object) Object {
+			r := obj.(emailRow)
+			r.Name = strings.TrimSpace(r.Name)
+			return r
+		},
+	)
+
+	if _, err := diff.Add(emailRow{"A@example.com", "A"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		if string(id) != "a@example.com" {
+			t.Fatalf("Expected the normalized ID; got %q", id)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := diff.Add(emailRow{"a@EXAMPLE.com", " A "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Fatal("Expected a cosmetic difference to be unchanged")
+	}
+
+	changed, err := diff.Changed([]byte("a@example.com"), emailRow{"A@example.com", "A "})
+	if err != nil || changed {
+		t.Fatalf("Expected Changed to normalize the object; got %v, %v", changed, err)
+	}
+}
@@ -0,0 +1,164 @@
+package diffdb
+
+import (
+	"encoding/binary"
+)
+
+var (
+	bucketSequence    = []byte("_sq") // staging sequence to ID
+	bucketSequenceIDs = []byte("_si") // ID to staging sequence
+
+	infoStagingOrder = []byte("staging_order")
+)
+
+// ApplyInStagingOrder makes apply runs started by EachWith, and so Each and its variants, apply pending changes
+// in the order they were staged instead of in ID order, such as when the target system requires parents to be
+// created before their children. A change that is staged again moves to the back of the queue.
+//
+// The setting is stored in the differential so that every process records the staging order.
+// Changes that are already pending are queued in ID order when it is first enabled.
+// EachParallel, EachBatch, Consume and the pending iterators are not affected and still visit changes in ID order.
+func (diff *Differential) ApplyInStagingOrder() error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		if stagingOrder(b) {
+			return nil
+		}
+		if err := b.Bucket(bucketMetadata).Put(infoStagingOrder, []byte{1}); err != nil {
+			return err
+		}
+
+		var ids [][]byte
+		err := b.Bucket(bucketPendingHashes).ForEach(func(id, _ []byte) error {
+			ids = append(ids, append([]byte(nil), id...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := putSequence(b, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// stagingOrder returns true if ApplyInStagingOrder has been enabled for b.
+func stagingOrder(b Bucket) bool {
+	return b.Bucket(bucketMetadata).Get(infoStagingOrder) != nil
+}
+
+// putSequence moves id to the back of the staging order.
+func putSequence(b Bucket, id []byte) error {
+	if err := clearSequence(b, id); err != nil {
+		return err
+	}
+
+	bsq, err := b.CreateBucketIfNotExists(bucketSequence)
+	if err != nil {
+		return err
+	}
+	bsi, err := b.CreateBucketIfNotExists(bucketSequenceIDs)
+	if err != nil {
+		return err
+	}
+
+	seq, err := bsq.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	if err := bsq.Put(key, id); err != nil {
+		return err
+	}
+	if err := bsi.Put(id, key); err != nil {
+		return err
+	}
+	// Move id to the back of its priority too, which is ordered by the staging order
+	if priority := changePriority(b, id); priority != 0 {
+		return putPriority(b, id, priority)
+	}
+	return nil
+}
+
+// clearSequence removes id from the staging order.
+func clearSequence(b Bucket, id []byte) error {
+	bsi := b.Bucket(bucketSequenceIDs)
+	if bsi == nil {
+		return nil
+	}
+	key := bsi.Get(id)
+	if key == nil {
+		return nil
+	}
+	if err := b.Bucket(bucketSequence).Delete(append([]byte(nil), key...)); err != nil {
+		return err
+	}
+	return bsi.Delete(id)
+}
+
+// sequenceCursor iterates over pending changes in staging order, returning their ID and pending hash.
+// Its positions are sequences of the staging order, so Seek accepts a position returned by position rather than an ID.
+type sequenceCursor struct {
+	c   Cursor
+	bph Bucket
+	key []byte // the sequence of the most recently returned ID
+}
+
+// newSequenceCursor returns a cursor over the staging order of b, which must exist.
+func newSequenceCursor(b Bucket) Cursor {
+	return &sequenceCursor{c: b.Bucket(bucketSequence).Cursor(), bph: b.Bucket(bucketPendingHashes)}
+}
+
+// resolve returns the ID and pending hash at k, skipping any sequence whose change is no longer pending.
+func (s *sequenceCursor) resolve(k, id []byte) ([]byte, []byte) {
+	for ; k != nil; k, id = s.c.Next() {
+		if hash := s.bph.Get(id); hash != nil {
+			s.key = append(s.key[:0], k...)
+			return id, hash
+		}
+	}
+	return nil, nil
+}
+
+func (s *sequenceCursor) First() ([]byte, []byte) {
+	return s.resolve(s.c.First())
+}
+
+func (s *sequenceCursor) Last() ([]byte, []byte) {
+	k, id := s.c.Last()
+	if k == nil {
+		return nil, nil
+	}
+	s.key = append(s.key[:0], k...)
+	return id, s.bph.Get(id)
+}
+
+func (s *sequenceCursor) Next() ([]byte, []byte) {
+	return s.resolve(s.c.Next())
+}
+
+func (s *sequenceCursor) Prev() ([]byte, []byte) {
+	k, id := s.c.Prev()
+	if k == nil {
+		return nil, nil
+	}
+	s.key = append(s.key[:0], k...)
+	return id, s.bph.Get(id)
+}
+
+// Seek positions the cursor at the first pending change staged at or after the sequence pos.
+func (s *sequenceCursor) Seek(pos []byte) ([]byte, []byte) {
+	return s.resolve(s.c.Seek(pos))
+}
+
+func (s *sequenceCursor) position() []byte {
+	return s.key
+}
+
+func (s *sequenceCursor) Delete() error {
+	return s.c.Delete()
+}
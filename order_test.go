@@ -0,0 +1,76 @@
+package diffdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDifferential_ApplyInStagingOrder(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := New(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes pending before staging order is enabled are queued in ID order
+	for _, id := range []string{"b", "a"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := diff.ApplyInStagingOrder(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"z", "c", "y"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Staging a new version moves the change to the back of the queue
+	if _, err := diff.Add(NewIDObject([]byte("c"), 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []string
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		applied = append(applied, string(id))
+		return nil
+	}, EachOpts{CommitEvery: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"a", "b", "z", "y", "c"}
+	if len(applied) != len(expect) {
+		t.Fatalf("Expected %v; got %v", expect, applied)
+	}
+	for i := range expect {
+		if applied[i] != expect[i] {
+			t.Fatalf("Expected %v; got %v", expect, applied)
+		}
+	}
+
+	err = db.view(func(tx Tx) error {
+		if n := tx.Bucket(diff.q).Bucket(bucketSequence).KeyN(); n != 0 {
+			t.Fatalf("Expected the staging order to be empty; got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
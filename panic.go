@@ -0,0 +1,32 @@
+package diffdb
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the error of a change whose ApplyFunc panicked, when panics are recovered using EachOpts.RecoverPanics.
+type PanicError struct {
+	ID []byte
+	// Value is the value the ApplyFunc panicked with.
+	Value interface{}
+	// Stack is the stack trace of the goroutine when it panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("diffdb: panic applying %q: %v", e.ID, e.Value)
+}
+
+// recoverPanics returns f converting a panic into a *PanicError.
+func recoverPanics(f ApplyContextFunc) ApplyContextFunc {
+	return func(ctx context.Context, id []byte, data Decoder) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = &PanicError{ID: copyBytes(id), Value: v, Stack: debug.Stack()}
+			}
+		}()
+		return f(ctx, id, data)
+	}
+}
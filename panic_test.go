@@ -0,0 +1,44 @@
+package diffdb
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEachOpts_RecoverPanics(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := diff.EachWithResult(context.Background(), func(id []byte, data Decoder) error {
+		if string(id) == "1" {
+			panic("bad record")
+		}
+		return nil
+	}, EachOpts{RecoverPanics: true})
+	if err == nil {
+		t.Fatal("Expected the panic to be returned as an error")
+	}
+	if res.Applied != 2 || res.Failed != 1 || len(res.Panics) != 1 {
+		t.Fatalf("Expected 2 applied changes and 1 panic; got %+v", res)
+	}
+
+	pe := res.Panics[0]
+	if string(pe.ID) != "1" || pe.Value != "bad record" || !strings.Contains(string(pe.Stack), "panic_test.go") {
+		t.Fatalf("Unexpected panic error %+v", pe)
+	}
+	if n := diff.CountChanges(); n != 1 {
+		t.Fatalf("Expected the change that panicked to remain pending; got %d pending changes", n)
+	}
+}
@@ -0,0 +1,142 @@
+package diffdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// parallelJob is a pending change dispatched to a worker.
+// All fields are copied out of the transaction because workers run concurrently with modifications to it.
+type parallelJob struct {
+	id, hash, data, meta []byte
+	staged               time.Time
+	err                  error
+}
+
+// EachParallel applies each pending change using f from a pool of worker goroutines.
+// f must be safe for concurrent use. Changes are committed as each worker reports success,
+// and all committed changes are written when the run completes or the context is cancelled.
+// If workers is < 1 then a single worker is used.
+//
+// Changes are dispatched in ID order. Unlike EachWith, EachParallel does not apply changes in priority
+// or staging order and does not evict changes older than the maximum age set using SetPendingMaxAge.
+// In-flight changes that were prepared by a TwoPhase run or leased by Consume are skipped.
+func (diff *Differential) EachParallel(ctx context.Context, f ApplyFunc, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	tx, err := diff.db.begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	b := tx.Bucket(diff.q)
+	var (
+		bph = b.Bucket(bucketPendingHashes)
+		bpd = b.Bucket(bucketPendingData)
+		cur = bph.Cursor()
+		run = nextRun(b)
+
+		jobs = make(chan *parallelJob)
+		// results is buffered so that workers never block if the run returns early
+		results = make(chan *parallelJob, workers)
+		wg      sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decoder := &msgpackDecoder{run: run}
+			for job := range jobs {
+				decoder.data, decoder.meta, decoder.staged = job.data, job.meta, job.staged
+				job.err = f(job.id, decoder)
+				results <- job
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	var (
+		updateErr *multierror.Error
+		running   int
+		last      []byte
+	)
+
+	// next returns the pending change after the last dispatched key.
+	// The cursor is repositioned each time because the bucket is modified between calls.
+	next := func() (*parallelJob, error) {
+		id, hash := seekAfter(cur, last)
+		for id != nil && inFlight(b, id) {
+			id, hash = cur.Next()
+		}
+		if id == nil {
+			return nil, nil
+		}
+
+		last = append([]byte(nil), id...)
+		data := bpd.Get(id)
+		if data == nil {
+			return nil, missingPayload(diff.Name(), id)
+		}
+		return &parallelJob{
+			id:     last,
+			hash:   append([]byte(nil), hash...),
+			data:   append([]byte(nil), data...),
+			meta:   copyBytes(changeMetadata(b, id)),
+			staged: stagedAt(b, id),
+		}, nil
+	}
+
+	pending, err := next()
+	if err != nil {
+		return err
+	}
+	done := ctx.Done()
+	for pending != nil || running > 0 {
+		var dispatch chan *parallelJob
+		if pending != nil {
+			dispatch = jobs
+		}
+
+		select {
+		case <-done:
+			updateErr = multierror.Append(updateErr, ctx.Err())
+			pending, done = nil, nil
+		case dispatch <- pending:
+			running++
+			if pending, err = next(); err != nil {
+				return err
+			}
+		case job := <-results:
+			running--
+			if job.err != nil {
+				updateErr = multierror.Append(updateErr, job.err)
+				if err := diff.failChange(tx, b, job.id, job.hash, job.data, job.err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := diff.commitChange(tx, b, job.id, job.hash, job.data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := recordRun(b, run); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return updateErr.ErrorOrNil()
+}
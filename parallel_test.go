@@ -0,0 +1,95 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDifferential_EachParallel(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var calls int64
+	err = diff.EachParallel(context.Background(), func(id []byte, data Decoder) error {
+		atomic.AddInt64(&calls, 1)
+		var x struct{ Object int }
+		if err := data.Decode(&x); err != nil {
+			return err
+		}
+		if strconv.Itoa(x.Object) != string(id) {
+			return errors.New("payload does not match id")
+		}
+		if x.Object%10 == 0 {
+			return errors.New("apply failed")
+		}
+		return nil
+	}, 8)
+	if err == nil {
+		t.Fatal("Expected apply errors")
+	}
+
+	if calls != 100 {
+		t.Fatalf("Expected 100 calls; got %d", calls)
+	}
+	if tracking := diff.CountTracking(); tracking != 90 {
+		t.Fatalf("Expected 90 applied changes; got %d", tracking)
+	}
+	if pending := diff.CountChanges(); pending != 10 {
+		t.Fatalf("Expected 10 failed changes to remain pending; got %d", pending)
+	}
+}
+
+func TestDifferential_EachParallel_InFlight(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, EachOpts{TwoPhase: true, N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var applied []string
+	err = diff.EachParallel(context.Background(), func(id []byte, data Decoder) error {
+		mu.Lock()
+		defer mu.Unlock()
+		applied = append(applied, string(id))
+		return nil
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range applied {
+		if id == "1" {
+			t.Fatal("Unexpected delivery of in-flight change 1")
+		}
+	}
+	if len(applied) != 2 || diff.CountChanges() != 1 {
+		t.Fatalf("Expected 2 applied changes and the in-flight change to remain pending; got %v", applied)
+	}
+}
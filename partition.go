@@ -0,0 +1,83 @@
+package diffdb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+var infoPartitions = []byte("partitions")
+
+// SetPartitions divides the pending changes of the differential into n partitions by a hash of their ID,
+// so that several workers can apply disjoint subsets of the pending changes using EachPartition.
+// Partitions share the pending queue, so runs of different partitions do not apply changes in parallel:
+// each run holds the write transaction while it applies its partition, and visits the changes of every other partition to skip them.
+// Use EachParallel to apply changes concurrently within a single run.
+// The number of partitions is stored in the differential so that every process uses the same partitioning.
+// Setting n to zero or one disables partitioning.
+func (diff *Differential) SetPartitions(n int) error {
+	if n < 0 {
+		return fmt.Errorf("diffdb: invalid number of partitions %d", n)
+	}
+	return diff.db.update(func(tx Tx) error {
+		bmd := tx.Bucket(diff.q).Bucket(bucketMetadata)
+		if n <= 1 {
+			return bmd.Delete(infoPartitions)
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(n))
+		return bmd.Put(infoPartitions, v)
+	})
+}
+
+// Partitions returns the number of partitions set using SetPartitions, or one if the differential is not partitioned.
+func (diff *Differential) Partitions() (n int, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		n = loadPartitions(tx.Bucket(diff.q))
+		return nil
+	})
+	return
+}
+
+// EachPartition applies the pending changes in partition using f like Each, skipping changes in other partitions.
+// It waits for any other apply run, including a run of another partition, to finish.
+// partition must be between zero and the number of partitions set using SetPartitions.
+func (diff *Differential) EachPartition(ctx context.Context, partition int, f ApplyFunc) error {
+	n, err := diff.Partitions()
+	if err != nil {
+		return err
+	}
+	if partition < 0 || partition >= n {
+		return fmt.Errorf("diffdb: partition %d out of range for %d partitions", partition, n)
+	}
+	return diff.EachWith(ctx, f, EachOpts{
+		partition: &partitionFilter{n: n, partition: partition},
+	})
+}
+
+// PartitionOf returns the partition of id when pending changes are divided into n partitions.
+func PartitionOf(id []byte, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(id)
+	return int(h.Sum32() % uint32(n))
+}
+
+// partitionFilter selects the IDs of a single partition.
+type partitionFilter struct {
+	n, partition int
+}
+
+func (p *partitionFilter) skip(id []byte) bool {
+	return p != nil && PartitionOf(id, p.n) != p.partition
+}
+
+func loadPartitions(b Bucket) int {
+	if v := b.Bucket(bucketMetadata).Get(infoPartitions); len(v) == 8 {
+		return int(binary.BigEndian.Uint64(v))
+	}
+	return 1
+}
@@ -0,0 +1,48 @@
+package diffdb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestDifferential_EachPartition(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.SetPartitions(3); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]int)
+	for p := 0; p < 3; p++ {
+		err := diff.EachPartition(context.Background(), p, func(id []byte, data Decoder) error {
+			if PartitionOf(id, 3) != p {
+				t.Fatalf("Unexpected change %s in partition %d", id, p)
+			}
+			seen[string(id)]++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(seen) != 30 {
+		t.Fatalf("Expected every change to be applied once; got %d", len(seen))
+	}
+
+	if err := diff.EachPartition(context.Background(), 3, func(id []byte, data Decoder) error {
+		return nil
+	}); err == nil {
+		t.Fatal("Expected an error for a partition out of range")
+	}
+}
@@ -0,0 +1,75 @@
+package diffdb
+
+import (
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// RetainPayloads stores the most recently applied payload of each ID alongside its hash
+// so that it can be read back using Get.
+// Only changes applied after RetainPayloads is called are retained.
+func (diff *Differential) RetainPayloads() error {
+	return diff.db.update(func(tx Tx) error {
+		tx.OnCommit(func() {
+			diff.retainPayloads = true
+		})
+
+		_, err := tx.Bucket(diff.q).CreateBucketIfNotExists(bucketCommittedData)
+		return err
+	})
+}
+
+// Get decodes the most recently applied value of id into dst.
+// dst should be the same type as the object originally added.
+//
+// Get requires either RetainPayloads or RetainHistory to have been enabled before the value was applied,
+// otherwise ErrNotFound is returned.
+func (diff *Differential) Get(id []byte, dst interface{}) error {
+	return diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+
+		var data []byte
+		if bcd := b.Bucket(bucketCommittedData); bcd != nil {
+			data = bcd.Get(id)
+		}
+		if data == nil {
+			if bhi := b.Bucket(bucketHistory); bhi != nil {
+				if hb := bhi.Bucket(id); hb != nil {
+					if _, v := hb.Cursor().Last(); v != nil {
+						var version Version
+						if err := msgpack.Unmarshal(v, &version); err != nil {
+							return err
+						}
+						data = version.Payload
+					}
+				}
+			}
+		}
+		if data == nil {
+			return diff.wrapErr(id, ErrNotFound)
+		}
+
+		return (&msgpackDecoder{data: data}).Decode(dst)
+	})
+}
+
+// GetPending decodes the currently pending value of id into dst without applying it.
+// If id has no pending change then false is returned and dst is left unmodified.
+func (diff *Differential) GetPending(id []byte, dst interface{}) (pending bool, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+
+		hash := b.Bucket(bucketPendingHashes).Get(id)
+		if hash == nil {
+			return nil
+		}
+
+		data := b.Bucket(bucketPendingData).Get(id)
+		if data == nil {
+			return missingPayload(diff.Name(), id)
+		}
+
+		pending = true
+		return (&msgpackDecoder{data: data}).Decode(dst)
+	})
+	return
+}
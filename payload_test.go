@@ -0,0 +1,78 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDifferential_Get(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var x struct{ Object int }
+	if err := diff.Get([]byte("1"), &x); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %q before payloads are retained; got %v", ErrNotFound, err)
+	}
+
+	if err := diff.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Get([]byte("1"), &x); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %q for an unapplied change; got %v", ErrNotFound, err)
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := diff.Get([]byte("1"), &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Object != 1 {
+		t.Fatalf("Expected applied value 1; got %d", x.Object)
+	}
+}
+
+func TestDifferential_GetPending(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var x struct{ Object int }
+	pending, err := diff.GetPending([]byte("1"), &x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending {
+		t.Fatal("Expected no pending change")
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err = diff.GetPending([]byte("1"), &x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pending || x.Object != 5 {
+		t.Fatalf("Expected pending value 5; got %v %d", pending, x.Object)
+	}
+}
@@ -0,0 +1,122 @@
+package diffdb
+
+import (
+	"context"
+)
+
+// PendingN visits up to n pending changes in a read-only transaction without applying them.
+// If n is <= 0 then all pending changes are visited.
+// Iteration stops at the first error returned by f or when the context is cancelled.
+//
+// PendingN can be used to preview what the next call to Each will apply.
+func (diff *Differential) PendingN(ctx context.Context, f ApplyFunc, n int) error {
+	return diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		var (
+			bph = b.Bucket(bucketPendingHashes)
+			bpd = b.Bucket(bucketPendingData)
+
+			decoder = new(msgpackDecoder)
+			cur     = bph.Cursor()
+		)
+
+		var i int
+		for id, _ := cur.First(); id != nil; id, _ = cur.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			data := bpd.Get(id)
+			if data == nil {
+				return missingPayload(diff.Name(), id)
+			}
+
+			decoder.data = data
+			decoder.meta = changeMetadata(b, id)
+			decoder.staged = stagedAt(b, id)
+			if err := f(id, decoder); err != nil {
+				return err
+			}
+
+			i++
+			if n > 0 && n == i {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// DiscardPending removes all pending changes without applying them.
+// The committed state is left untouched, so discarded objects will be seen as changed again the next time they are added.
+func (diff *Differential) DiscardPending() error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		if diff.observed(EventDiscarded) {
+			err := b.Bucket(bucketPendingHashes).ForEach(func(id, hash []byte) error {
+				diff.emit(tx, EventDiscarded, id, hash)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, name := range [][]byte{bucketPendingHashes, bucketPendingData, bucketFailures} {
+			if err := b.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := b.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		for _, name := range [][]byte{bucketInFlight, bucketLeases, bucketDelayed, bucketChangeMetadata, bucketPriority, bucketPriorityQueue, bucketStagedAt, bucketSequence, bucketSequenceIDs, bucketCorruptPending} {
+			if b.Bucket(name) == nil {
+				continue
+			}
+			if err := b.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return clearUsage(b)
+	})
+}
+
+// DiscardPendingID removes the pending change of id without applying it.
+// It is not an error if id has no pending change.
+func (diff *Differential) DiscardPendingID(id []byte) error {
+	return diff.db.update(func(tx Tx) error {
+		return diff.discardPending(tx, tx.Bucket(diff.q), id)
+	})
+}
+
+// discardPending removes the pending hash and payload of id.
+func (diff *Differential) discardPending(tx Tx, b Bucket, id []byte) error {
+	bph := b.Bucket(bucketPendingHashes)
+	hash := bph.Get(id)
+	if hash == nil {
+		return nil
+	}
+
+	diff.emit(tx, EventDiscarded, id, hash)
+
+	if err := trackUsage(b, id, nil); err != nil {
+		return err
+	}
+	if err := b.Bucket(bucketPendingData).Delete(id); err != nil {
+		return err
+	}
+	if err := b.Bucket(bucketFailures).Delete(id); err != nil {
+		return err
+	}
+	if err := clearInFlight(b, id); err != nil {
+		return err
+	}
+	if err := clearDelay(b, id); err != nil {
+		return err
+	}
+	if err := clearChangeInfo(b, id); err != nil {
+		return err
+	}
+	return bph.Delete(id)
+}
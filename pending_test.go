@@ -0,0 +1,85 @@
+package diffdb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestDifferential_PendingN(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var visited int
+	err = diff.PendingN(context.Background(), func(id []byte, data Decoder) error {
+		visited++
+		return nil
+	}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if visited != 3 {
+		t.Fatalf("Expected 3 changes to be visited; got %d", visited)
+	}
+	if pending := diff.CountChanges(); pending != 5 {
+		t.Fatalf("Expected 5 pending changes to remain; got %d", pending)
+	}
+	if tracking := diff.CountTracking(); tracking != 0 {
+		t.Fatalf("Expected nothing to be tracked; got %d", tracking)
+	}
+}
+
+func TestDifferential_DiscardPending(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := diff.Add(NewIDObject([]byte(strconv.Itoa(i)), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := diff.DiscardPendingID([]byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if pending := diff.CountChanges(); pending != 4 {
+		t.Fatalf("Expected 4 pending changes; got %d", pending)
+	}
+
+	var x struct{ Object int }
+	if ok, err := diff.GetPending([]byte("0"), &x); err != nil || ok {
+		t.Fatalf("Expected discarded change to no longer be pending; got %v %v", ok, err)
+	}
+
+	if err := diff.DiscardPending(); err != nil {
+		t.Fatal(err)
+	}
+	if pending := diff.CountChanges(); pending != 0 {
+		t.Fatalf("Expected no pending changes; got %d", pending)
+	}
+
+	updated, err := diff.Add(NewIDObject([]byte("1"), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated {
+		t.Fatal("Expected a discarded change to be staged again")
+	}
+}
@@ -0,0 +1,77 @@
+package diffdb
+
+import (
+	"context"
+)
+
+// A PlannedChange is a pending change that the next apply run would apply.
+type PlannedChange struct {
+	ID   []byte
+	Kind ChangeKind
+	// Hash is the pending hash of the change.
+	Hash []byte
+	// PreviousHash is the committed hash that the change replaces, or nil for ChangeCreate.
+	PreviousHash []byte
+	// Size is the size of the stored payload in bytes.
+	Size int
+}
+
+// A Report summarises the pending changes of a differential, see Plan.
+type Report struct {
+	// Changes are the planned changes in ID order.
+	Changes []PlannedChange
+	// Counts is the number of planned changes of each kind.
+	Counts map[ChangeKind]int
+	// Bytes is the total size of the stored payloads of the planned changes.
+	Bytes int
+}
+
+// Plan reports the changes that the next call to Each would apply, without applying or consuming them,
+// such as to review a large run before it is started. In-flight changes are not included.
+func (diff *Differential) Plan(ctx context.Context) (*Report, error) {
+	report := &Report{
+		Counts: make(map[ChangeKind]int),
+	}
+	err := diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		var (
+			bh  = b.Bucket(bucketHashes)
+			bpd = b.Bucket(bucketPendingData)
+			cur = b.Bucket(bucketPendingHashes).Cursor()
+		)
+
+		for id, hash := cur.First(); id != nil; id, hash = cur.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if inFlight(b, id) {
+				continue
+			}
+
+			data := bpd.Get(id)
+			if data == nil {
+				return missingPayload(diff.Name(), id)
+			}
+
+			change := PlannedChange{
+				ID:           copyBytes(id),
+				Kind:         ChangeCreate,
+				Hash:         copyBytes(hash),
+				PreviousHash: copyBytes(bh.Get(id)),
+				Size:         len(data),
+			}
+			if change.PreviousHash != nil {
+				change.Kind = ChangeUpdate
+			}
+
+			report.Changes = append(report.Changes, change)
+			report.Counts[change.Kind]++
+			report.Bytes += change.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
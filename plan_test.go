@@ -0,0 +1,59 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDifferential_Plan(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"a", "b"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("a"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("c"), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := diff.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 2 || report.Counts[ChangeCreate] != 1 || report.Counts[ChangeUpdate] != 1 {
+		t.Fatalf("Expected one create and one update; got %+v", report)
+	}
+
+	a := report.Changes[0]
+	committed, pending, err := diff.HashFor([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a.ID) != "a" || a.Kind != ChangeUpdate || !bytes.Equal(a.Hash, pending) || !bytes.Equal(a.PreviousHash, committed) {
+		t.Fatalf("Unexpected planned change %+v", a)
+	}
+	if report.Bytes != report.Changes[0].Size+report.Changes[1].Size || report.Bytes == 0 {
+		t.Fatalf("Expected the total size of the changes; got %d", report.Bytes)
+	}
+
+	if n := diff.CountChanges(); n != 2 {
+		t.Fatalf("Expected Plan to leave changes pending; got %d", n)
+	}
+}
@@ -0,0 +1,64 @@
+package diffdb
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sync"
+
+	"github.com/mitchellh/hashstructure"
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// maxPooledBuffer is the largest encode buffer returned to the pool,
+// so that a single very large object does not pin its buffer for the lifetime of the process.
+const maxPooledBuffer = 64 << 10
+
+// encodeBuffer is a reusable buffer with a msgpack encoder writing into it.
+type encodeBuffer struct {
+	buf bytes.Buffer
+	enc *msgpack.Encoder
+}
+
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		e := new(encodeBuffer)
+		e.enc = msgpack.NewEncoder(&e.buf)
+		return e
+	},
+}
+
+// marshal encodes v like msgpack.Marshal using a pooled encoder and buffer.
+// The returned slice is a copy owned by the caller.
+func marshal(v interface{}) ([]byte, error) {
+	e := encodeBuffers.Get().(*encodeBuffer)
+	defer func() {
+		if e.buf.Cap() <= maxPooledBuffer {
+			encodeBuffers.Put(e)
+		}
+	}()
+
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buf.Bytes()...), nil
+}
+
+// hashOptions pools the options and hash function used by HashOf.
+// Options cannot be shared between concurrent calls to hashstructure.Hash.
+var hashOptions = sync.Pool{
+	New: func() interface{} {
+		return &hashstructure.HashOptions{Hasher: fnv.New64()}
+	},
+}
+
+// laneHashOptions pools the options of every lane used by hashOf for hashes wider than 64 bits.
+var laneHashOptions = sync.Pool{
+	New: func() interface{} {
+		var lanes [HashWidth256 / 8]*hashstructure.HashOptions
+		for i := range lanes {
+			lanes[i] = &hashstructure.HashOptions{Hasher: newLaneHash(byte(i))}
+		}
+		return &lanes
+	},
+}
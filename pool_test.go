@@ -0,0 +1,67 @@
+package diffdb
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+func TestMarshal(t *testing.T) {
+	x := &hashBenchmark{A: "abc", B: 1, C: []string{"a", "b"}, D: time.Unix(0, 0)}
+
+	want, err := msgpack.Marshal(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		got, err := marshal(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Expected %x; got %x", want, got)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	x := &hashBenchmark{A: "abc", B: 131241231, C: []string{"6", "1", "732", "2341"}, D: time.Now()}
+
+	b.Run("msgpack", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := msgpack.Marshal(x); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := marshal(x); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkAdd(b *testing.B) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		obj := NewIDObject([]byte(strconv.Itoa(i%1000)), &hashBenchmark{A: "abc", B: i, C: []string{"6", "1"}})
+		if _, err := diff.Add(obj); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
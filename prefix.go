@@ -0,0 +1,120 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+)
+
+// prefixCursor is a Cursor restricted to the keys with a prefix.
+type prefixCursor struct {
+	c      Cursor
+	prefix []byte
+}
+
+// withPrefix returns c restricted to keys starting with prefix, or c itself if prefix is empty.
+func withPrefix(c Cursor, prefix []byte) Cursor {
+	if len(prefix) == 0 {
+		return c
+	}
+	return &prefixCursor{c: c, prefix: prefix}
+}
+
+func (p *prefixCursor) check(k, v []byte) ([]byte, []byte) {
+	if k == nil || !bytes.HasPrefix(k, p.prefix) {
+		return nil, nil
+	}
+	return k, v
+}
+
+func (p *prefixCursor) First() ([]byte, []byte) {
+	return p.check(p.c.Seek(p.prefix))
+}
+
+func (p *prefixCursor) Last() ([]byte, []byte) {
+	// The last key with the prefix is the key before the first key that sorts after every key with the prefix
+	if end := prefixEnd(p.prefix); end != nil {
+		if k, _ := p.c.Seek(end); k != nil {
+			return p.check(p.c.Prev())
+		}
+	}
+	return p.check(p.c.Last())
+}
+
+func (p *prefixCursor) Next() ([]byte, []byte) {
+	return p.check(p.c.Next())
+}
+
+func (p *prefixCursor) Prev() ([]byte, []byte) {
+	return p.check(p.c.Prev())
+}
+
+func (p *prefixCursor) Seek(seek []byte) ([]byte, []byte) {
+	if bytes.Compare(seek, p.prefix) < 0 {
+		seek = p.prefix
+	}
+	return p.check(p.c.Seek(seek))
+}
+
+func (p *prefixCursor) Delete() error {
+	return p.c.Delete()
+}
+
+// prefixEnd returns the smallest key that sorts after every key starting with prefix,
+// or nil if there is no such key because prefix only contains 0xff bytes.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// EachPrefix applies the pending changes whose ID starts with prefix using f like Each,
+// such as the changes of a single tenant in a differential keyed by CompositeKey.
+// Only the changes with the prefix are visited, so the run does not scan the rest of the differential.
+func (diff *Differential) EachPrefix(ctx context.Context, prefix []byte, f ApplyFunc) error {
+	return diff.EachWith(ctx, f, EachOpts{
+		Prefix: prefix,
+	})
+}
+
+// CountChangesPrefix counts the pending changes whose ID starts with prefix.
+func (diff *Differential) CountChangesPrefix(prefix []byte) (pending int, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		cur := withPrefix(tx.Bucket(diff.q).Bucket(bucketPendingHashes).Cursor(), prefix)
+		for id, _ := cur.First(); id != nil; id, _ = cur.Next() {
+			pending++
+		}
+		return nil
+	})
+	return
+}
+
+// DiscardPendingPrefix removes the pending changes whose ID starts with prefix without applying them,
+// and returns how many were removed.
+func (diff *Differential) DiscardPendingPrefix(prefix []byte) (n int, err error) {
+	err = diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+
+		var ids [][]byte
+		cur := withPrefix(b.Bucket(bucketPendingHashes).Cursor(), prefix)
+		for id, _ := cur.First(); id != nil; id, _ = cur.Next() {
+			ids = append(ids, append([]byte(nil), id...))
+		}
+
+		for _, id := range ids {
+			if err := diff.discardPending(tx, b, id); err != nil {
+				return err
+			}
+		}
+		n = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_Prefix(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tenant := range []string{"a", "ab", "b"} {
+		for _, id := range []uint64{1, 2} {
+			key := CompositeKey(StringKey(tenant), Uint64Key(id))
+			if _, err := diff.Add(NewIDObject(key, tenant)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	prefix := CompositeKey(StringKey("a"))
+	if n, err := diff.CountChangesPrefix(prefix); err != nil || n != 2 {
+		t.Fatalf("Expected 2 pending changes for tenant a; got %d, %v", n, err)
+	}
+
+	var applied []string
+	if err := diff.EachPrefix(context.Background(), prefix, func(id []byte, data Decoder) error {
+		var obj struct{ Object string }
+		if err := data.Decode(&obj); err != nil {
+			return err
+		}
+		applied = append(applied, obj.Object)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0] != "a" || applied[1] != "a" {
+		t.Fatalf("Expected only changes of tenant a; got %v", applied)
+	}
+
+	if n, err := diff.DiscardPendingPrefix([]byte("a")); err != nil || n != 2 {
+		t.Fatalf("Expected 2 discarded changes; got %d, %v", n, err)
+	}
+	if pending := diff.CountChanges(); pending != 2 {
+		t.Fatalf("Expected 2 remaining pending changes; got %d", pending)
+	}
+}
+
+func TestPrefixCursor_Last(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	err := db.update(func(tx Tx) error {
+		b, err := tx.CreateBucket([]byte("test"))
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"a", "b\xff", "b\xff\x01", "c"} {
+			if err := b.Put([]byte(k), []byte{1}); err != nil {
+				return err
+			}
+		}
+
+		for prefix, expect := range map[string]string{"b": "b\xff\x01", "b\xff": "b\xff\x01", "c": "c", "d": ""} {
+			k, _ := withPrefix(b.Cursor(), []byte(prefix)).Last()
+			if string(k) != expect {
+				t.Fatalf("Expected last key %q with prefix %q; got %q", expect, prefix, k)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,263 @@
+package diffdb
+
+import (
+	"encoding/binary"
+)
+
+var (
+	bucketPriority      = []byte("_pr") // ID to key in the priority queue
+	bucketPriorityQueue = []byte("_pq") // inverted priority and ID, or sequence in staging order, to ID
+)
+
+// priorityObject is an Object staged with a priority.
+type priorityObject struct {
+	obj      Object
+	priority uint64
+}
+
+func (o priorityObject) ID() []byte {
+	return o.obj.ID()
+}
+
+// WithPriority stages the pending change of obj with the given priority, without it affecting the hash of obj.
+// Apply runs started by EachWith, and so Each and its variants, apply changes with a higher priority first,
+// such as urgent corrections that should not wait behind a bulk backfill. Changes of the same priority are applied
+// in ID order, or in staging order if ApplyInStagingOrder is enabled, and the default priority is 0.
+//
+// The priority of the most recently staged version of an ID replaces any previous priority,
+// and it is removed once the change is applied or discarded. If obj is unchanged then nothing is staged
+// and the priority is ignored. Runs that apply prioritised changes do not resume from the position of a previous run.
+// EachParallel, EachBatch, Consume and the pending iterators are not affected and still visit changes in ID order.
+func WithPriority(obj Object, priority uint64) Object {
+	return priorityObject{obj: obj, priority: priority}
+}
+
+// priorityOf returns the priority obj was wrapped with using WithPriority, or 0.
+func priorityOf(obj Object) uint64 {
+	for {
+		switch o := obj.(type) {
+		case priorityObject:
+			return o.priority
+		case metadataObject:
+			obj = o.obj
+		default:
+			return 0
+		}
+	}
+}
+
+// priorityKey returns the key of id in the priority queue of b, which orders higher priorities first
+// and then changes of the same priority in staging order if it is enabled, or in ID order.
+func priorityKey(b Bucket, priority uint64, id []byte) []byte {
+	key := make([]byte, 8, 16+len(id))
+	binary.BigEndian.PutUint64(key, ^priority)
+	if bsi := b.Bucket(bucketSequenceIDs); bsi != nil && stagingOrder(b) {
+		if seq := bsi.Get(id); seq != nil {
+			return append(key, seq...)
+		}
+	}
+	return append(key, id...)
+}
+
+// putPriority replaces the priority of the pending change of id.
+func putPriority(b Bucket, id []byte, priority uint64) error {
+	if err := clearPriority(b, id); err != nil {
+		return err
+	}
+	if priority == 0 {
+		return nil
+	}
+
+	bpr, err := b.CreateBucketIfNotExists(bucketPriority)
+	if err != nil {
+		return err
+	}
+	bpq, err := b.CreateBucketIfNotExists(bucketPriorityQueue)
+	if err != nil {
+		return err
+	}
+
+	key := priorityKey(b, priority, id)
+	if err := bpq.Put(key, id); err != nil {
+		return err
+	}
+	return bpr.Put(id, key)
+}
+
+// changePriority returns the priority of the pending change of id.
+func changePriority(b Bucket, id []byte) uint64 {
+	bpr := b.Bucket(bucketPriority)
+	if bpr == nil {
+		return 0
+	}
+	if v := bpr.Get(id); v != nil {
+		return ^binary.BigEndian.Uint64(v[:8])
+	}
+	return 0
+}
+
+// clearPriority resets the priority of the pending change of id to 0.
+func clearPriority(b Bucket, id []byte) error {
+	bpr := b.Bucket(bucketPriority)
+	if bpr == nil {
+		return nil
+	}
+	v := bpr.Get(id)
+	if v == nil {
+		return nil
+	}
+	if err := b.Bucket(bucketPriorityQueue).Delete(append([]byte(nil), v...)); err != nil {
+		return err
+	}
+	return bpr.Delete(id)
+}
+
+// prioritised returns true if any pending change of b has a priority.
+func prioritised(b Bucket) bool {
+	bpq := b.Bucket(bucketPriorityQueue)
+	if bpq == nil {
+		return false
+	}
+	k, _ := bpq.Cursor().First()
+	return k != nil
+}
+
+// Positions of a priorityCursor are a key of the priority queue prefixed with positionQueue while visiting
+// prioritised changes, and a position of the base cursor prefixed with positionBase once they have all been visited.
+const (
+	positionQueue byte = iota
+	positionBase
+)
+
+// priorityCursor iterates over pending changes with a priority in priority order, returning their ID and pending hash,
+// and then over the remaining pending changes of base.
+// Seek accepts a position returned by position rather than an ID.
+type priorityCursor struct {
+	c    Cursor // nil if the priority queue has been removed
+	base Cursor
+	bph  Bucket
+	bpr  Bucket
+
+	inBase bool   // the prioritised changes have all been visited
+	pos    []byte // the position of the most recently returned change
+}
+
+// newPriorityCursor returns a cursor over the priority queue of b followed by base.
+// If the priority queue has been removed, such as by DiscardPending, then only base is visited.
+func newPriorityCursor(b Bucket, base Cursor) Cursor {
+	p := &priorityCursor{
+		base: base,
+		bph:  b.Bucket(bucketPendingHashes),
+		bpr:  b.Bucket(bucketPriority),
+	}
+	if bpq := b.Bucket(bucketPriorityQueue); bpq != nil {
+		p.c = bpq.Cursor()
+	}
+	return p
+}
+
+// queued returns the ID and pending hash at k moving forward through the priority queue,
+// skipping any entry whose change is no longer pending.
+// Once the priority queue is exhausted it continues from the first change of the base cursor.
+func (p *priorityCursor) queued(k, id []byte) ([]byte, []byte) {
+	for ; k != nil; k, id = p.c.Next() {
+		if hash := p.bph.Get(id); hash != nil {
+			p.inBase = false
+			p.pos = append(append(p.pos[:0], positionQueue), k...)
+			return id, hash
+		}
+	}
+	return p.skip(p.base.First())
+}
+
+// skip returns the ID and pending hash at id moving forward through the base cursor, skipping prioritised changes.
+func (p *priorityCursor) skip(id, hash []byte) ([]byte, []byte) {
+	for ; id != nil && p.prioritised(id); id, hash = p.base.Next() {
+	}
+	return p.based(id, hash)
+}
+
+// skipBack is like skip but moves backward.
+func (p *priorityCursor) skipBack(id, hash []byte) ([]byte, []byte) {
+	for ; id != nil && p.prioritised(id); id, hash = p.base.Prev() {
+	}
+	return p.based(id, hash)
+}
+
+// based records id as the current change of the base cursor.
+func (p *priorityCursor) based(id, hash []byte) ([]byte, []byte) {
+	p.inBase = true
+	if id != nil {
+		p.pos = append(append(p.pos[:0], positionBase), positionOf(p.base, id)...)
+	}
+	return id, hash
+}
+
+func (p *priorityCursor) prioritised(id []byte) bool {
+	return p.bpr != nil && p.bpr.Get(id) != nil
+}
+
+// back returns the ID and pending hash at k moving backward through the priority queue.
+func (p *priorityCursor) back(k, id []byte) ([]byte, []byte) {
+	for ; k != nil; k, id = p.c.Prev() {
+		if hash := p.bph.Get(id); hash != nil {
+			p.inBase = false
+			p.pos = append(append(p.pos[:0], positionQueue), k...)
+			return id, hash
+		}
+	}
+	return nil, nil
+}
+
+func (p *priorityCursor) First() ([]byte, []byte) {
+	if p.c == nil {
+		return p.skip(p.base.First())
+	}
+	return p.queued(p.c.First())
+}
+
+func (p *priorityCursor) Last() ([]byte, []byte) {
+	if id, hash := p.skipBack(p.base.Last()); id != nil || p.c == nil {
+		return id, hash
+	}
+	return p.back(p.c.Last())
+}
+
+func (p *priorityCursor) Next() ([]byte, []byte) {
+	if p.inBase {
+		return p.skip(p.base.Next())
+	}
+	return p.queued(p.c.Next())
+}
+
+func (p *priorityCursor) Prev() ([]byte, []byte) {
+	if !p.inBase {
+		return p.back(p.c.Prev())
+	}
+	if id, hash := p.skipBack(p.base.Prev()); id != nil || p.c == nil {
+		return id, hash
+	}
+	return p.back(p.c.Last())
+}
+
+// Seek positions the cursor at the first change at or after the position pos.
+func (p *priorityCursor) Seek(pos []byte) ([]byte, []byte) {
+	if len(pos) == 0 {
+		return p.First()
+	}
+	if pos[0] == positionBase || p.c == nil {
+		return p.skip(p.base.Seek(pos[1:]))
+	}
+	return p.queued(p.c.Seek(pos[1:]))
+}
+
+func (p *priorityCursor) position() []byte {
+	return p.pos
+}
+
+func (p *priorityCursor) Delete() error {
+	if p.inBase {
+		return p.base.Delete()
+	}
+	return p.c.Delete()
+}
@@ -0,0 +1,70 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithPriority(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, obj := range []Object{
+		NewIDObject([]byte("a"), 1),
+		WithPriority(NewIDObject([]byte("b"), 1), 1),
+		NewIDObject([]byte("c"), 1),
+		WithMetadata(WithPriority(NewIDObject([]byte("d"), 1), 5), Metadata{"source": "fix"}),
+		WithPriority(NewIDObject([]byte("e"), 1), 5),
+		WithPriority(NewIDObject([]byte("f"), 1), 3),
+	} {
+		if _, err := diff.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Staging a new version without a priority resets it
+	if _, err := diff.Add(NewIDObject([]byte("f"), 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []string
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		applied = append(applied, string(id))
+		if string(id) == "d" {
+			md, err := MetadataOf(data)
+			if err != nil || md["source"] != "fix" {
+				t.Fatalf("Expected metadata alongside the priority; got %v, %v", md, err)
+			}
+		}
+		return nil
+	}, EachOpts{CommitEvery: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"d", "e", "b", "a", "c", "f"}
+	if len(applied) != len(expect) {
+		t.Fatalf("Expected %v; got %v", expect, applied)
+	}
+	for i := range expect {
+		if applied[i] != expect[i] {
+			t.Fatalf("Expected %v; got %v", expect, applied)
+		}
+	}
+
+	err = db.view(func(tx Tx) error {
+		if prioritised(tx.Bucket(diff.q)) {
+			t.Fatal("Expected the priority queue to be empty")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test that changes without a priority are all applied after prioritised changes have been committed.
@@ -0,0 +1,58 @@
+package diffdb
+
+import (
+	"time"
+)
+
+// Progress describes how far a long running AddChanWith or EachWith run has got.
+type Progress struct {
+	// Processed is the number of objects received or pending changes visited so far.
+	Processed int
+	// Updated is the number of objects that were changed by AddChanWith, or the number of changes applied by EachWith.
+	Updated int
+	// Elapsed is the time since the run started.
+	Elapsed time.Duration
+}
+
+// ProgressFunc is called periodically with the progress of a run.
+type ProgressFunc func(p Progress)
+
+// progressReporter calls a ProgressFunc every n processed items.
+type progressReporter struct {
+	f       ProgressFunc
+	every   int
+	started time.Time
+
+	Progress
+}
+
+func newProgressReporter(f ProgressFunc, every int) *progressReporter {
+	return &progressReporter{
+		f:       f,
+		every:   every,
+		started: time.Now(),
+	}
+}
+
+// processed records a processed item and reports progress if the interval has been reached.
+func (p *progressReporter) processed(updated bool) {
+	p.Processed++
+	if updated {
+		p.Updated++
+	}
+	if p.f != nil && p.every > 0 && p.Processed%p.every == 0 {
+		p.report()
+	}
+}
+
+// done reports the final progress of the run.
+func (p *progressReporter) done() {
+	if p.f != nil {
+		p.report()
+	}
+}
+
+func (p *progressReporter) report() {
+	p.Elapsed = time.Since(p.started)
+	p.f(p.Progress)
+}
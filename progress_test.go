@@ -0,0 +1,59 @@
+package diffdb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := make(chan Object)
+	go func() {
+		for i := 0; i < 10; i++ {
+			stream <- NewIDObject([]byte(strconv.Itoa(i%5)), i%5)
+		}
+		close(stream)
+	}()
+
+	var reports []Progress
+	err = diff.AddChanWith(context.Background(), stream, AddChanOpts{
+		Progress:      func(p Progress) { reports = append(reports, p) },
+		ProgressEvery: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("Expected 3 progress reports; got %d", len(reports))
+	}
+	if last := reports[2]; last.Processed != 10 || last.Updated != 5 {
+		t.Fatalf("Unexpected final progress %+v", last)
+	}
+
+	reports = nil
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, EachOpts{
+		Progress:      func(p Progress) { reports = append(reports, p) },
+		ProgressEvery: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("Expected 3 progress reports; got %d", len(reports))
+	}
+	if last := reports[2]; last.Processed != 5 || last.Updated != 5 {
+		t.Fatalf("Unexpected final progress %+v", last)
+	}
+}
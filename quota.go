@@ -0,0 +1,153 @@
+package diffdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrQuotaExceeded is returned by Add when staging a change would exceed the quota of the differential.
+var ErrQuotaExceeded = errors.New("diffdb: pending quota exceeded")
+
+// A Quota limits the pending changes of a differential, so that a runaway ingest cannot grow
+// the database without bound while nothing is applying changes.
+// A zero limit is unlimited.
+type Quota struct {
+	// MaxPending is the maximum number of pending changes.
+	MaxPending int
+	// MaxPendingBytes is the maximum total size of all pending payloads after compression.
+	MaxPendingBytes int64
+}
+
+func (q Quota) enabled() bool {
+	return q.MaxPending > 0 || q.MaxPendingBytes > 0
+}
+
+func (q Quota) check(count int, bytes int64) error {
+	if q.MaxPending > 0 && count > q.MaxPending {
+		return fmt.Errorf("%w: more than %d pending changes", ErrQuotaExceeded, q.MaxPending)
+	}
+	if q.MaxPendingBytes > 0 && bytes > q.MaxPendingBytes {
+		return fmt.Errorf("%w: more than %d bytes of pending changes", ErrQuotaExceeded, q.MaxPendingBytes)
+	}
+	return nil
+}
+
+// SetQuota sets the quota of pending changes enforced by subsequent calls to Add.
+// A change that replaces an existing pending change of the same ID only counts the difference in size.
+// Changes that are already pending are never removed when a quota is set below their current usage.
+//
+// The quota is enforced by this Differential only, so other handles to the same differential
+// opened using DB.Open are not limited by it.
+func (diff *Differential) SetQuota(q Quota) {
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+
+	diff.quota = q
+}
+
+// infoPendingUsage stores the running total of the pending changes of a differential, used to enforce a Quota without
+// scanning every pending change on each add. Once a handle with a quota has measured it,
+// it is kept up to date in the same transaction as every change that is staged, applied or discarded,
+// so a change that is rolled back or retried as part of a batch is never counted twice.
+var infoPendingUsage = []byte("pending_usage")
+
+type pendingUsage struct {
+	count int
+	bytes int64
+}
+
+// loadPendingUsage returns the usage of the pending changes in b, or false if it has not been measured.
+func loadPendingUsage(b Bucket) (pendingUsage, bool) {
+	v := b.Bucket(bucketMetadata).Get(infoPendingUsage)
+	if len(v) != 16 {
+		return pendingUsage{}, false
+	}
+	return pendingUsage{
+		count: int(binary.BigEndian.Uint64(v[:8])),
+		bytes: int64(binary.BigEndian.Uint64(v[8:])),
+	}, true
+}
+
+// put stores u as the usage of the pending changes in b.
+func (u pendingUsage) put(b Bucket) error {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v[:8], uint64(u.count))
+	binary.BigEndian.PutUint64(v[8:], uint64(u.bytes))
+	return b.Bucket(bucketMetadata).Put(infoPendingUsage, v)
+}
+
+// measurePendingUsage returns the real usage of the pending changes in b.
+func measurePendingUsage(b Bucket) (u pendingUsage, err error) {
+	err = b.Bucket(bucketPendingData).ForEach(func(_, v []byte) error {
+		u.count++
+		u.bytes += int64(len(v))
+		return nil
+	})
+	return
+}
+
+// usageDelta returns the change in usage of replacing the pending payload of id in b with raw,
+// where a nil raw removes the pending payload.
+func usageDelta(b Bucket, id, raw []byte) (count int, bytes int64) {
+	if raw != nil {
+		count, bytes = 1, int64(len(raw))
+	}
+	if existing := b.Bucket(bucketPendingData).Get(id); existing != nil {
+		count, bytes = count-1, bytes-int64(len(existing))
+	}
+	return
+}
+
+// trackUsage updates the usage of the pending changes in b, if it has been measured, for replacing
+// the pending payload of id with raw, where a nil raw removes the pending payload.
+// It must be called before the pending payload is changed.
+func trackUsage(b Bucket, id, raw []byte) error {
+	u, known := loadPendingUsage(b)
+	if !known {
+		return nil
+	}
+	count, bytes := usageDelta(b, id, raw)
+	if count == 0 && bytes == 0 {
+		return nil
+	}
+	u.count += count
+	u.bytes += bytes
+	return u.put(b)
+}
+
+// clearUsage records that b has no pending changes, if its usage has been measured.
+func clearUsage(b Bucket) error {
+	if _, known := loadPendingUsage(b); !known {
+		return nil
+	}
+	return pendingUsage{}.put(b)
+}
+
+// reserve checks that staging raw for id fits within the quota and records it in the usage of the pending changes.
+// The usage is measured the first time a quota is enforced, and is kept up to date from then on
+// even by handles that have no quota.
+func (diff *Differential) reserve(b Bucket, id, raw []byte) error {
+	diff.mu.Lock()
+	q := diff.quota
+	diff.mu.Unlock()
+
+	u, known := loadPendingUsage(b)
+	if !known {
+		if !q.enabled() {
+			return nil
+		}
+		var err error
+		if u, err = measurePendingUsage(b); err != nil {
+			return err
+		}
+	}
+
+	count, bytes := usageDelta(b, id, raw)
+	if err := q.check(u.count+count, u.bytes+bytes); err != nil {
+		return err
+	}
+	u.count += count
+	u.bytes += bytes
+	return u.put(b)
+}
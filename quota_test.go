@@ -0,0 +1,134 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDifferential_SetQuota(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff.SetQuota(Quota{MaxPending: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, err := diff.Add(NewIDObject([]byte{byte(i)}, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := diff.Add(NewIDObject([]byte{2}, 2)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected %q; got %v", ErrQuotaExceeded, err)
+	}
+	// Replacing an existing pending change does not count towards the quota
+	if _, err := diff.Add(NewIDObject([]byte{1}, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Applying pending changes frees the quota
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte{2}, 2)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDifferential_SetQuota_Bytes(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff.SetQuota(Quota{MaxPendingBytes: 64})
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), "small")); err != nil {
+		t.Fatal(err)
+	}
+	large := make([]byte, 64)
+	if _, err := diff.Add(NewIDObject([]byte("2"), large)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected %q; got %v", ErrQuotaExceeded, err)
+	}
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected 1 pending change; got %d", pending)
+	}
+}
+
+// Test that the usage of pending changes is kept up to date as changes are applied and discarded, without measuring it again.
+func TestDifferential_SetQuota_Usage(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff.SetQuota(Quota{MaxPending: 10})
+
+	usage := func() pendingUsage {
+		t.Helper()
+		var u pendingUsage
+		err := db.view(func(tx Tx) error {
+			b := tx.Bucket([]byte("test"))
+			var known bool
+			if u, known = loadPendingUsage(b); !known {
+				t.Fatal("Expected the usage to be known")
+			}
+			real, err := measurePendingUsage(b)
+			if err != nil {
+				return err
+			}
+			if u != real {
+				t.Fatalf("Expected a usage of %+v; got %+v", real, u)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := diff.Add(NewIDObject([]byte{byte(i)}, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u := usage(); u.count != 3 {
+		t.Fatalf("Expected 3 pending changes; got %d", u.count)
+	}
+
+	if err := diff.DiscardPendingID([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if u := usage(); u.count != 2 {
+		t.Fatalf("Expected 2 pending changes; got %d", u.count)
+	}
+
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if u := usage(); u.count != 0 || u.bytes != 0 {
+		t.Fatalf("Expected no pending changes; got %+v", u)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte{3}, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.DiscardPending(); err != nil {
+		t.Fatal(err)
+	}
+	if u := usage(); u.count != 0 {
+		t.Fatalf("Expected no pending changes; got %d", u.count)
+	}
+}
@@ -0,0 +1,45 @@
+package diffdb
+
+import (
+	"context"
+	"time"
+)
+
+// A Limiter blocks until the next change may be applied, or returns an error if ctx is done first.
+// It is satisfied by *rate.Limiter from golang.org/x/time/rate.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// newLimiter returns the Limiter of a run using opts, or nil if the run is not rate limited.
+func newLimiter(opts EachOpts) Limiter {
+	if opts.Limiter != nil {
+		return opts.Limiter
+	}
+	if opts.RateLimit > 0 {
+		return &intervalLimiter{interval: time.Duration(float64(time.Second) / opts.RateLimit)}
+	}
+	return nil
+}
+
+// intervalLimiter is a Limiter that allows one event every interval without bursts.
+type intervalLimiter struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *intervalLimiter) Wait(ctx context.Context) error {
+	now := time.Now()
+	if wait := l.next.Sub(now); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		now = l.next
+	}
+	l.next = now.Add(l.interval)
+	return nil
+}
@@ -0,0 +1,61 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type countingLimiter struct {
+	n   int
+	max int
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	if l.n == l.max {
+		return errors.New("limit reached")
+	}
+	l.n++
+	return nil
+}
+
+func TestEachOpts_RateLimit(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The limiter error stops the run, and changes applied before it are committed
+	limiter := &countingLimiter{max: 2}
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, EachOpts{Limiter: limiter})
+	if err == nil {
+		t.Fatal("Expected the limiter error")
+	}
+	if n := diff.CountChanges(); n != 3 {
+		t.Fatalf("Expected 3 pending changes; got %d", n)
+	}
+
+	started := time.Now()
+	err = diff.EachWith(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, EachOpts{RateLimit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first change is applied immediately, then one every 20ms
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Fatalf("Expected the run to be rate limited; took %s", elapsed)
+	}
+}
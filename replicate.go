@@ -0,0 +1,238 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+var infoReplicated = []byte("replicated")
+
+// ErrJournalGap is returned when journal entries following the position of a replica are missing,
+// such as when the journal of the source was truncated before the replica applied them.
+// The replica can no longer be kept in sync and must be rebuilt.
+var ErrJournalGap = errors.New("diffdb: journal entries are missing")
+
+// journalGap returns an error wrapping ErrJournalGap if the first entry after pos in entries does not directly follow it.
+func journalGap(pos uint64, entries []JournalEntry) error {
+	for _, e := range entries {
+		if e.Seq <= pos {
+			continue
+		}
+		if e.Seq != pos+1 {
+			return fmt.Errorf("%w: expected entry %d after position %d; got %d", ErrJournalGap, pos+1, pos, e.Seq)
+		}
+		return nil
+	}
+	return nil
+}
+
+var _ ReplicaTarget = (*Differential)(nil)
+
+// A ReplicaTarget receives journal entries from a Replicator.
+// A Differential is a ReplicaTarget, so state can be replicated directly into another database file.
+type ReplicaTarget interface {
+	// JournalPosition returns the sequence number of the last journal entry applied to the target.
+	JournalPosition() (uint64, error)
+	// ApplyJournal applies entries in order.
+	ApplyJournal(entries []JournalEntry) error
+}
+
+// A Replicator copies the committed state of a differential to a ReplicaTarget by following its journal,
+// keeping a warm standby of the diff state.
+// The journal of the source differential must be enabled using EnableJournal.
+type Replicator struct {
+	src *Differential
+	dst ReplicaTarget
+}
+
+// NewReplicator creates a new Replicator from src to dst.
+func NewReplicator(src *Differential, dst ReplicaTarget) *Replicator {
+	return &Replicator{
+		src: src,
+		dst: dst,
+	}
+}
+
+// Sync applies all journal entries that have not yet been applied to the target, returning how many were applied.
+// An error wrapping ErrJournalGap is returned if entries the target has not applied were truncated from the journal.
+func (r *Replicator) Sync() (int, error) {
+	pos, err := r.dst.JournalPosition()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := r.src.Journal(pos)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	if err := journalGap(pos, entries); err != nil {
+		return 0, err
+	}
+	if err := r.dst.ApplyJournal(entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Run calls Sync every interval until the context is cancelled or an error occurs.
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if _, err := r.Sync(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// JournalPosition returns the sequence number of the last journal entry replicated into the differential.
+func (diff *Differential) JournalPosition() (pos uint64, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		if bmd := tx.Bucket(diff.q).Bucket(bucketMetadata); bmd != nil {
+			if v := bmd.Get(infoReplicated); len(v) == 8 {
+				pos = binary.BigEndian.Uint64(v)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// ApplyJournal replicates journal entries from another differential into the committed state of this differential
+// in a single transaction. Entries that have already been replicated are skipped, and entries must otherwise
+// be consecutive from the last replicated entry, or an error wrapping ErrJournalGap is returned.
+// Like Seed, no pending changes are created and any identical pending change is removed.
+func (diff *Differential) ApplyJournal(entries []JournalEntry) error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		bmd := b.Bucket(bucketMetadata)
+
+		var pos uint64
+		if v := bmd.Get(infoReplicated); len(v) == 8 {
+			pos = binary.BigEndian.Uint64(v)
+		}
+
+		for _, e := range entries {
+			if e.Seq <= pos {
+				continue
+			}
+			if e.Seq != pos+1 {
+				return journalGap(pos, []JournalEntry{e})
+			}
+			if err := diff.replicate(tx, b, e); err != nil {
+				return err
+			}
+			pos = e.Seq
+		}
+
+		return bmd.Put(infoReplicated, journalKey(pos))
+	})
+}
+
+// replicate applies a single journal entry to b.
+func (diff *Differential) replicate(tx Tx, b Bucket, e JournalEntry) error {
+	if e.Kind == ChangeDelete {
+		if b.Bucket(bucketHashes).Get(e.ID) == nil {
+			return nil
+		}
+		return diff.expire(tx, b, e.ID)
+	}
+
+	var (
+		bph = b.Bucket(bucketPendingHashes)
+		bpd = b.Bucket(bucketPendingData)
+	)
+	if pending := bph.Get(e.ID); pending != nil && bytes.Equal(pending, e.Hash) {
+		if err := bph.Delete(e.ID); err != nil {
+			return err
+		}
+		if err := trackUsage(b, e.ID, nil); err != nil {
+			return err
+		}
+		if err := bpd.Delete(e.ID); err != nil {
+			return err
+		}
+		if err := clearChangeInfo(b, e.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := diff.appendJournal(b, e.Kind, e.ID, e.Hash, e.PreviousHash, e.Payload); err != nil {
+		return err
+	}
+	if diff.retainPayloads {
+		if err := b.Bucket(bucketCommittedData).Put(e.ID, e.Payload); err != nil {
+			return err
+		}
+	}
+	if err := diff.index(b, e.ID, e.Payload); err != nil {
+		return err
+	}
+	return b.Bucket(bucketHashes).Put(e.ID, e.Hash)
+}
+
+// replicaRecord is the wire format of a journal entry sent by a stream target.
+type replicaRecord struct {
+	Seq   uint64
+	Entry JournalEntry
+}
+
+// NewStreamTarget creates a ReplicaTarget which encodes journal entries to w,
+// so that they can be transported to another process and replicated using Differential.ReplicateFrom.
+// since is the position of the receiver when the stream is started.
+func NewStreamTarget(w io.Writer, since uint64) ReplicaTarget {
+	return &streamTarget{
+		enc: msgpack.NewEncoder(w),
+		pos: since,
+	}
+}
+
+type streamTarget struct {
+	enc *msgpack.Encoder
+	pos uint64
+}
+
+func (t *streamTarget) JournalPosition() (uint64, error) {
+	return t.pos, nil
+}
+
+func (t *streamTarget) ApplyJournal(entries []JournalEntry) error {
+	for _, e := range entries {
+		if err := t.enc.Encode(&replicaRecord{Seq: e.Seq, Entry: e}); err != nil {
+			return err
+		}
+		t.pos = e.Seq
+	}
+	return nil
+}
+
+// ReplicateFrom reads journal entries written by a stream target from r and applies them until r returns io.EOF.
+func (diff *Differential) ReplicateFrom(r io.Reader) error {
+	dec := msgpack.NewDecoder(r)
+	for {
+		var rec replicaRecord
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		rec.Entry.Seq = rec.Seq
+		if err := diff.ApplyJournal([]JournalEntry{rec.Entry}); err != nil {
+			return err
+		}
+	}
+}
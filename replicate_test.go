@@ -0,0 +1,123 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func replicaFixture(t *testing.T) (*DB, *Differential) {
+	db := NewMemory()
+	src, err := db.Open("src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.EnableJournal(); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"1", "2"} {
+		if _, err := src.Add(NewIDObject([]byte(id), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = src.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, src
+}
+
+func TestReplicator_Sync(t *testing.T) {
+	db, src := replicaFixture(t)
+	defer db.Close()
+
+	standby := NewMemory()
+	defer standby.Close()
+	dst, err := standby.Open("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReplicator(src, dst)
+	if n, err := r.Sync(); err != nil || n != 2 {
+		t.Fatalf("Expected 2 entries to be replicated; got %d, %v", n, err)
+	}
+	if n, err := r.Sync(); err != nil || n != 0 {
+		t.Fatalf("Expected nothing to replicate; got %d, %v", n, err)
+	}
+	if pos, _ := dst.JournalPosition(); pos != 2 {
+		t.Fatalf("Expected position 2; got %d", pos)
+	}
+
+	if tracking := dst.CountTracking(); tracking != 2 {
+		t.Fatalf("Expected 2 tracked IDs; got %d", tracking)
+	}
+	var x struct{ Object int }
+	if err := dst.Get([]byte("2"), &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Object != 1 {
+		t.Fatalf("Expected replicated payload 1; got %d", x.Object)
+	}
+
+	// Replicated state is treated as already applied
+	if updated, err := dst.Add(NewIDObject([]byte("1"), 0)); err != nil || updated {
+		t.Fatalf("Expected no change; got %v, %v", updated, err)
+	}
+}
+
+func TestReplicator_Sync_Gap(t *testing.T) {
+	db, src := replicaFixture(t)
+	defer db.Close()
+
+	standby := NewMemory()
+	defer standby.Close()
+	dst, err := standby.Open("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first entry is truncated before the replica applies it
+	if err := src.TruncateJournal(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReplicator(src, dst).Sync(); !errors.Is(err, ErrJournalGap) {
+		t.Fatalf("Expected %q; got %v", ErrJournalGap, err)
+	}
+	if err := dst.ApplyJournal([]JournalEntry{{Seq: 2, Kind: ChangeCreate, ID: []byte("2")}}); !errors.Is(err, ErrJournalGap) {
+		t.Fatalf("Expected %q; got %v", ErrJournalGap, err)
+	}
+	if pos, _ := dst.JournalPosition(); pos != 0 {
+		t.Fatalf("Expected position 0; got %d", pos)
+	}
+}
+
+func TestDifferential_ReplicateFrom(t *testing.T) {
+	db, src := replicaFixture(t)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	if _, err := NewReplicator(src, NewStreamTarget(&buf, 0)).Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := db.Open("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.ReplicateFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if tracking := dst.CountTracking(); tracking != 2 {
+		t.Fatalf("Expected 2 tracked IDs; got %d", tracking)
+	}
+	if pos, _ := dst.JournalPosition(); pos != 2 {
+		t.Fatalf("Expected position 2; got %d", pos)
+	}
+}
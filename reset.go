@@ -0,0 +1,82 @@
+package diffdb
+
+// resetKept are the buckets of a differential that are kept by Reset.
+var resetKept = [][]byte{bucketUserData, bucketMetadata, bucketJournal}
+
+// Reset removes all committed hashes, pending changes, failures, dead letters, conflicts,
+// retained payloads and history of the differential in a single transaction,
+// so that every object is seen as new the next time it is added.
+//
+// User data, the journal and settings such as the hash width and number of partitions are kept,
+// as are features enabled on the differential such as RetainPayloads and indexes added using AddIndex.
+// No events are emitted for removed changes, but if the journal is enabled a delete is journaled
+// for every committed ID so that replicas following the journal are reset too.
+func (diff *Differential) Reset() error {
+	return diff.db.update(func(tx Tx) error {
+		if diff.db.readOnly {
+			return ErrReadOnly
+		}
+
+		b := tx.Bucket(diff.q)
+		if journaled(b) {
+			err := b.Bucket(bucketHashes).ForEach(func(id, hash []byte) error {
+				return diff.appendJournal(b, ChangeDelete, id, nil, hash, nil)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		var names [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if v == nil && !containsName(resetKept, k) {
+				names = append(names, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Empty buckets are recreated so that enabled features keep working
+		for _, name := range names {
+			if err := b.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := b.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		if err := b.Bucket(bucketMetadata).Delete(infoCursor); err != nil {
+			return err
+		}
+		if err := clearUsage(b); err != nil {
+			return err
+		}
+
+		diff.mu.Lock()
+		defer diff.mu.Unlock()
+		for name := range diff.indexes {
+			if _, err := indexBuckets(b, name); err != nil {
+				return err
+			}
+		}
+
+		tx.OnCommit(diff.resetConflicts)
+		return nil
+	})
+}
+
+// ClearPending removes all pending changes without applying them, and is equivalent to DiscardPending.
+func (diff *Differential) ClearPending() error {
+	return diff.DiscardPending()
+}
+
+func containsName(names [][]byte, name []byte) bool {
+	for _, n := range names {
+		if string(n) == string(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_Reset(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.UpdateUserData(func(b Bucket) error {
+		return b.Put([]byte("k"), []byte("v"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := diff.Add(NewIDObject([]byte("1"), i)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := diff.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if tracking, pending := diff.CountTracking(), diff.CountChanges(); tracking != 0 || pending != 0 {
+		t.Fatalf("Expected an empty differential; got %d tracking and %d pending", tracking, pending)
+	}
+
+	var v []byte
+	if err := diff.ViewUserData(func(b Bucket) error {
+		v = append(v, b.Get([]byte("k"))...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "v" {
+		t.Fatalf("Expected user data to be kept; got %q", v)
+	}
+
+	// Retained payloads are still recorded after a reset
+	if _, err := diff.Add(NewIDObject([]byte("1"), 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var obj struct{ Object int }
+	if err := diff.Get([]byte("1"), &obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDifferential_Reset_IndexAndJournal(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.EnableJournal(); err != nil {
+		t.Fatal(err)
+	}
+	err = diff.AddIndex("object", func(id []byte, data Decoder) ([][]byte, error) {
+		return [][]byte{id}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, err := diff.QueryIndex("object", []byte("1")); err != nil || len(ids) != 0 {
+		t.Fatalf("Expected an empty index; got %q, %v", ids, err)
+	}
+
+	entries, err := diff.Journal(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Kind != ChangeDelete || string(entries[1].ID) != "1" {
+		t.Fatalf("Expected the reset ID to be journaled as a delete; got %+v", entries)
+	}
+}
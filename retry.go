@@ -0,0 +1,71 @@
+package diffdb
+
+import (
+	"log/slog"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// A FailedChange records unsuccessful attempts to apply a pending change.
+// Failures are cleared when the change is applied, discarded, or replaced by a newer version.
+type FailedChange struct {
+	ID []byte `msgpack:"-"`
+	// Attempts is the number of times ApplyFunc has returned an error for the current version of the change.
+	Attempts int
+	// LastError is the message of the most recent error.
+	LastError string
+	// LastAttempt is when the most recent attempt failed.
+	LastAttempt time.Time
+}
+
+// FailedChanges returns all pending changes that have failed to apply at least once.
+func (diff *Differential) FailedChanges() ([]FailedChange, error) {
+	var failed []FailedChange
+	err := diff.db.view(func(tx Tx) error {
+		bfa := tx.Bucket(diff.q).Bucket(bucketFailures)
+		if bfa == nil {
+			return nil
+		}
+
+		return bfa.ForEach(func(id, v []byte) error {
+			var fc FailedChange
+			if err := msgpack.Unmarshal(v, &fc); err != nil {
+				return err
+			}
+			fc.ID = append([]byte(nil), id...)
+			failed = append(failed, fc)
+			return nil
+		})
+	})
+	return failed, err
+}
+
+// failChange increments the attempt counter of id and stores the error returned by ApplyFunc.
+// If the maximum number of attempts has been reached then the change is moved to the dead-letter bucket.
+func (diff *Differential) failChange(tx Tx, b Bucket, id, hash, data []byte, cause error) error {
+	bfa := b.Bucket(bucketFailures)
+
+	var fc FailedChange
+	if v := bfa.Get(id); v != nil {
+		if err := msgpack.Unmarshal(v, &fc); err != nil {
+			return err
+		}
+	}
+
+	fc.Attempts++
+	fc.LastError = cause.Error()
+	fc.LastAttempt = time.Now().UTC()
+
+	diff.log(slog.LevelWarn, "apply failed", logID(id), logHash(hash), slog.Int("attempts", fc.Attempts), slog.String("error", fc.LastError))
+
+	if diff.maxAttempts > 0 && fc.Attempts >= diff.maxAttempts {
+		return diff.deadLetter(tx, b, id, hash, data, fc)
+	}
+
+	raw, err := msgpack.Marshal(&fc)
+	if err != nil {
+		return err
+	}
+	return bfa.Put(id, raw)
+}
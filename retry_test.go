@@ -0,0 +1,63 @@
+package diffdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDifferential_FailedChanges(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errApply := errors.New("apply failed")
+	apply := func(id []byte, data Decoder) error {
+		if string(id) == "1" {
+			return errApply
+		}
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := diff.Each(context.Background(), apply); err == nil {
+			t.Fatal("Expected an apply error")
+		}
+	}
+
+	failed, err := diff.FailedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 {
+		t.Fatalf("Expected 1 failed change; got %d", len(failed))
+	}
+	if string(failed[0].ID) != "1" || failed[0].Attempts != 3 || failed[0].LastError != errApply.Error() {
+		t.Fatalf("Unexpected failed change %+v", failed[0])
+	}
+
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err = diff.FailedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 0 {
+		t.Fatalf("Expected failures to be cleared after applying; got %d", len(failed))
+	}
+}
@@ -0,0 +1,95 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+)
+
+// SeedTx records obj as already applied using an existing transaction, without creating a pending change.
+// If a pending change with identical contents exists for the same ID then it is removed.
+func (diff *Differential) SeedTx(tx Tx, obj Object) error {
+	if diff.db.readOnly {
+		return ErrReadOnly
+	}
+
+	b := tx.Bucket(diff.q)
+	var (
+		bh  = b.Bucket(bucketHashes)
+		bph = b.Bucket(bucketPendingHashes)
+		bpd = b.Bucket(bucketPendingData)
+	)
+
+	obj = diff.normalize(obj)
+	id := obj.ID()
+	hash, err := diff.hash(obj)
+	if err != nil {
+		return err
+	}
+	if err := diff.touch(b, id); err != nil {
+		return err
+	}
+
+	if pending := bph.Get(id); pending != nil && bytes.Equal(pending, hash) {
+		if err := bph.Delete(id); err != nil {
+			return err
+		}
+		if err := trackUsage(b, id, nil); err != nil {
+			return err
+		}
+		if err := bpd.Delete(id); err != nil {
+			return err
+		}
+		if err := clearChangeInfo(b, id); err != nil {
+			return err
+		}
+	}
+
+	if diff.retainPayloads || diff.indexed() {
+		raw, err := diff.encode(obj)
+		if err != nil {
+			return err
+		}
+		raw = compress(diff.compression, raw)
+		if diff.retainPayloads {
+			if err := b.Bucket(bucketCommittedData).Put(id, raw); err != nil {
+				return err
+			}
+		}
+		if err := diff.index(b, id, raw); err != nil {
+			return err
+		}
+	}
+
+	return bh.Put(id, hash)
+}
+
+// Seed writes objects sent from a channel directly into the committed state until the channel is closed,
+// the object is nil, or the context is cancelled.
+// No pending changes are created, so Seed can be used to prime a new differential from the current
+// state of the target system so that the first apply run only sees real changes.
+//
+// All objects are written in a single transaction which is only committed once the stream ends.
+func (diff *Differential) Seed(ctx context.Context, stream <-chan Object) error {
+	tx, err := diff.db.begin(true)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for {
+		var obj Object
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case obj = <-stream:
+			if obj == nil {
+				return tx.Commit()
+			}
+		}
+
+		if err := diff.SeedTx(tx, obj); err != nil {
+			return err
+		}
+	}
+}
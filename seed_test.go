@@ -0,0 +1,52 @@
+package diffdb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestDifferential_Seed(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := make(chan Object)
+	go func() {
+		for i := 0; i < 10; i++ {
+			stream <- NewIDObject([]byte(strconv.Itoa(i)), i)
+		}
+		close(stream)
+	}()
+
+	if err := diff.Seed(context.Background(), stream); err != nil {
+		t.Fatal(err)
+	}
+
+	if tracking := diff.CountTracking(); tracking != 10 {
+		t.Fatalf("Expected 10 items to be tracked; got %d", tracking)
+	}
+	if pending := diff.CountChanges(); pending != 0 {
+		t.Fatalf("Expected no pending changes; got %d", pending)
+	}
+
+	updated, err := diff.Add(NewIDObject([]byte("1"), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Fatal("Expected seeded object to be unchanged")
+	}
+
+	updated, err = diff.Add(NewIDObject([]byte("1"), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated {
+		t.Fatal("Expected modified object to be changed")
+	}
+}
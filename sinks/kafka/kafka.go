@@ -0,0 +1,112 @@
+// Package kafka publishes pending changes of a diffdb Differential to a Kafka topic.
+//
+// Changes are only committed once Kafka has acknowledged them, so delivery failures leave the change pending
+// and it is published again by the next apply run. Delivery is therefore at-least-once.
+// This requires WriteMessages to wait for acknowledgement, so New rejects a *kafkago.Writer that is Async
+// or that does not require acknowledgements.
+package kafka
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/relvacode/diffdb"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Message headers added to each published change
+const (
+	HeaderHash = "diffdb-hash"
+	HeaderRun  = "diffdb-run"
+)
+
+// A MessageWriter publishes messages to Kafka. It is implemented by *kafkago.Writer.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+}
+
+// EncodeFunc encodes the payload of a change as the value of a Kafka message.
+type EncodeFunc func(id []byte, data diffdb.Decoder) ([]byte, error)
+
+// A Sink publishes changes as Kafka messages keyed by object ID.
+type Sink struct {
+	w      MessageWriter
+	encode EncodeFunc
+}
+
+// New creates a new Sink writing to w.
+// If encode is nil then payloads are encoded as JSON using their msgpack field names.
+// The topic must be configured on w. If w is a *kafkago.Writer then it must not be Async,
+// and RequiredAcks must be kafkago.RequireOne or kafkago.RequireAll.
+func New(w MessageWriter, encode EncodeFunc) (*Sink, error) {
+	if kw, ok := w.(*kafkago.Writer); ok {
+		if kw.Async {
+			return nil, errors.New("kafka: writer must not be async, as delivery errors would not be returned")
+		}
+		if kw.RequiredAcks == kafkago.RequireNone {
+			return nil, errors.New("kafka: writer must require acknowledgements")
+		}
+	}
+	if encode == nil {
+		encode = EncodeJSON
+	}
+	return &Sink{
+		w:      w,
+		encode: encode,
+	}, nil
+}
+
+// Apply returns an ApplyFunc which publishes each change individually and waits for it to be acknowledged.
+// ctx is used for every write.
+func (s *Sink) Apply(ctx context.Context) diffdb.ApplyFunc {
+	return func(id []byte, data diffdb.Decoder) error {
+		msg, err := s.message(id, nil, data)
+		if err != nil {
+			return err
+		}
+		return s.w.WriteMessages(ctx, msg)
+	}
+}
+
+// ApplyBatch returns a BatchApplyFunc which publishes a batch of changes in a single write.
+// If any message of the batch cannot be delivered then the whole batch remains pending.
+func (s *Sink) ApplyBatch(ctx context.Context) diffdb.BatchApplyFunc {
+	return func(batch []diffdb.Change) error {
+		msgs := make([]kafkago.Message, 0, len(batch))
+		for _, c := range batch {
+			msg, err := s.message(c.ID, c.Hash, c.Data)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		return s.w.WriteMessages(ctx, msgs...)
+	}
+}
+
+func (s *Sink) message(id, hash []byte, data diffdb.Decoder) (kafkago.Message, error) {
+	value, err := s.encode(id, data)
+	if err != nil {
+		return kafkago.Message{}, fmt.Errorf("kafka: encode %q: %w", id, err)
+	}
+
+	msg := kafkago.Message{
+		Key:   append([]byte(nil), id...),
+		Value: value,
+		Headers: []kafkago.Header{
+			{Key: HeaderRun, Value: []byte(strconv.FormatUint(diffdb.RunOf(data), 10))},
+		},
+	}
+	if hash != nil {
+		msg.Headers = append(msg.Headers, kafkago.Header{Key: HeaderHash, Value: []byte(hex.EncodeToString(hash))})
+	}
+	return msg, nil
+}
+
+// EncodeJSON encodes the payload of a change as JSON.
+func EncodeJSON(_ []byte, data diffdb.Decoder) ([]byte, error) {
+	return data.DecodeJSON()
+}
@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/relvacode/diffdb"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+type row struct {
+	Key   string
+	Value int
+}
+
+func (r row) ID() []byte {
+	return []byte(r.Key)
+}
+
+type writer struct {
+	err  error
+	msgs []kafkago.Message
+}
+
+func (w *writer) WriteMessages(_ context.Context, msgs ...kafkago.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestSink_ApplyBatch(t *testing.T) {
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		if _, err := diff.Add(row{Key: key, Value: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	w := &writer{err: errors.New("broker unavailable")}
+	sink, err := New(w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := diff.EachBatch(ctx, sink.ApplyBatch(ctx), 2); err == nil {
+		t.Fatal("Expected a delivery error")
+	}
+	if pending := diff.CountChanges(); pending != 3 {
+		t.Fatalf("Expected failed changes to remain pending; got %d", pending)
+	}
+
+	w.err = nil
+	if err := diff.EachBatch(ctx, sink.ApplyBatch(ctx), 2); err != nil {
+		t.Fatal(err)
+	}
+	if pending := diff.CountChanges(); pending != 0 {
+		t.Fatalf("Expected all changes to be applied; got %d pending", pending)
+	}
+
+	if len(w.msgs) != 3 {
+		t.Fatalf("Expected 3 messages; got %d", len(w.msgs))
+	}
+	msg := w.msgs[1]
+	if string(msg.Key) != "b" || string(msg.Value) != `{"Key":"b","Value":1}` {
+		t.Fatalf("Unexpected message %s=%s", msg.Key, msg.Value)
+	}
+	if len(msg.Headers) != 2 || msg.Headers[0].Key != HeaderRun || msg.Headers[1].Key != HeaderHash {
+		t.Fatalf("Unexpected headers %v", msg.Headers)
+	}
+}
+
+func TestSink_Apply(t *testing.T) {
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(row{Key: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	w := new(writer)
+	sink, err := New(w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(ctx, sink.Apply(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.msgs) != 1 || string(w.msgs[0].Key) != "a" {
+		t.Fatalf("Expected a message for a; got %v", w.msgs)
+	}
+}
+
+func TestNew_Writer(t *testing.T) {
+	for _, tc := range []struct {
+		w     *kafkago.Writer
+		valid bool
+	}{
+		{&kafkago.Writer{RequiredAcks: kafkago.RequireAll}, true},
+		{&kafkago.Writer{RequiredAcks: kafkago.RequireOne}, true},
+		{&kafkago.Writer{RequiredAcks: kafkago.RequireAll, Async: true}, false},
+		{&kafkago.Writer{}, false},
+	} {
+		if _, err := New(tc.w, nil); (err == nil) != tc.valid {
+			t.Fatalf("Expected writer with acks %v and async %v to be valid %v; got %v", tc.w.RequiredAcks, tc.w.Async, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package sql applies pending changes of a diffdb Differential to a database/sql table using UPSERT statements.
+//
+// Columns are mapped from the fields of the object type added to the differential using the db struct tag:
+//
+//	type Row struct {
+//		ID    int64  `db:"id,key"`
+//		Name  string `db:"name"`
+//		Cache string `db:"-"`
+//	}
+//
+// Fields tagged with the key option form the conflict target of the upsert and must have a unique constraint.
+// Exported fields without a tag use the lower-cased field name.
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/relvacode/diffdb"
+)
+
+// A Dialect selects the UPSERT syntax and placeholders of the target database.
+type Dialect int
+
+const (
+	// Postgres uses INSERT ... ON CONFLICT with $n placeholders.
+	Postgres Dialect = iota
+	// MySQL uses INSERT ... ON DUPLICATE KEY UPDATE with ? placeholders.
+	MySQL
+	// SQLite uses INSERT ... ON CONFLICT with ? placeholders.
+	SQLite
+)
+
+// quote quotes each dot-separated part of name, such as the schema and table of public.users.
+func (d Dialect) quote(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if d == MySQL {
+			parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+		} else {
+			parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (d Dialect) placeholder(i int) string {
+	if d == Postgres {
+		return fmt.Sprintf("$%d", i)
+	}
+	return "?"
+}
+
+// column maps a struct field to a table column.
+type column struct {
+	name  string
+	index []int
+	key   bool
+}
+
+// A Sink upserts changes into a table.
+type Sink struct {
+	db    *stdsql.DB
+	typ   reflect.Type
+	cols  []column
+	query string
+}
+
+// New creates a new Sink upserting into table.
+// example is a value of the struct type added to the differential and is used to map columns.
+func New(db *stdsql.DB, table string, dialect Dialect, example interface{}) (*Sink, error) {
+	typ := reflect.TypeOf(example)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sql: example must be a struct; got %T", example)
+	}
+
+	cols := columns(typ)
+	if len(cols) == 0 {
+		return nil, errors.New("sql: no columns mapped")
+	}
+
+	query, err := upsert(dialect, table, cols)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		db:    db,
+		typ:   typ,
+		cols:  cols,
+		query: query,
+	}, nil
+}
+
+// Query returns the UPSERT statement executed for each change.
+func (s *Sink) Query() string {
+	return s.query
+}
+
+// Each applies every pending change of diff inside a single transaction, so that the table is updated by the whole run or not at all.
+// Changes are prepared using a TwoPhase run and confirmed once the transaction commits. If any upsert or the commit fails
+// then the transaction is rolled back, the run stops and its changes are returned to the pending queue.
+// Should the process exit between the commit and the confirmation then the changes of the run are left in-flight,
+// and must be confirmed or aborted using Differential.Confirm or Differential.Abort.
+func (s *Sink) Each(ctx context.Context, diff *diffdb.Differential) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var ids [][]byte
+	err = diff.EachWith(ctx, func(id []byte, data diffdb.Decoder) error {
+		args, err := s.args(data)
+		if err != nil {
+			return fmt.Errorf("sql: decode %q: %w", id, err)
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("sql: upsert %q: %w", id, err)
+		}
+		ids = append(ids, id)
+		return nil
+	}, diffdb.EachOpts{TwoPhase: true, FailFast: true})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if abortErr := diff.Abort(ids); abortErr != nil {
+			return multierror.Append(err, abortErr)
+		}
+		return err
+	}
+	return diff.Confirm(ids)
+}
+
+// Apply returns an ApplyFunc which upserts each change in its own statement outside of a transaction.
+// ctx is used for every statement.
+func (s *Sink) Apply(ctx context.Context) diffdb.ApplyFunc {
+	return func(id []byte, data diffdb.Decoder) error {
+		args, err := s.args(data)
+		if err != nil {
+			return fmt.Errorf("sql: decode %q: %w", id, err)
+		}
+		_, err = s.db.ExecContext(ctx, s.query, args...)
+		return err
+	}
+}
+
+// ApplyBatch returns a BatchApplyFunc which upserts each batch of changes inside a single transaction.
+// Use Each to apply a whole run in one transaction.
+// If any statement or the commit fails then the transaction is rolled back and the whole batch remains pending.
+func (s *Sink) ApplyBatch(ctx context.Context) diffdb.BatchApplyFunc {
+	return func(batch []diffdb.Change) error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		stmt, err := tx.PrepareContext(ctx, s.query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, c := range batch {
+			args, err := s.args(c.Data)
+			if err != nil {
+				return fmt.Errorf("sql: decode %q: %w", c.ID, err)
+			}
+			if _, err := stmt.ExecContext(ctx, args...); err != nil {
+				return fmt.Errorf("sql: upsert %q: %w", c.ID, err)
+			}
+		}
+		return tx.Commit()
+	}
+}
+
+// args decodes data and returns the values of each column in order.
+func (s *Sink) args(data diffdb.Decoder) ([]interface{}, error) {
+	v := reflect.New(s.typ)
+	if err := data.Decode(v.Interface()); err != nil {
+		return nil, err
+	}
+	v = v.Elem()
+
+	args := make([]interface{}, len(s.cols))
+	for i, c := range s.cols {
+		args[i] = v.FieldByIndex(c.index).Interface()
+	}
+	return args, nil
+}
+
+// columns returns the columns mapped from the fields of typ.
+func columns(typ reflect.Type) []column {
+	var cols []column
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		cols = append(cols, column{
+			name:  name,
+			index: f.Index,
+			key:   opts == "key",
+		})
+	}
+	return cols
+}
+
+// upsert builds the UPSERT statement of cols for dialect.
+func upsert(dialect Dialect, table string, cols []column) (string, error) {
+	var names, values, keys, updates []string
+	for i, c := range cols {
+		name := dialect.quote(c.name)
+		names = append(names, name)
+		values = append(values, dialect.placeholder(i+1))
+		if c.key {
+			keys = append(keys, name)
+			continue
+		}
+		if dialect == MySQL {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", name, name))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", name, name))
+		}
+	}
+	if len(keys) == 0 {
+		return "", errors.New("sql: at least one field must be tagged as a key")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES (%s)", dialect.quote(table), strings.Join(names, ", "), strings.Join(values, ", "))
+
+	switch {
+	case dialect == MySQL && len(updates) == 0:
+		fmt.Fprintf(&b, " ON DUPLICATE KEY UPDATE %s = %s", keys[0], keys[0])
+	case dialect == MySQL:
+		fmt.Fprintf(&b, " ON DUPLICATE KEY UPDATE %s", strings.Join(updates, ", "))
+	case len(updates) == 0:
+		fmt.Fprintf(&b, " ON CONFLICT (%s) DO NOTHING", strings.Join(keys, ", "))
+	default:
+		fmt.Fprintf(&b, " ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(updates, ", "))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,170 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/relvacode/diffdb"
+)
+
+type row struct {
+	Key   string `db:"id,key"`
+	Name  string
+	Cache string `db:"-"`
+}
+
+func (r row) ID() []byte {
+	return []byte(r.Key)
+}
+
+func TestUpsert(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		expect  string
+	}{
+		{Postgres, `INSERT INTO "rows" ("id", "name") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"`},
+		{MySQL, "INSERT INTO `rows` (`id`, `name`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)"},
+		{SQLite, `INSERT INTO "rows" ("id", "name") VALUES (?, ?) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"`},
+	} {
+		s, err := New(nil, "rows", tc.dialect, row{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Query() != tc.expect {
+			t.Fatalf("Expected %s; got %s", tc.expect, s.Query())
+		}
+	}
+
+	s, err := New(nil, "public.rows", Postgres, row{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `INSERT INTO "public"."rows" `; !strings.HasPrefix(s.Query(), expect) {
+		t.Fatalf("Expected each part of the table name to be quoted; got %s", s.Query())
+	}
+
+	if _, err := New(nil, "rows", Postgres, struct{ Name string }{}); err == nil {
+		t.Fatal("Expected an error without a key column")
+	}
+}
+
+func TestSink_ApplyBatch(t *testing.T) {
+	sqldb, err := stdsql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.Close()
+	sqldb.SetMaxOpenConns(1)
+
+	if _, err := sqldb.Exec(`CREATE TABLE rows (id TEXT PRIMARY KEY, name TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+
+	db := diffdb.NewMemory()
+	defer db.Close()
+	diff, err := db.Open("rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := New(sqldb, "rows", SQLite, row{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, rows := range [][]row{
+		{{Key: "a", Name: "one"}, {Key: "b", Name: "two"}},
+		{{Key: "a", Name: "three"}},
+	} {
+		for _, r := range rows {
+			if _, err := diff.Add(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := diff.EachBatch(ctx, sink.ApplyBatch(ctx), 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var name string
+	if err := sqldb.QueryRow(`SELECT name FROM rows WHERE id = 'a'`).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "three" {
+		t.Fatalf("Expected the row to be updated; got %s", name)
+	}
+	var n int
+	if err := sqldb.QueryRow(`SELECT COUNT(*) FROM rows`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected 2 rows; got %d", n)
+	}
+}
+
+func TestSink_Each(t *testing.T) {
+	sqldb, err := stdsql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.Close()
+	sqldb.SetMaxOpenConns(1)
+
+	if _, err := sqldb.Exec(`CREATE TABLE rows (id TEXT PRIMARY KEY, name TEXT NOT NULL CHECK (name <> 'bad'))`); err != nil {
+		t.Fatal(err)
+	}
+
+	db := diffdb.NewMemory()
+	defer db.Close()
+	diff, err := db.Open("rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := New(sqldb, "rows", SQLite, row{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, r := range []row{{Key: "a", Name: "one"}, {Key: "b", Name: "bad"}, {Key: "c", Name: "three"}} {
+		if _, err := diff.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func() (n int) {
+		if err := sqldb.QueryRow(`SELECT COUNT(*) FROM rows`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// A failed upsert rolls back the whole run
+	if err := sink.Each(ctx, diff); err == nil {
+		t.Fatal("Expected an upsert error")
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("Expected the transaction to be rolled back; got %d rows", n)
+	}
+	if n := diff.CountChanges(); n != 3 {
+		t.Fatalf("Expected every change to remain pending; got %d", n)
+	}
+
+	if _, err := diff.Add(row{Key: "b", Name: "two"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Each(ctx, diff); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 3 {
+		t.Fatalf("Expected 3 rows; got %d", n)
+	}
+	if n := diff.CountChanges(); n != 0 {
+		t.Fatalf("Expected no pending changes; got %d", n)
+	}
+}
@@ -0,0 +1,138 @@
+// Package webhook notifies an HTTP endpoint about pending changes of a diffdb Differential.
+//
+// Each change, or batch of changes, is sent as a JSON POST request.
+// Any response other than 2xx is returned as an error, so the change remains pending
+// and is sent again by the next apply run.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/relvacode/diffdb"
+)
+
+// A StatusError is returned when the endpoint responds with a status other than 2xx.
+type StatusError struct {
+	StatusCode int
+	// Body is the start of the response body.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// maxErrorBody is the maximum number of bytes of a response body included in a StatusError.
+const maxErrorBody = 512
+
+// An Event is the JSON body describing a single change.
+type Event struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash,omitempty"`
+	Run  uint64 `json:"run"`
+	// Data is the decoded payload of the change, rendered using its msgpack field names.
+	Data interface{} `json:"data"`
+}
+
+// A Sink sends changes to an HTTP endpoint.
+type Sink struct {
+	url    string
+	client *http.Client
+
+	// Header is added to every request.
+	Header http.Header
+}
+
+// New creates a new Sink posting to url.
+// If client is nil then http.DefaultClient is used.
+func New(url string, client *http.Client) *Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Sink{
+		url:    url,
+		client: client,
+		Header: make(http.Header),
+	}
+}
+
+// Apply returns an ApplyFunc which sends each change as a single Event.
+// ctx is used for every request.
+func (s *Sink) Apply(ctx context.Context) diffdb.ApplyFunc {
+	return func(id []byte, data diffdb.Decoder) error {
+		e, err := newEvent(id, nil, data)
+		if err != nil {
+			return err
+		}
+		return s.post(ctx, e)
+	}
+}
+
+// ApplyBatch returns a BatchApplyFunc which sends each batch of changes as a JSON array of events.
+// If the request fails then the whole batch remains pending.
+func (s *Sink) ApplyBatch(ctx context.Context) diffdb.BatchApplyFunc {
+	return func(batch []diffdb.Change) error {
+		events := make([]*Event, 0, len(batch))
+		for _, c := range batch {
+			e, err := newEvent(c.ID, c.Hash, c.Data)
+			if err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return s.post(ctx, events)
+	}
+}
+
+func (s *Sink) post(ctx context.Context, body interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(msg),
+		}
+	}
+
+	// Drain the body so that the connection can be reused
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+func newEvent(id, hash []byte, data diffdb.Decoder) (*Event, error) {
+	raw, err := data.DecodeJSON()
+	if err != nil {
+		return nil, fmt.Errorf("webhook: decode %q: %w", id, err)
+	}
+	return &Event{
+		ID:   string(id),
+		Hash: hex.EncodeToString(hash),
+		Run:  diffdb.RunOf(data),
+		Data: json.RawMessage(raw),
+	}, nil
+}
@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/relvacode/diffdb"
+)
+
+type row struct {
+	Key   string
+	Value int
+}
+
+func (r row) ID() []byte {
+	return []byte(r.Key)
+}
+
+func TestSink_Apply(t *testing.T) {
+	var (
+		fail     = true
+		received []Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token" {
+			t.Errorf("Expected the configured header; got %v", r.Header)
+		}
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		received = append(received, e)
+	}))
+	defer srv.Close()
+
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(row{Key: "a", Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	sink := New(srv.URL, srv.Client())
+	sink.Header.Set("Authorization", "token")
+
+	err = diff.Each(ctx, sink.Apply(ctx))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected a status error; got %v", err)
+	}
+	if pending := diff.CountChanges(); pending != 1 {
+		t.Fatalf("Expected the change to remain pending; got %d", pending)
+	}
+
+	fail = false
+	if err := diff.Each(ctx, sink.Apply(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].ID != "a" || received[0].Run != 2 {
+		t.Fatalf("Unexpected events %+v", received)
+	}
+	if data := received[0].Data.(map[string]interface{}); data["Value"] != float64(1) {
+		t.Fatalf("Unexpected data %v", data)
+	}
+}
+
+func TestSink_ApplyBatch(t *testing.T) {
+	var received [][]Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []Event
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		received = append(received, batch)
+	}))
+	defer srv.Close()
+
+	db := diffdb.NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		if _, err := diff.Add(row{Key: key, Value: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	if err := diff.EachBatch(ctx, New(srv.URL, nil).ApplyBatch(ctx), 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || len(received[0]) != 2 || len(received[1]) != 1 {
+		t.Fatalf("Expected batches of 2 and 1; got %v", received)
+	}
+	if received[0][0].Hash == "" {
+		t.Fatal("Expected batch events to include the hash")
+	}
+}
@@ -0,0 +1,91 @@
+package diffdb
+
+import (
+	"sort"
+	"time"
+)
+
+// bucketStagedAt stores the time each pending change was staged, keyed by ID.
+var bucketStagedAt = []byte("_sa")
+
+// A StagedChange is a pending change and the time it was staged.
+type StagedChange struct {
+	ID       []byte
+	StagedAt time.Time
+}
+
+// StagedAt returns the time the change being decoded by d was staged by Add.
+// It returns false for changes staged before staged times were recorded.
+func StagedAt(d Decoder) (time.Time, bool) {
+	msg, ok := d.(*msgpackDecoder)
+	if !ok || msg.staged.IsZero() {
+		return time.Time{}, false
+	}
+	return msg.staged, true
+}
+
+// PendingOlderThan returns the pending changes that were staged more than d ago, oldest first,
+// which can be used to detect a backlog that is not being applied.
+// Changes staged before staged times were recorded are not returned.
+func (diff *Differential) PendingOlderThan(d time.Duration) ([]StagedChange, error) {
+	var changes []StagedChange
+	err := diff.db.view(func(tx Tx) error {
+		bsa := tx.Bucket(diff.q).Bucket(bucketStagedAt)
+		if bsa == nil {
+			return nil
+		}
+
+		cutoff := time.Now().Add(-d)
+		return bsa.ForEach(func(id, v []byte) error {
+			if t := decodeTime(v); t.Before(cutoff) {
+				changes = append(changes, StagedChange{
+					ID:       append([]byte(nil), id...),
+					StagedAt: t,
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].StagedAt.Before(changes[j].StagedAt)
+	})
+	return changes, nil
+}
+
+// putStagedAt records id as being staged now.
+func putStagedAt(b Bucket, id []byte) error {
+	return putStagedAtTime(b, id, time.Now())
+}
+
+// putStagedAtTime records id as being staged at t, such as when restoring a change staged earlier.
+func putStagedAtTime(b Bucket, id []byte, t time.Time) error {
+	bsa, err := b.CreateBucketIfNotExists(bucketStagedAt)
+	if err != nil {
+		return err
+	}
+	return bsa.Put(id, encodeTime(t))
+}
+
+// stagedAt returns the time the pending change of id was staged, or the zero time if it is unknown.
+func stagedAt(b Bucket, id []byte) time.Time {
+	bsa := b.Bucket(bucketStagedAt)
+	if bsa == nil {
+		return time.Time{}
+	}
+	if v := bsa.Get(id); v != nil {
+		return decodeTime(v)
+	}
+	return time.Time{}
+}
+
+func clearStagedAt(b Bucket, id []byte) error {
+	bsa := b.Bucket(bucketStagedAt)
+	if bsa == nil {
+		return nil
+	}
+	return bsa.Delete(id)
+}
@@ -0,0 +1,50 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDifferential_StagedAt(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	for _, id := range []string{"1", "2"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stale, err := diff.PendingOlderThan(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 2 || string(stale[0].ID) != "1" || stale[0].StagedAt.Before(before.Truncate(0)) {
+		t.Fatalf("Expected 2 stale changes oldest first; got %+v", stale)
+	}
+	if stale, err := diff.PendingOlderThan(time.Hour); err != nil || len(stale) != 0 {
+		t.Fatalf("Expected no changes older than an hour; got %+v, %v", stale, err)
+	}
+
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		staged, ok := StagedAt(data)
+		if !ok || staged.Before(before.Truncate(0)) || staged.After(time.Now()) {
+			t.Fatalf("Unexpected staged time %s, %v", staged, ok)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stale, err := diff.PendingOlderThan(0); err != nil || len(stale) != 0 {
+		t.Fatalf("Expected staged times to be removed once applied; got %+v, %v", stale, err)
+	}
+}
@@ -0,0 +1,130 @@
+package diffdb
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+// Stats describes the storage used by a differential.
+type Stats struct {
+	// Tracked is the number of IDs with a committed hash.
+	Tracked int
+	// Pending is the number of pending changes.
+	Pending int
+	// Failed is the number of pending changes that have failed to apply at least once.
+	Failed int
+	// DeadLetters is the number of dead-lettered changes.
+	DeadLetters int
+	// History is the number of IDs with retained history.
+	History int
+	// CommittedPayloads is the number of retained payloads of applied changes.
+	CommittedPayloads int
+
+	// PendingBytes is the total size of all pending payloads.
+	PendingBytes int64
+	// AveragePendingBytes is the average size of a pending payload.
+	AveragePendingBytes float64
+
+	// Bolt contains the BoltDB statistics of the differential bucket,
+	// or nil if the database does not use a BoltDB backend.
+	Bolt *bolt.BucketStats
+}
+
+// A bucketStatser is a Bucket that can report BoltDB statistics.
+type bucketStatser interface {
+	Stats() bolt.BucketStats
+}
+
+// Stats returns key counts and payload sizes of the differential.
+func (diff *Differential) Stats() (stats Stats, err error) {
+	err = diff.db.view(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+
+		keyN := func(name []byte) int {
+			if nb := b.Bucket(name); nb != nil {
+				return nb.KeyN()
+			}
+			return 0
+		}
+
+		stats.Tracked = keyN(bucketHashes)
+		stats.Pending = keyN(bucketPendingHashes)
+		stats.Failed = keyN(bucketFailures)
+		stats.DeadLetters = keyN(bucketDeadLetters)
+		stats.History = keyN(bucketHistory)
+		stats.CommittedPayloads = keyN(bucketCommittedData)
+
+		var n int
+		err := b.Bucket(bucketPendingData).ForEach(func(_, v []byte) error {
+			stats.PendingBytes += int64(len(v))
+			n++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			stats.AveragePendingBytes = float64(stats.PendingBytes) / float64(n)
+		}
+
+		if s, ok := b.(bucketStatser); ok {
+			bs := s.Stats()
+			stats.Bolt = &bs
+		}
+		return nil
+	})
+	return
+}
+
+// DBStats summarises the storage used by all differentials of a database.
+type DBStats struct {
+	// Differentials is the number of differentials in the database.
+	Differentials int
+	// Tracked is the total number of IDs with a committed hash across all differentials.
+	Tracked int
+	// Pending is the total number of pending changes across all differentials.
+	Pending int
+
+	// FileSize is the size in bytes of the database file, or zero if the database is not backed by a file.
+	FileSize int64
+	// FreePages is the number of free pages in the database file that will be reused before the file grows.
+	FreePages int
+
+	// Bolt contains the BoltDB statistics of the database,
+	// or nil if the database does not use a BoltDB backend.
+	Bolt *bolt.Stats
+}
+
+// A dbStatser is a Backend that can report the size of its file and BoltDB statistics.
+type dbStatser interface {
+	stats() (size int64, stats bolt.Stats, err error)
+}
+
+// Stats returns aggregate counts of all differentials and the size of the database file,
+// which is suitable for exporting to monitoring.
+func (db *DB) Stats() (stats DBStats, err error) {
+	err = db.view(func(tx Tx) error {
+		return tx.ForEach(func(name []byte, b Bucket) error {
+			if b == nil || b.Bucket(bucketHashes) == nil || b.Bucket(bucketPendingHashes) == nil {
+				return nil
+			}
+			stats.Differentials++
+			stats.Tracked += b.Bucket(bucketHashes).KeyN()
+			stats.Pending += b.Bucket(bucketPendingHashes).KeyN()
+			return nil
+		})
+	})
+	if err != nil {
+		return
+	}
+
+	if s, ok := db.backend.(dbStatser); ok {
+		size, bs, err := s.stats()
+		if err != nil {
+			return stats, err
+		}
+		stats.FileSize = size
+		stats.FreePages = bs.FreePageN
+		stats.Bolt = &bs
+	}
+	return
+}
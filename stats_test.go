@@ -0,0 +1,121 @@
+package diffdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDifferential_Stats(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = diff.EachN(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := diff.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Tracked != 1 || stats.Pending != 2 {
+		t.Fatalf("Expected 1 tracked and 2 pending; got %+v", stats)
+	}
+	if stats.PendingBytes == 0 || stats.AveragePendingBytes != float64(stats.PendingBytes)/2 {
+		t.Fatalf("Unexpected payload sizes %+v", stats)
+	}
+	if stats.Bolt != nil {
+		t.Fatal("Expected no BoltDB statistics for a memory database")
+	}
+}
+
+func TestDifferential_Stats_Bolt(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := New(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := diff.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Bolt == nil || stats.Bolt.KeyN == 0 {
+		t.Fatalf("Expected BoltDB statistics; got %+v", stats.Bolt)
+	}
+}
+
+func TestDB_Stats(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := New(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i, name := range []string{"a", "b"} {
+		diff, err := db.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j <= i; j++ {
+			if _, err := diff.Add(NewIDObject([]byte{byte(j)}, j)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	diff, err := db.Open("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Differentials != 2 || stats.Tracked != 1 || stats.Pending != 2 {
+		t.Fatalf("Expected 2 differentials with 1 tracked and 2 pending; got %+v", stats)
+	}
+	if stats.FileSize == 0 || stats.Bolt == nil {
+		t.Fatalf("Expected file statistics; got %+v", stats)
+	}
+}
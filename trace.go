@@ -0,0 +1,93 @@
+package diffdb
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/relvacode/diffdb"
+
+// Span attribute keys
+const (
+	attrDifferential = attribute.Key("diffdb.differential")
+	attrObjects      = attribute.Key("diffdb.objects")
+	attrUpdated      = attribute.Key("diffdb.updated")
+	attrApplied      = attribute.Key("diffdb.applied")
+	attrFailed       = attribute.Key("diffdb.failed")
+	attrBytes        = attribute.Key("diffdb.bytes")
+	attrWritable     = attribute.Key("diffdb.writable")
+)
+
+// tracerRef holds a trace.Tracer so that it can be stored atomically.
+type tracerRef struct {
+	trace.Tracer
+}
+
+// SetTracerProvider enables OpenTelemetry tracing using tp.
+// Spans are created for Add, AddChan and Each as well as every writable transaction they start.
+// A nil provider disables tracing.
+func (db *DB) SetTracerProvider(tp trace.TracerProvider) {
+	if tp == nil {
+		db.tracer.Store(nil)
+		return
+	}
+	db.tracer.Store(&tracerRef{tp.Tracer(tracerName)})
+}
+
+// startSpan starts a new span if tracing is enabled, otherwise it returns ctx and a no-op span.
+func (db *DB) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	t := db.tracer.Load()
+	if t == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return t.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startSpan starts a new span labelled with the name of the differential.
+func (diff *Differential) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return diff.db.startSpan(ctx, name, append([]attribute.KeyValue{attrDifferential.String(diff.Name())}, attrs...)...)
+}
+
+// endSpan records err on span, if not nil, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// beginContext starts a new transaction like begin,
+// but stops waiting for the database writer if ctx is cancelled.
+// If tracing is enabled then writable transactions are recorded as a child span of ctx.
+func (db *DB) beginContext(ctx context.Context, writable bool) (Tx, error) {
+	tx, err := db.beginWait(ctx, writable)
+	if err != nil || !writable || db.tracer.Load() == nil {
+		return tx, err
+	}
+	_, span := db.startSpan(ctx, "diffdb.Tx", attrWritable.Bool(writable))
+	return &tracedTx{Tx: tx, span: span}, nil
+}
+
+// tracedTx ends the span of a transaction when it is committed or rolled back.
+type tracedTx struct {
+	Tx
+	span trace.Span
+}
+
+func (tx *tracedTx) Commit() error {
+	err := tx.Tx.Commit()
+	endSpan(tx.span, err)
+	return err
+}
+
+func (tx *tracedTx) Rollback() error {
+	err := tx.Tx.Rollback()
+	if err == nil {
+		tx.span.End()
+	}
+	return err
+}
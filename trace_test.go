@@ -0,0 +1,65 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDB_SetTracerProvider(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := tracetest.NewSpanRecorder()
+	db.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := rec.Ended()
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	if len(spans) != 4 {
+		t.Fatalf("Expected 4 spans; got %v", names)
+	}
+
+	// Transactions end before the operation that started them
+	for i, name := range []string{"diffdb.Tx", "diffdb.Add", "diffdb.Tx", "diffdb.Each"} {
+		if names[i] != name {
+			t.Fatalf("Expected span %d to be %s; got %v", i, name, names)
+		}
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Fatal("Expected the transaction span to be a child of the Add span")
+	}
+
+	attrs := make(map[string]int64)
+	for _, kv := range spans[3].Attributes() {
+		if kv.Key == attrDifferential {
+			if kv.Value.AsString() != "test" {
+				t.Fatalf("Expected differential attribute test; got %s", kv.Value.AsString())
+			}
+			continue
+		}
+		attrs[string(kv.Key)] = kv.Value.AsInt64()
+	}
+	if attrs["diffdb.applied"] != 1 || attrs["diffdb.failed"] != 0 || attrs["diffdb.bytes"] == 0 {
+		t.Fatalf("Unexpected Each span attributes %v", attrs)
+	}
+}
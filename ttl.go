@@ -0,0 +1,139 @@
+package diffdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var errTouchedDisabled = errors.New("diffdb: TrackTouched must be enabled to expire tracked IDs")
+
+// TrackTouched records the last time each ID was given to Add or Seed, even if its contents did not change.
+// This allows IDs that are no longer produced by the source system to be removed using ExpireOlderThan.
+// IDs that are already tracked are treated as being touched when TrackTouched is first called.
+//
+// TrackTouched is recorded in the differential, so it remains enabled whenever the differential is opened.
+func (diff *Differential) TrackTouched() error {
+	return diff.db.update(func(tx Tx) error {
+		return initTouched(tx.Bucket(diff.q))
+	})
+}
+
+// initTouched creates the touched bucket of b, treating IDs that are already tracked as being touched now.
+func initTouched(b Bucket) error {
+	btt, err := b.CreateBucketIfNotExists(bucketTouched)
+	if err != nil {
+		return err
+	}
+
+	now := encodeTime(time.Now())
+	return b.Bucket(bucketHashes).ForEach(func(id, _ []byte) error {
+		if btt.Get(id) != nil {
+			return nil
+		}
+		return btt.Put(id, now)
+	})
+}
+
+// ExpireOlderThan stops tracking IDs that have not been touched within d,
+// removing their committed hash along with any retained payload and history.
+// IDs with a pending change are never expired, nor are IDs that were touched but never committed.
+// An EventExpired event is emitted for each expired ID, which can be used to delete it from the target system.
+// It returns the number of expired IDs.
+func (diff *Differential) ExpireOlderThan(d time.Duration) (n int, err error) {
+	err = diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		btt := b.Bucket(bucketTouched)
+		if btt == nil {
+			return errTouchedDisabled
+		}
+
+		var (
+			cutoff  = time.Now().Add(-d)
+			bh      = b.Bucket(bucketHashes)
+			bph     = b.Bucket(bucketPendingHashes)
+			expired [][]byte
+		)
+		err := btt.ForEach(func(id, v []byte) error {
+			if bh.Get(id) != nil && bph.Get(id) == nil && decodeTime(v).Before(cutoff) {
+				expired = append(expired, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range expired {
+			if err := diff.expire(tx, b, id); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return
+}
+
+// expire removes all committed state of id.
+func (diff *Differential) expire(tx Tx, b Bucket, id []byte) error {
+	bh := b.Bucket(bucketHashes)
+	diff.emit(tx, EventExpired, id, bh.Get(id))
+
+	if err := diff.appendJournal(b, ChangeDelete, id, nil, bh.Get(id), nil); err != nil {
+		return err
+	}
+
+	return removeCommitted(b, id)
+}
+
+// removeCommitted removes the committed hash of id along with any retained payload, history, index entries and touch time.
+func removeCommitted(b Bucket, id []byte) error {
+	if err := clearIndexes(b, id); err != nil {
+		return err
+	}
+	if err := b.Bucket(bucketHashes).Delete(id); err != nil {
+		return err
+	}
+	if btt := b.Bucket(bucketTouched); btt != nil {
+		if err := btt.Delete(id); err != nil {
+			return err
+		}
+	}
+	if bcd := b.Bucket(bucketCommittedData); bcd != nil {
+		if err := bcd.Delete(id); err != nil {
+			return err
+		}
+	}
+	if bhi := b.Bucket(bucketHistory); bhi != nil && bhi.Bucket(id) != nil {
+		if err := bhi.DeleteBucket(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touch records id as being seen now if TrackTouched is enabled.
+func (diff *Differential) touch(b Bucket, id []byte) error {
+	btt := b.Bucket(bucketTouched)
+	if btt == nil {
+		return nil
+	}
+	return btt.Put(id, encodeTime(time.Now()))
+}
+
+func encodeTime(t time.Time) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(t.UnixNano()))
+	return v
+}
+
+func decodeTime(v []byte) time.Time {
+	if len(v) != 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+}
@@ -0,0 +1,128 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDifferential_ExpireOlderThan(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := diff.ExpireOlderThan(time.Hour); err != errTouchedDisabled {
+		t.Fatalf("Expected %q; got %v", errTouchedDisabled, err)
+	}
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("2"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing IDs are touched when tracking is enabled
+	if err := diff.TrackTouched(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := diff.ExpireOlderThan(time.Hour); err != nil || n != 0 {
+		t.Fatalf("Expected no IDs to expire; got %d, %v", n, err)
+	}
+
+	var expired []string
+	diff.OnExpire(func(id, hash []byte) {
+		expired = append(expired, string(id))
+	})
+
+	time.Sleep(10 * time.Millisecond)
+
+	// Touch 1 without changing it, and stage a change for 3
+	if updated, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil || updated {
+		t.Fatalf("Expected an unchanged touch; got %v, %v", updated, err)
+	}
+	if _, err := diff.Add(NewIDObject([]byte("3"), 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := diff.ExpireOlderThan(5 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(expired) != 1 || expired[0] != "2" {
+		t.Fatalf("Expected 2 to expire; got %d %v", n, expired)
+	}
+	if tracking := diff.CountTracking(); tracking != 1 {
+		t.Fatalf("Expected 1 tracked ID; got %d", tracking)
+	}
+
+	// An expired ID is treated as new when seen again
+	if updated, err := diff.Add(NewIDObject([]byte("2"), 2)); err != nil || !updated {
+		t.Fatalf("Expected expired ID to be staged again; got %v, %v", updated, err)
+	}
+}
+
+// Test that touch tracking survives reopening the differential and that IDs that were never committed are not expired.
+func TestDifferential_ExpireOlderThan_Reopen(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.TrackTouched(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Staged and then discarded, so the target never had it
+	if _, err := diff.Add(NewIDObject([]byte("3"), "3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.DiscardPending(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	reopened, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Add(NewIDObject([]byte("1"), "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	var expired []string
+	reopened.OnExpire(func(id, hash []byte) {
+		expired = append(expired, string(id))
+	})
+	n, err := reopened.ExpireOlderThan(5 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(expired) != 1 || expired[0] != "2" {
+		t.Fatalf("Expected only 2 to expire; got %d %v", n, expired)
+	}
+}
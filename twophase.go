@@ -0,0 +1,153 @@
+package diffdb
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// Prepare applies each pending change using f like Each, but changes that are successfully applied
+// are marked as in-flight instead of being committed. This allows a caller that writes to the target system
+// in its own transaction to only promote changes once that transaction has committed:
+//
+//	diff.Prepare(ctx, write)
+//	if err := downstream.Commit(); err != nil {
+//		return diff.Abort(ids)
+//	}
+//	return diff.Confirm(ids)
+//
+// In-flight changes are skipped by Each and Prepare until they are confirmed or aborted.
+// Adding a newer version of an in-flight ID replaces its pending change as usual, but the newer version is
+// not delivered until the in-flight version has been confirmed or aborted.
+func (diff *Differential) Prepare(ctx context.Context, f ApplyFunc) error {
+	return diff.EachWith(ctx, f, EachOpts{
+		TwoPhase: true,
+	})
+}
+
+// Confirm promotes the in-flight changes of ids to the committed state.
+// If a newer version of an ID was added while it was in-flight then the newer version remains pending.
+// IDs that are not in-flight are ignored.
+func (diff *Differential) Confirm(ids [][]byte) error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		bif := b.Bucket(bucketInFlight)
+		if bif == nil {
+			return nil
+		}
+
+		var (
+			bph = b.Bucket(bucketPendingHashes)
+			bpd = b.Bucket(bucketPendingData)
+		)
+		for _, id := range ids {
+			prepared := bif.Get(id)
+			if prepared == nil {
+				continue
+			}
+
+			hash := bph.Get(id)
+			if !bytes.Equal(hash, prepared) {
+				if err := bif.Delete(id); err != nil {
+					return err
+				}
+				continue
+			}
+
+			data := bpd.Get(id)
+			if data == nil {
+				return missingPayload(diff.Name(), id)
+			}
+			if err := diff.commitChange(tx, b, id, hash, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Abort returns the in-flight changes of ids to the pending queue so that they are delivered again by the next run.
+// IDs that are not in-flight are ignored.
+func (diff *Differential) Abort(ids [][]byte) error {
+	return diff.db.update(func(tx Tx) error {
+		b := tx.Bucket(diff.q)
+		for _, id := range ids {
+			if err := clearInFlight(b, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// InFlight returns the IDs of all changes that have been prepared but not yet confirmed or aborted,
+// including changes delivered by Consume whose lease has expired.
+func (diff *Differential) InFlight() ([][]byte, error) {
+	var ids [][]byte
+	err := diff.db.view(func(tx Tx) error {
+		bif := tx.Bucket(diff.q).Bucket(bucketInFlight)
+		if bif == nil {
+			return nil
+		}
+		return bif.ForEach(func(id, _ []byte) error {
+			ids = append(ids, append([]byte(nil), id...))
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// markInFlight records that the pending change of id with hash has been prepared.
+// If lease is > 0 then the change only remains in-flight until the lease expires.
+func markInFlight(b Bucket, id, hash []byte, lease time.Duration) error {
+	bif, err := b.CreateBucketIfNotExists(bucketInFlight)
+	if err != nil {
+		return err
+	}
+	if err := bif.Put(id, hash); err != nil {
+		return err
+	}
+
+	if lease <= 0 {
+		return clearLease(b, id)
+	}
+	bls, err := b.CreateBucketIfNotExists(bucketLeases)
+	if err != nil {
+		return err
+	}
+	return bls.Put(id, encodeTime(time.Now().Add(lease)))
+}
+
+// inFlight returns true if the pending change of id has been prepared and its lease, if any, has not expired.
+func inFlight(b Bucket, id []byte) bool {
+	bif := b.Bucket(bucketInFlight)
+	if bif == nil || bif.Get(id) == nil {
+		return false
+	}
+	if bls := b.Bucket(bucketLeases); bls != nil {
+		if v := bls.Get(id); v != nil {
+			return time.Now().Before(decodeTime(v))
+		}
+	}
+	return true
+}
+
+// clearInFlight removes the in-flight mark and lease of id, if any.
+func clearInFlight(b Bucket, id []byte) error {
+	bif := b.Bucket(bucketInFlight)
+	if bif == nil {
+		return nil
+	}
+	if err := bif.Delete(id); err != nil {
+		return err
+	}
+	return clearLease(b, id)
+}
+
+func clearLease(b Bucket, id []byte) error {
+	bls := b.Bucket(bucketLeases)
+	if bls == nil {
+		return nil
+	}
+	return bls.Delete(id)
+}
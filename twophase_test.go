@@ -0,0 +1,72 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_Prepare(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := diff.Add(NewIDObject([]byte(id), id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var prepared [][]byte
+	err = diff.Prepare(context.Background(), func(id []byte, data Decoder) error {
+		prepared = append(prepared, append([]byte(nil), id...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prepared) != 3 {
+		t.Fatalf("Expected 3 prepared changes; got %d", len(prepared))
+	}
+
+	// In-flight changes are not delivered again
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		t.Fatalf("Unexpected delivery of in-flight change %s", id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer version of 2 stays pending when the prepared version is confirmed
+	if _, err := diff.Add(NewIDObject([]byte("2"), "updated")); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Confirm(prepared[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.Abort(prepared[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, err := diff.InFlight(); err != nil || len(ids) != 0 {
+		t.Fatalf("Expected no in-flight changes; got %q, %v", ids, err)
+	}
+	if tracked := diff.CountTracking(); tracked != 1 {
+		t.Fatalf("Expected 1 committed change; got %d", tracked)
+	}
+
+	var pending []string
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		pending = append(pending, string(id))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0] != "2" || pending[1] != "3" {
+		t.Fatalf("Expected 2 and 3 to remain pending; got %v", pending)
+	}
+}
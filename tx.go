@@ -0,0 +1,103 @@
+package diffdb
+
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// View calls f with a read-only transaction of the database.
+// The transaction can be passed to the Tx methods of any differential and used to read custom buckets,
+// giving a consistent view of both.
+//
+// The transaction is only valid until f returns and must not be committed or rolled back by f.
+func (db *DB) View(f func(tx Tx) error) error {
+	return db.view(f)
+}
+
+// A Txn is a read-write transaction spanning every differential of a database, passed to the function given to Update.
+// Txn implements Tx, so it can also be passed to the Tx methods of a Differential.
+type Txn struct {
+	Tx
+	db *DB
+}
+
+// Update calls f with a read-write transaction of the database which is committed if f returns nil,
+// otherwise it is rolled back. This allows changes to related differentials and custom buckets to be staged atomically:
+//
+//	err := db.Update(func(txn *diffdb.Txn) error {
+//		if _, err := txn.Add("orders", order); err != nil {
+//			return err
+//		}
+//		_, err := txn.Add("customers", customer)
+//		return err
+//	})
+//
+// The following invariants must hold for the differentials to remain consistent:
+//
+//   - The transaction is only valid until f returns and must not be committed or rolled back by f.
+//   - The buckets of a differential must only be modified through Txn or the Tx methods of a Differential.
+//     Custom state belongs in top-level buckets that are not named after a differential, or in user data.
+//   - Events and hooks are delivered after the transaction commits, and never if f returns an error.
+func (db *DB) Update(f func(txn *Txn) error) error {
+	return db.update(func(tx Tx) error {
+		return f(&Txn{Tx: tx, db: db})
+	})
+}
+
+// bucket returns the bucket of the differential name, which must already exist.
+func (txn *Txn) bucket(name string) (Bucket, error) {
+	b := txn.Bucket([]byte(name))
+	if b == nil || b.Bucket(bucketHashes) == nil {
+		return nil, fmt.Errorf("diffdb: differential %q does not exist", name)
+	}
+	return b, nil
+}
+
+// differential returns the most recently opened Differential of name.
+// A differential that has not been opened by this DB is an error rather than using default settings,
+// as the same object may hash differently without the codec, compression and normalizers of the differential.
+func (txn *Txn) differential(name string) (*Differential, error) {
+	if _, err := txn.bucket(name); err != nil {
+		return nil, err
+	}
+	diff, ok := txn.db.handles.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("diffdb: differential %q has not been opened", name)
+	}
+	return diff.(*Differential), nil
+}
+
+// Add adds obj to the named differential like AddTx.
+// The settings of the most recent Differential opened by name using this DB, such as its compression and normalizers,
+// are used for the add. The differential must already exist and have been opened using Open,
+// otherwise use the AddTx method of the Differential.
+func (txn *Txn) Add(name string, obj Object) (bool, error) {
+	diff, err := txn.differential(name)
+	if err != nil {
+		return false, err
+	}
+	return diff.AddTx(txn, obj)
+}
+
+// UserData returns the user data of the named differential for use within the transaction.
+func (txn *Txn) UserData(name string) (UserData, error) {
+	b, err := txn.bucket(name)
+	if err != nil {
+		return UserData{}, err
+	}
+	return NewUserData(b.Bucket(bucketUserData)), nil
+}
+
+// BoltTx returns the BoltDB transaction underlying tx for code that needs the BoltDB API directly.
+// It returns false if the database does not use a BoltDB backend.
+// The invariants documented on Update apply to the BoltDB transaction.
+func BoltTx(tx Tx) (*bolt.Tx, bool) {
+	if txn, ok := tx.(*Txn); ok {
+		tx = txn.Tx
+	}
+	if t, ok := tx.(*boltTx); ok {
+		return t.tx, true
+	}
+	return nil, false
+}
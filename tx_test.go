@@ -0,0 +1,176 @@
+package diffdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_Update(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(v int, fail error) error {
+		return db.Update(func(txn *Txn) error {
+			if _, err := diff.AddTx(txn, NewIDObject([]byte("1"), v)); err != nil {
+				return err
+			}
+			b, err := txn.CreateBucketIfNotExists([]byte("custom"))
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte("v"), []byte{byte(v)}); err != nil {
+				return err
+			}
+			return fail
+		})
+	}
+
+	if err := write(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	fail := errors.New("fail")
+	if err := write(2, fail); err != fail {
+		t.Fatalf("Expected %q; got %v", fail, err)
+	}
+
+	var (
+		custom  []byte
+		pending bool
+		v       struct{ Object int }
+	)
+	err = db.View(func(tx Tx) error {
+		custom = append(custom, tx.Bucket([]byte("custom")).Get([]byte("v"))...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending, err = diff.GetPending([]byte("1"), &v); err != nil || !pending {
+		t.Fatalf("Expected a pending change; got %v, %v", pending, err)
+	}
+	if len(custom) != 1 || custom[0] != 1 || v.Object != 1 {
+		t.Fatalf("Expected both writes of the failed update to be rolled back; got %v and %d", custom, v.Object)
+	}
+
+	// Custom top-level buckets are not differentials
+	if names, err := db.List(); err != nil || len(names) != 1 {
+		t.Fatalf("Expected 1 differential; got %v, %v", names, err)
+	}
+}
+
+func TestBoltTx(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := New(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(txn *Txn) error {
+		btx, ok := BoltTx(txn)
+		if !ok {
+			return errors.New("expected a BoltDB transaction")
+		}
+		_, err := btx.CreateBucket([]byte("custom"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem := NewMemory()
+	defer mem.Close()
+	err = mem.View(func(tx Tx) error {
+		if _, ok := BoltTx(tx); ok {
+			return errors.New("expected no BoltDB transaction for a memory database")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxn_Add(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	orders, err := db.Open("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	customers, err := db.Open("customers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fail := errors.New("fail")
+	for _, expect := range []error{fail, nil} {
+		err := db.Update(func(txn *Txn) error {
+			if _, err := txn.Add("orders", NewIDObject([]byte("1"), "order")); err != nil {
+				return err
+			}
+			if _, err := txn.Add("customers", NewIDObject([]byte("1"), "customer")); err != nil {
+				return err
+			}
+			ud, err := txn.UserData("orders")
+			if err != nil {
+				return err
+			}
+			if err := ud.PutString("k", "v"); err != nil {
+				return err
+			}
+			return expect
+		})
+		if err != expect {
+			t.Fatalf("Expected %v; got %v", expect, err)
+		}
+
+		n := 0
+		if expect == nil {
+			n = 1
+		}
+		if orders.CountChanges() != n || customers.CountChanges() != n {
+			t.Fatalf("Expected %d pending change in each differential", n)
+		}
+	}
+
+	err = db.Update(func(txn *Txn) error {
+		_, err := txn.Add("missing", NewIDObject([]byte("1"), 1))
+		return err
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a missing differential")
+	}
+
+	// A deleted differential is not added to using its previous handle once the name is reused
+	if err := db.Delete("orders"); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(txn *Txn) error {
+		if _, err := txn.CreateBucket([]byte("orders")); err != nil {
+			return err
+		}
+		if _, err := txn.Bucket([]byte("orders")).CreateBucket(bucketHashes); err != nil {
+			return err
+		}
+		_, err := txn.Add("orders", NewIDObject([]byte("1"), 1))
+		return err
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a differential that has not been opened")
+	}
+}
@@ -0,0 +1,97 @@
+package diffdb
+
+import (
+	"encoding/binary"
+	"time"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// UserData provides typed access to the user data bucket of a differential, such as within the functions given
+// to ViewUserData and UpdateUserData:
+//
+//	err := diff.UpdateUserData(func(b diffdb.Bucket) error {
+//		return diffdb.NewUserData(b).PutTime("last_export", time.Now())
+//	})
+//
+// Get methods return false if the key does not exist or was not written by the matching Put method.
+type UserData struct {
+	b Bucket
+}
+
+// NewUserData wraps the user data bucket b.
+func NewUserData(b Bucket) UserData {
+	return UserData{b: b}
+}
+
+// Bucket returns the underlying bucket for raw access.
+func (ud UserData) Bucket() Bucket {
+	return ud.b
+}
+
+// Delete removes key.
+func (ud UserData) Delete(key string) error {
+	return ud.b.Delete([]byte(key))
+}
+
+// GetString returns the string stored at key.
+func (ud UserData) GetString(key string) (string, bool) {
+	v := ud.b.Get([]byte(key))
+	if v == nil {
+		return "", false
+	}
+	return string(v), true
+}
+
+// PutString stores a string at key.
+func (ud UserData) PutString(key, v string) error {
+	return ud.b.Put([]byte(key), []byte(v))
+}
+
+// GetUint64 returns the integer stored at key.
+func (ud UserData) GetUint64(key string) (uint64, bool) {
+	v := ud.b.Get([]byte(key))
+	if len(v) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(v), true
+}
+
+// PutUint64 stores an integer at key as 8 big-endian bytes.
+func (ud UserData) PutUint64(key string, v uint64) error {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return ud.b.Put([]byte(key), b)
+}
+
+// GetTime returns the time stored at key.
+func (ud UserData) GetTime(key string) (time.Time, bool) {
+	v := ud.b.Get([]byte(key))
+	if len(v) != 8 {
+		return time.Time{}, false
+	}
+	return decodeTime(v), true
+}
+
+// PutTime stores a time at key with nanosecond precision. The location of t is not kept.
+func (ud UserData) PutTime(key string, t time.Time) error {
+	return ud.b.Put([]byte(key), encodeTime(t))
+}
+
+// Get decodes the msgpack value stored at key into v.
+func (ud UserData) Get(key string, v interface{}) (bool, error) {
+	data := ud.b.Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	return true, msgpack.Unmarshal(data, v)
+}
+
+// Put stores v at key encoded using msgpack.
+func (ud UserData) Put(key string, v interface{}) error {
+	data, err := marshal(v)
+	if err != nil {
+		return err
+	}
+	return ud.b.Put([]byte(key), data)
+}
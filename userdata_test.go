@@ -0,0 +1,65 @@
+package diffdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserData(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type export struct {
+		Name  string
+		Count int
+	}
+	now := time.Now()
+	err = diff.UpdateUserData(func(b Bucket) error {
+		ud := NewUserData(b)
+		if err := ud.PutString("s", "v"); err != nil {
+			return err
+		}
+		if err := ud.PutUint64("u", 42); err != nil {
+			return err
+		}
+		if err := ud.PutTime("t", now); err != nil {
+			return err
+		}
+		return ud.Put("m", export{"a", 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = diff.ViewUserData(func(b Bucket) error {
+		ud := NewUserData(b)
+		if s, ok := ud.GetString("s"); !ok || s != "v" {
+			t.Fatalf("Expected string v; got %q, %v", s, ok)
+		}
+		if u, ok := ud.GetUint64("u"); !ok || u != 42 {
+			t.Fatalf("Expected 42; got %d, %v", u, ok)
+		}
+		if tm, ok := ud.GetTime("t"); !ok || !tm.Equal(now) {
+			t.Fatalf("Expected %s; got %s, %v", now, tm, ok)
+		}
+		var e export
+		if ok, err := ud.Get("m", &e); err != nil || !ok || e != (export{"a", 1}) {
+			t.Fatalf("Expected %+v; got %+v, %v, %v", export{"a", 1}, e, ok, err)
+		}
+		if _, ok := ud.GetUint64("s"); ok {
+			t.Fatal("Expected a string not to decode as an integer")
+		}
+		if _, ok := ud.GetString("missing"); ok {
+			t.Fatal("Expected a missing key")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
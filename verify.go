@@ -0,0 +1,33 @@
+package diffdb
+
+import (
+	"bytes"
+	"log/slog"
+)
+
+// VerifyContent guards against hash collisions by comparing payloads byte for byte whenever
+// the hash of an added object matches its committed or pending hash.
+// If the stored payload differs from the new encoding the object is treated as changed.
+//
+// Committed payloads can only be compared if RetainPayloads is enabled, and an ObjectVersioned is never compared.
+// Objects must encode deterministically, otherwise every add will be treated as a change.
+func (diff *Differential) VerifyContent() {
+	diff.verifyContent = true
+}
+
+// sameContent returns false if the stored payload is known to differ from raw despite having the same hash.
+// A missing payload cannot be compared, so it is assumed to be the same.
+func (diff *Differential) sameContent(id, stored, raw []byte) (bool, error) {
+	if stored == nil {
+		return true, nil
+	}
+	stored, err := decompress(stored)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(stored, raw) {
+		return true, nil
+	}
+	diff.log(slog.LevelWarn, "hash collision", logID(id))
+	return false, nil
+}
@@ -0,0 +1,46 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDifferential_VerifyContent(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diff.RetainPayloads(); err != nil {
+		t.Fatal(err)
+	}
+	diff.VerifyContent()
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil {
+		t.Fatal(err)
+	}
+	if updated, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil || updated {
+		t.Fatalf("Expected an identical pending object to be unchanged; got %v, %v", updated, err)
+	}
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if updated, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil || updated {
+		t.Fatalf("Expected an identical committed object to be unchanged; got %v, %v", updated, err)
+	}
+
+	// Simulate a collision by storing a different committed payload under the same hash
+	err = db.update(func(tx Tx) error {
+		return tx.Bucket(diff.q).Bucket(bucketCommittedData).Put([]byte("1"), []byte{0xc0})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated, err := diff.Add(NewIDObject([]byte("1"), "value")); err != nil || !updated {
+		t.Fatalf("Expected a colliding object to be changed; got %v, %v", updated, err)
+	}
+}
@@ -0,0 +1,41 @@
+package diffdb
+
+import (
+	"encoding/binary"
+)
+
+// ObjectVersioned is an Object whose source system already supplies a monotonically increasing version,
+// such as a revision counter or an updated_at timestamp.
+// The version is stored in place of a hash, so the object is never hashed and is only treated as changed
+// if its version is greater than the committed or pending version of the same ID.
+type ObjectVersioned interface {
+	Object
+	Version() uint64
+}
+
+// versionMarker prefixes a stored version so that it can never be mistaken for a hash of any width.
+const versionMarker = 'v'
+
+func encodeVersion(v uint64) []byte {
+	b := make([]byte, 9)
+	b[0] = versionMarker
+	binary.BigEndian.PutUint64(b[1:], v)
+	return b
+}
+
+func decodeVersion(b []byte) (uint64, bool) {
+	if len(b) != 9 || b[0] != versionMarker {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(b[1:]), true
+}
+
+// notNewer returns true if hash and existing are both versions and hash is not newer than existing.
+func notNewer(hash, existing []byte) bool {
+	v, ok := decodeVersion(hash)
+	if !ok {
+		return false
+	}
+	current, ok := decodeVersion(existing)
+	return ok && v <= current
+}
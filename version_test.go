@@ -0,0 +1,64 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+)
+
+type versionedObject struct {
+	Key      string
+	Value    string
+	Revision uint64
+}
+
+func (o versionedObject) ID() []byte {
+	return []byte(o.Key)
+}
+
+func (o versionedObject) Version() uint64 {
+	return o.Revision
+}
+
+func TestDifferential_AddVersioned(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		obj     versionedObject
+		updated bool
+	}{
+		{versionedObject{"1", "a", 2}, true},
+		// Same version is unchanged even if the contents differ
+		{versionedObject{"1", "b", 2}, false},
+		// An older version arriving late is ignored
+		{versionedObject{"1", "c", 1}, false},
+		{versionedObject{"1", "d", 3}, true},
+	} {
+		updated, err := diff.Add(c.obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated != c.updated {
+			t.Fatalf("Expected updated %v for %+v; got %v", c.updated, c.obj, updated)
+		}
+	}
+
+	if err := diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := diff.Changed([]byte("1"), versionedObject{"1", "e", 3})
+	if err != nil || changed {
+		t.Fatalf("Expected the committed version to be unchanged; got %v, %v", changed, err)
+	}
+	if updated, err := diff.Add(versionedObject{"1", "f", 2}); err != nil || updated {
+		t.Fatalf("Expected an older version to be ignored; got %v, %v", updated, err)
+	}
+}
@@ -0,0 +1,108 @@
+package diffdb
+
+import (
+	"context"
+	"fmt"
+)
+
+// watchBuffer is the number of events buffered for each watcher before events are dropped.
+const watchBuffer = 256
+
+// EventKind identifies what happened to a change.
+type EventKind int
+
+const (
+	// EventStaged is emitted when a new pending change is added.
+	EventStaged EventKind = iota + 1
+	// EventApplied is emitted when a pending change is successfully applied.
+	EventApplied
+	// EventDiscarded is emitted when a pending change is removed without being applied.
+	EventDiscarded
+	// EventExpired is emitted when a tracked ID is removed by ExpireOlderThan.
+	EventExpired
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventStaged:
+		return "staged"
+	case EventApplied:
+		return "applied"
+	case EventDiscarded:
+		return "discarded"
+	case EventExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// An Event describes a change to the state of an ID in a differential.
+type Event struct {
+	Kind EventKind
+	ID   []byte
+	Hash []byte
+}
+
+// Watch returns a channel that receives an event whenever a pending change is staged, applied, or discarded,
+// or a tracked ID is expired through this Differential. Events are emitted once the transaction containing the change has committed.
+// The channel is closed when the context is cancelled.
+//
+// Events are delivered on a best-effort basis: if the receiver falls behind then events are dropped
+// rather than blocking writers. Watch is intended to trigger processing, not as a durable change log.
+func (diff *Differential) Watch(ctx context.Context) <-chan Event {
+	ch := make(chan Event, watchBuffer)
+
+	diff.mu.Lock()
+	if diff.watchers == nil {
+		diff.watchers = make(map[chan Event]struct{})
+	}
+	diff.watchers[ch] = struct{}{}
+	diff.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		diff.mu.Lock()
+		delete(diff.watchers, ch)
+		close(ch)
+		diff.mu.Unlock()
+	}()
+
+	return ch
+}
+
+// observed returns true if there are any watchers or hooks that would receive an event of kind.
+func (diff *Differential) observed(kind EventKind) bool {
+	diff.mu.Lock()
+	defer diff.mu.Unlock()
+	return len(diff.watchers) > 0 || len(diff.hooks[kind]) > 0
+}
+
+// emit delivers an event to all watchers and hooks once tx has committed.
+func (diff *Differential) emit(tx Tx, kind EventKind, id, hash []byte) {
+	if !diff.observed(kind) {
+		return
+	}
+
+	e := Event{
+		Kind: kind,
+		ID:   append([]byte(nil), id...),
+		Hash: append([]byte(nil), hash...),
+	}
+	tx.OnCommit(func() {
+		diff.mu.Lock()
+		hooks := diff.hooks[kind]
+		for ch := range diff.watchers {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+		diff.mu.Unlock()
+
+		for _, h := range hooks {
+			h(e.ID, e.Hash)
+		}
+	})
+}
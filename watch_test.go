@@ -0,0 +1,51 @@
+package diffdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDifferential_Watch(t *testing.T) {
+	db := NewMemory()
+	defer db.Close()
+
+	diff, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := diff.Watch(ctx)
+
+	if _, err := diff.Add(NewIDObject([]byte("1"), 1)); err != nil {
+		t.Fatal(err)
+	}
+	err = diff.Each(context.Background(), func(id []byte, data Decoder) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []EventKind{EventStaged, EventApplied} {
+		select {
+		case e := <-events:
+			if e.Kind != kind || string(e.ID) != "1" || len(e.Hash) == 0 {
+				t.Fatalf("Expected %s event for ID 1; got %+v", kind, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s event", kind)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("Expected no further events")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the channel to close")
+	}
+}